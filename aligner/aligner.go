@@ -0,0 +1,358 @@
+// This package aligns nucleotide sequences against the genes of an
+// alignment profile. It's the entry point for programs that embed
+// nucamino as a library instead of calling the command line tool.
+//
+// An Aligner is built once and can then be shared by any number of
+// goroutines:
+//
+//	profile, _ := builtin.Get("hiv1b")
+//	aln, err := aligner.New(*profile, []ap.Gene{"POL"})
+//	...
+//	results, err := aln.Align(ctx, "seq1", n.ReadString("ACAGTRTTAGTA..."))
+package aligner
+
+import (
+	"context"
+	"github.com/hivdb/nucamino/alignment"
+	ap "github.com/hivdb/nucamino/alignmentprofile"
+	h "github.com/hivdb/nucamino/scorehandler/general"
+	a "github.com/hivdb/nucamino/types/amino"
+	n "github.com/hivdb/nucamino/types/nucleic"
+	"sync"
+)
+
+// The outcome of aligning a sequence against one gene. Exactly one
+// of Report and Err is set. Score is the screening score, which is
+// only calculated when genes are detected automatically.
+type GeneResult struct {
+	Gene   ap.Gene
+	Report *alignment.AlignmentReport
+	Err    error
+	Score  float64
+}
+
+// The default for Options.MinDetectionScore. Random sequences
+// rarely score higher than 20 against any gene, while a true match
+// scores about 5 per aligned codon.
+const DefaultMinDetectionScore = 50
+
+type Options struct {
+	// Screen each sequence against every gene with a cheap scoring
+	// pass first, and only align it against the genes it scores at
+	// least MinDetectionScore on. Genes that weren't detected are
+	// reported with ErrGeneNotDetected. A Genotyper also uses
+	// MinDetectionScore as the minimum alignment score of the
+	// profiles it ranks. A zero MinDetectionScore means
+	// DefaultMinDetectionScore.
+	DetectGenes       bool
+	MinDetectionScore float64
+	// Align the reverse complement of each sequence as well, and
+	// keep whichever strand scores higher. Reports of reverse
+	// complement alignments have IsReverseComplement set.
+	BothStrands bool
+	// Replace the bases whose Phred quality is below MinQuality by N
+	// before aligning. Only applies to sequences with base qualities.
+	MinQuality int
+	// Weight the substitution score of each codon by the quality of
+	// its bases, and annotate mutations with their codon quality.
+	// Only applies to sequences with base qualities.
+	QualityWeighting bool
+	// Reject the alignments that don't meet these thresholds. The
+	// thresholds that are set override those of the profile.
+	// Rejected alignments are reported with an
+	// *alignment.RejectedError.
+	Thresholds ap.Thresholds
+	// Place the genes of the genome order of the profile
+	// consistently: the alignments of the genes that conflict with
+	// the best scoring set of placements in genome order are
+	// rejected with ErrConflictingPlacement.
+	GenomeMode bool
+}
+
+type Aligner struct {
+	profile ap.AlignmentProfile
+	genes   []ap.Gene
+	// The gene whose reference each of genes is aligned against, and
+	// the region if genes[i] is the name of a region
+	refGenes []ap.Gene
+	regions  []*ap.Region
+	refs     [][]a.AminoAcid
+	options  Options
+	// the thresholds of the profile, overridden by the options
+	thresholds ap.Thresholds
+	// Score handlers cache substitution scores while aligning, so a
+	// handler can't be used by two goroutines at the same time. Each
+	// call to Align borrows one handler per gene from this pool.
+	handlers sync.Pool
+}
+
+// Create an Aligner for the given genes of profile. The genes must
+// match the gene names in the profile exactly; the results returned
+// by Align follow the order of this list. The names of the regions
+// of the profile can be given as well: the sequences are aligned
+// against the gene of the region, and the reports are restricted to
+// the region.
+func New(profile ap.AlignmentProfile, genes []ap.Gene) (*Aligner, error) {
+	return NewWithOptions(profile, genes, Options{})
+}
+
+// Create an Aligner like New does, with non-default options.
+func NewWithOptions(profile ap.AlignmentProfile, genes []ap.Gene, options Options) (*Aligner, error) {
+	if len(genes) == 0 {
+		return nil, &NoGenesError{}
+	}
+	refs := make([][]a.AminoAcid, len(genes))
+	refGenes := make([]ap.Gene, len(genes))
+	regions := make([]*ap.Region, len(genes))
+	for i, gene := range genes {
+		refGenes[i] = gene
+		for j, region := range profile.Regions {
+			if region.Name == string(gene) {
+				refGenes[i], regions[i] = region.Gene, &profile.Regions[j]
+				break
+			}
+		}
+		ref, found := profile.ReferenceSequences[refGenes[i]]
+		if !found {
+			return nil, &UnknownGeneError{gene, profile.Genes()}
+		}
+		refs[i] = ref
+	}
+	if options.GenomeMode && (profile.Genome == nil || len(profile.Genome.Order) == 0) {
+		return nil, ErrNoGenomeOrder
+	}
+	if options.DetectGenes && options.MinDetectionScore == 0 {
+		options.MinDetectionScore = DefaultMinDetectionScore
+	}
+	aligner := &Aligner{
+		profile:    profile,
+		genes:      append([]ap.Gene(nil), genes...),
+		refGenes:   refGenes,
+		regions:    regions,
+		refs:       refs,
+		options:    options,
+		thresholds: profile.Thresholds.Override(options.Thresholds),
+	}
+	aligner.handlers.New = func() interface{} {
+		handlers := make([]*h.GeneralScoreHandler, len(aligner.genes))
+		for i, gene := range aligner.refGenes {
+			handlers[i] = h.New(gene, aligner.profile)
+		}
+		return handlers
+	}
+	return aligner, nil
+}
+
+// The genes this Aligner aligns against, in result order.
+func (self *Aligner) Genes() []ap.Gene {
+	return append([]ap.Gene(nil), self.genes...)
+}
+
+// Align a nucleotide sequence against every gene of the
+// Aligner. Failing to align against a gene isn't an error: it's
+// reported by the Err field of the corresponding GeneResult. An
+// error is only returned if ctx is done before all genes are
+// aligned.
+func (self *Aligner) Align(ctx context.Context, name string, nas []n.NucleicAcid) ([]GeneResult, error) {
+	return self.AlignWithQuality(ctx, name, nas, nil)
+}
+
+// Align a nucleotide sequence like Align does, given the Phred
+// quality of each of its bases (e.g. read from a FASTQ file). How the
+// qualities are used depends on the MinQuality and QualityWeighting
+// options; a nil quality is the same as calling Align. A
+// *QualityLengthError is returned if quality doesn't have one value
+// per base.
+func (self *Aligner) AlignWithQuality(
+	ctx context.Context, name string,
+	nas []n.NucleicAcid, quality []int) ([]GeneResult, error) {
+	masked, err := self.maskLowQuality(nas, quality)
+	if err != nil {
+		return nil, err
+	}
+	return self.alignMasked(ctx, masked, quality)
+}
+
+// Replace the bases whose quality is below MinQuality by N. The
+// sequence is only copied if any base was replaced. A
+// *QualityLengthError is returned if quality isn't nil and doesn't
+// have one value per base.
+func (self *Aligner) maskLowQuality(
+	nas []n.NucleicAcid, quality []int) ([]n.NucleicAcid, error) {
+	if quality != nil && len(quality) != len(nas) {
+		return nil, &QualityLengthError{len(nas), len(quality)}
+	}
+	if quality == nil || self.options.MinQuality <= 0 {
+		return nas, nil
+	}
+	masked, copied := nas, false
+	for i, q := range quality {
+		if q >= self.options.MinQuality || masked[i] == n.N {
+			continue
+		}
+		if !copied {
+			masked, copied = append([]n.NucleicAcid(nil), nas...), true
+		}
+		masked[i] = n.N
+	}
+	return masked, nil
+}
+
+func (self *Aligner) alignMasked(
+	ctx context.Context, nas []n.NucleicAcid, quality []int) ([]GeneResult, error) {
+	handlers := self.handlers.Get().([]*h.GeneralScoreHandler)
+	defer self.handlers.Put(handlers)
+	if !self.options.QualityWeighting {
+		quality = nil
+	}
+	var nasRC []n.NucleicAcid
+	var qualityRC []int
+	if self.options.BothStrands {
+		nasRC = n.ReverseComplement(nas)
+		if quality != nil {
+			qualityRC = make([]int, len(quality))
+			for i, q := range quality {
+				qualityRC[len(quality)-1-i] = q
+			}
+		}
+	}
+	results := make([]GeneResult, len(self.genes))
+	// regions of the same gene share the alignment against the gene
+	aligned := make(map[ap.Gene]geneAlignment)
+	for i := range self.genes {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		geneAln, found := aligned[self.refGenes[i]]
+		if !found {
+			geneAln = self.alignGene(
+				ctx, i, strand{nas, quality}, strand{nasRC, qualityRC}, handlers[i])
+			aligned[self.refGenes[i]] = geneAln
+		}
+		results[i] = self.makeGeneResult(i, geneAln)
+	}
+	// the last gene may have been cut short
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if self.options.GenomeMode {
+		self.resolvePlacements(
+			ctx, results, strand{nas, quality}, strand{nasRC, qualityRC}, handlers)
+		// and so may a gene aligned again to place it
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// A strand of a sequence to align, and the qualities of its bases if
+// they are used for scoring
+type strand struct {
+	nas     []n.NucleicAcid
+	quality []int
+}
+
+func (s strand) align(
+	ctx context.Context, ref []a.AminoAcid,
+	handler *h.GeneralScoreHandler) (*alignment.Alignment, error) {
+	return alignment.NewAlignmentWithContext(ctx, s.nas, s.quality, ref, handler)
+}
+
+// The alignment of a sequence against the reference of a gene. nas
+// is the strand that was aligned; Score is the screening score.
+type geneAlignment struct {
+	report *alignment.AlignmentReport
+	nas    []n.NucleicAcid
+	score  float64
+	err    error
+}
+
+// Align fwd, and rc if it isn't empty, against the reference of the
+// i-th gene; the alignment stops early once ctx is done
+func (self *Aligner) alignGene(
+	ctx context.Context, i int, fwd strand, rc strand,
+	handler *h.GeneralScoreHandler) geneAlignment {
+	var (
+		ref                 = self.refs[i]
+		result              geneAlignment
+		isReverseComplement = false
+	)
+	if self.options.DetectGenes {
+		// screening both strands is cheaper than aligning both, so
+		// only the strand that scores higher is aligned
+		result.score = alignment.CalcScoreWithQuality(fwd.nas, fwd.quality, ref, handler)
+		if rc.nas != nil {
+			if scoreRC := alignment.CalcScoreWithQuality(rc.nas, rc.quality, ref, handler); scoreRC > result.score {
+				result.score = scoreRC
+				fwd = rc
+				isReverseComplement = true
+			}
+			rc = strand{}
+		}
+		if result.score < self.options.MinDetectionScore {
+			result.err = ErrGeneNotDetected
+			return result
+		}
+	}
+	aligned, err := fwd.align(ctx, ref, handler)
+	result.nas = fwd.nas
+	if rc.nas != nil {
+		alignedRC, errRC := rc.align(ctx, ref, handler)
+		if errRC == nil && (err != nil || alignedRC.GetScore() > aligned.GetScore()) {
+			aligned, err = alignedRC, nil
+			result.nas = rc.nas
+			isReverseComplement = true
+		}
+	}
+	if err != nil {
+		result.err = err
+		return result
+	}
+	result.report = self.annotatedReport(i, aligned, result.nas, isReverseComplement)
+	return result
+}
+
+// The report of an alignment of nas against the reference of the
+// i-th gene, annotated with what the profile defines for the gene
+func (self *Aligner) annotatedReport(
+	i int, aligned *alignment.Alignment, nas []n.NucleicAcid,
+	isReverseComplement bool) *alignment.AlignmentReport {
+	report := aligned.GetReport()
+	report.IsReverseComplement = isReverseComplement
+	if regions := self.profile.RegionsOf(self.refGenes[i]); len(regions) > 0 {
+		report.AnnotateRegions(regions)
+	}
+	if start, found := self.profile.Genome.StartOf(self.refGenes[i]); found {
+		report.AnnotateGenome(start)
+	}
+	if nucRef, found := self.profile.NucleotideReferenceSequences[self.refGenes[i]]; found {
+		report.CompareNucleotides(nucRef, nas)
+	}
+	return report
+}
+
+// The result of the i-th gene, restricting the alignment against its
+// reference to the region if the gene is a region
+func (self *Aligner) makeGeneResult(i int, aligned geneAlignment) GeneResult {
+	gene := self.genes[i]
+	result := GeneResult{Gene: gene, Score: aligned.score}
+	if aligned.err != nil {
+		result.Err = &AlignmentError{gene, aligned.err}
+		return result
+	}
+	report := aligned.report
+	if region := self.regions[i]; region != nil {
+		var err error
+		if report, err = report.ForRegion(*region, aligned.nas); err != nil {
+			result.Err = &AlignmentError{gene, err}
+			return result
+		}
+	}
+	if err := report.CheckThresholds(self.thresholds); err != nil {
+		result.Err = &AlignmentError{gene, err}
+		return result
+	}
+	result.Report = report
+	return result
+}
@@ -0,0 +1,619 @@
+package aligner
+
+import (
+	"context"
+	"errors"
+	"github.com/hivdb/nucamino/alignment"
+	ap "github.com/hivdb/nucamino/alignmentprofile"
+	"github.com/hivdb/nucamino/alignmentprofile/builtin"
+	a "github.com/hivdb/nucamino/types/amino"
+	n "github.com/hivdb/nucamino/types/nucleic"
+	"github.com/hivdb/nucamino/utils/fastareader"
+	"reflect"
+	"testing"
+)
+
+const (
+	MSG_NOT_EQUAL = "Expect %#v but received %#v"
+)
+
+var (
+	NSEQ                      = n.ReadString("ACAGTRTTAGTAGGACCTACACCTGCCAACATAATTGGAAGAAATCTGTTGACYCAG")
+	EXAMPLE_ALIGNMENT_PROFILE = ap.AlignmentProfile{
+		StopCodonPenalty:         4,
+		GapOpeningPenalty:        10,
+		GapExtensionPenalty:      2,
+		IndelCodonOpeningBonus:   0,
+		IndelCodonExtensionBonus: 2,
+		ReferenceSequences: ap.ReferenceSeqs{
+			"A": a.ReadString("TVLVGPTPVNIIGRNLLTQ"),
+			"B": a.ReadString("GGGGGGGGGGGGGGGGGG"),
+		},
+	}
+)
+
+func TestNewWithUnknownGene(t *testing.T) {
+	_, err := New(EXAMPLE_ALIGNMENT_PROFILE, []ap.Gene{"A", "Z"})
+	if geneErr, ok := err.(*UnknownGeneError); !ok || geneErr.Gene != "Z" {
+		t.Errorf(MSG_NOT_EQUAL, &UnknownGeneError{Gene: "Z"}, err)
+	}
+	_, err = New(EXAMPLE_ALIGNMENT_PROFILE, nil)
+	if _, ok := err.(*NoGenesError); !ok {
+		t.Errorf(MSG_NOT_EQUAL, &NoGenesError{}, err)
+	}
+}
+
+func TestAlign(t *testing.T) {
+	aln, err := New(EXAMPLE_ALIGNMENT_PROFILE, []ap.Gene{"A", "B"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	results, err := aln.Align(context.Background(), "seq", NSEQ)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if results[0].Gene != "A" || results[0].Err != nil || results[0].Report.LastAA != 19 {
+		t.Errorf("Expected sequence to be aligned against gene A, got %#v", results[0])
+	}
+	alnErr, ok := results[1].Err.(*AlignmentError)
+	if !ok || alnErr.Gene != "B" || alnErr.Err != alignment.ErrNoOverlap {
+		t.Errorf(MSG_NOT_EQUAL, &AlignmentError{"B", alignment.ErrNoOverlap}, results[1].Err)
+	}
+}
+
+func TestAlignThresholds(t *testing.T) {
+	profile := EXAMPLE_ALIGNMENT_PROFILE
+	minCoverage, minScorePerCodon := 1.0, 100.0
+	profile.Thresholds = ap.Thresholds{MinCoverage: &minCoverage}
+	aln, _ := NewWithOptions(profile, []ap.Gene{"A"}, Options{
+		Thresholds: ap.Thresholds{MinScorePerCodon: &minScorePerCodon},
+	})
+	results, _ := aln.Align(context.Background(), "seq", NSEQ)
+	// the coverage threshold of the profile is met, the score
+	// threshold of the options isn't
+	if results[0].Report != nil || !errors.As(results[0].Err, new(*alignment.LowScoreError)) {
+		t.Errorf("Expected the alignment to be rejected for its score, got %#v", results[0])
+	}
+	var rejected *alignment.RejectedError
+	if !errors.As(results[0].Err, &rejected) || rejected.Report.LastAA != 19 {
+		t.Errorf("Expected the rejected report, got %v", results[0].Err)
+	}
+}
+
+func TestAlignCancelled(t *testing.T) {
+	aln, _ := New(EXAMPLE_ALIGNMENT_PROFILE, []ap.Gene{"A"})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := aln.Align(ctx, "seq", NSEQ)
+	if err != context.Canceled {
+		t.Errorf(MSG_NOT_EQUAL, context.Canceled, err)
+	}
+}
+
+func TestAlignStreamKeepsInputOrder(t *testing.T) {
+	aln, _ := New(EXAMPLE_ALIGNMENT_PROFILE, []ap.Gene{"A"})
+	names := []string{"s0", "s1", "s2", "s3", "s4", "s5", "s6", "s7", "s8", "s9"}
+	seqs := make(chan fastareader.Sequence)
+	go func() {
+		for i, name := range names {
+			// alternate long and short sequences so that they
+			// don't finish in input order
+			nas := NSEQ
+			if i%2 == 0 {
+				nas = append(append([]n.NucleicAcid{}, NSEQ...), NSEQ...)
+			}
+			seqs <- fastareader.Sequence{Name: name, Sequence: nas}
+		}
+		close(seqs)
+	}()
+	idx := 0
+	for result := range aln.AlignStream(context.Background(), seqs, 4) {
+		if result.Index != idx || result.Name != names[idx] {
+			t.Errorf(MSG_NOT_EQUAL, names[idx], result.Name)
+		}
+		idx++
+	}
+	if idx != len(names) {
+		t.Errorf(MSG_NOT_EQUAL, len(names), idx)
+	}
+}
+
+func TestAlignDetectGenes(t *testing.T) {
+	aln, _ := NewWithOptions(
+		EXAMPLE_ALIGNMENT_PROFILE, []ap.Gene{"A", "B"},
+		Options{DetectGenes: true})
+	results, _ := aln.Align(context.Background(), "seq", NSEQ)
+	if results[0].Report == nil || results[0].Score < DefaultMinDetectionScore {
+		t.Errorf("Expected gene A to be detected, got %#v", results[0])
+	}
+	alnErr, ok := results[1].Err.(*AlignmentError)
+	if !ok || alnErr.Err != ErrGeneNotDetected {
+		t.Errorf(MSG_NOT_EQUAL, &AlignmentError{"B", ErrGeneNotDetected}, results[1].Err)
+	}
+}
+
+func TestAlignBothStrands(t *testing.T) {
+	aln, _ := NewWithOptions(
+		EXAMPLE_ALIGNMENT_PROFILE, []ap.Gene{"A"},
+		Options{BothStrands: true})
+	for _, detect := range []bool{false, true} {
+		aln.options.DetectGenes = detect
+		aln.options.MinDetectionScore = DefaultMinDetectionScore
+		results, _ := aln.Align(context.Background(), "seq", NSEQ)
+		if results[0].Report == nil || results[0].Report.IsReverseComplement {
+			t.Errorf("Expected forward strand to be aligned, got %#v", results[0])
+		}
+		results, _ = aln.Align(context.Background(), "seq", n.ReverseComplement(NSEQ))
+		if results[0].Report == nil || !results[0].Report.IsReverseComplement ||
+			results[0].Report.LastAA != 19 {
+			t.Errorf("Expected reverse complement to be aligned, got %#v", results[0])
+		}
+	}
+}
+
+func exampleQuality(low int, lowPositions ...int) []int {
+	quality := make([]int, len(NSEQ))
+	for i := range quality {
+		quality[i] = 40
+	}
+	for _, pos := range lowPositions {
+		quality[pos-1] = low
+	}
+	return quality
+}
+
+func TestAlignMaskLowQuality(t *testing.T) {
+	aln, _ := NewWithOptions(
+		EXAMPLE_ALIGNMENT_PROFILE, []ap.Gene{"A"},
+		Options{MinQuality: 20})
+	nas := append([]n.NucleicAcid(nil), NSEQ...)
+	results, _ := aln.AlignWithQuality(context.Background(), "seq", nas, exampleQuality(10, 4, 5, 6))
+	report := results[0].Report
+	if report == nil || report.Mutations[0].Position != 2 || report.Mutations[0].CodonText != "NNN" {
+		t.Errorf("Expected codon 2 to be masked, got %#v", results[0])
+	}
+	if n.WriteString(nas) != n.WriteString(NSEQ) {
+		t.Errorf("Expected the input sequence to be left unchanged")
+	}
+}
+
+func TestAlignQualityLengthMismatch(t *testing.T) {
+	masking, _ := NewWithOptions(
+		EXAMPLE_ALIGNMENT_PROFILE, []ap.Gene{"A"}, Options{MinQuality: 20})
+	weighting, _ := NewWithOptions(
+		EXAMPLE_ALIGNMENT_PROFILE, []ap.Gene{"A"}, Options{QualityWeighting: true})
+	tooLong := append(exampleQuality(10, 4), 40, 40, 40)
+	tooShort := exampleQuality(10, 4)[:2]
+	for _, aln := range []*Aligner{masking, weighting} {
+		for _, quality := range [][]int{tooLong, tooShort} {
+			results, err := aln.AlignWithQuality(context.Background(), "seq", NSEQ, quality)
+			expect := &QualityLengthError{Bases: len(NSEQ), Qualities: len(quality)}
+			if !reflect.DeepEqual(err, expect) || results != nil {
+				t.Errorf(MSG_NOT_EQUAL, expect, err)
+			}
+		}
+	}
+}
+
+func TestAlignQualityWeighting(t *testing.T) {
+	plain, _ := New(EXAMPLE_ALIGNMENT_PROFILE, []ap.Gene{"A"})
+	weighted, _ := NewWithOptions(
+		EXAMPLE_ALIGNMENT_PROFILE, []ap.Gene{"A"},
+		Options{QualityWeighting: true})
+	quality := exampleQuality(3, 1, 2, 3, 4, 5, 6, 26)
+	plainResults, _ := plain.AlignWithQuality(context.Background(), "seq", NSEQ, quality)
+	weightedResults, _ := weighted.AlignWithQuality(context.Background(), "seq", NSEQ, quality)
+	plainReport, weightedReport := plainResults[0].Report, weightedResults[0].Report
+	if weightedReport.Score >= plainReport.Score {
+		t.Errorf(
+			"Expected low-quality codons to lower the score, got %v >= %v",
+			weightedReport.Score, plainReport.Score)
+	}
+	for _, mut := range plainReport.Mutations {
+		if mut.CodonQuality != nil {
+			t.Errorf("Expected no codon quality without weighting, got %#v", mut)
+		}
+	}
+	for _, mut := range weightedReport.Mutations {
+		expect := 40
+		if mut.Position == 9 {
+			expect = 3
+		}
+		if mut.CodonQuality == nil || *mut.CodonQuality != expect {
+			t.Errorf("Expected codon quality %v, got %#v", expect, mut)
+		}
+	}
+}
+
+func TestDetectGenesWithQualityWeighting(t *testing.T) {
+	aln, _ := NewWithOptions(
+		EXAMPLE_ALIGNMENT_PROFILE, []ap.Gene{"A"},
+		Options{DetectGenes: true, QualityWeighting: true})
+	quality := exampleQuality(3, 1, 2, 3, 4, 5, 6, 26)
+	results, _ := aln.AlignWithQuality(context.Background(), "seq", NSEQ, quality)
+	report := results[0].Report
+	if report == nil || results[0].Score != report.Score {
+		t.Errorf("Expected the screening score to be weighted like the alignment score, got %#v", results[0])
+	}
+}
+
+func TestGenotype(t *testing.T) {
+	other := EXAMPLE_ALIGNMENT_PROFILE
+	other.ReferenceSequences = ap.ReferenceSeqs{
+		"A": a.ReadString("TVLAGPTPANIIGKNLLSQ"),
+	}
+	unrelated := EXAMPLE_ALIGNMENT_PROFILE
+	unrelated.ReferenceSequences = ap.ReferenceSeqs{
+		"A": a.ReadString("GGGGGGGGGGGGGGGGGG"),
+	}
+	genotyper, err := NewGenotyper(
+		[]string{"unrelated", "other", "match"},
+		[]ap.AlignmentProfile{unrelated, other, EXAMPLE_ALIGNMENT_PROFILE},
+		"A", Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	results, profiles, err := genotyper.Genotype(context.Background(), "seq", NSEQ, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expect := []string{"match", "other", "unrelated"}
+	if !reflect.DeepEqual(profiles, expect) {
+		t.Errorf(MSG_NOT_EQUAL, expect, profiles)
+	}
+	if results[0].Report == nil || results[1].Report == nil ||
+		results[0].Report.NormalizedScore <= results[1].Report.NormalizedScore {
+		t.Errorf("Expected the profiles to be ranked by normalized score, got %#v", results)
+	}
+	if results[2].Err == nil {
+		t.Errorf("Expected the unrelated profile to fail, got %#v", results[2])
+	}
+
+	_, err = NewGenotyper(
+		[]string{"match", "other"},
+		[]ap.AlignmentProfile{EXAMPLE_ALIGNMENT_PROFILE, other},
+		"B", Options{})
+	if geneErr, ok := err.(*UnknownProfileGeneError); !ok || geneErr.Profile != "other" {
+		t.Errorf(MSG_NOT_EQUAL, &UnknownProfileGeneError{Profile: "other"}, err)
+	}
+}
+
+func TestGenotypeOffTarget(t *testing.T) {
+	hiv2a, _ := builtin.Get("hiv2a")
+	hiv2b, _ := builtin.Get("hiv2b")
+	genotyper, err := NewGenotyper(
+		[]string{"hiv2a", "hiv2b"},
+		[]ap.AlignmentProfile{*hiv2a, *hiv2b}, "POL", Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	results, _, err := genotyper.Genotype(
+		context.Background(), "seq", n.ReadString("ACGTACGTAC"), nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, result := range results {
+		if result.Report != nil || !errors.Is(result.Err, ErrGeneNotDetected) {
+			t.Errorf("Expected a short off-target sequence not to be detected, got %#v", result)
+		}
+	}
+}
+
+func TestScanRecombinant(t *testing.T) {
+	// the first twelve codons translate to the reference of "first",
+	// the last twelve to the reference of "second"
+	nas := n.ReadString(
+		"TGGTGTATGCATTGGTTTTATTGTATGCATTGGTTT" +
+			"AAAGATGAACGTAAAAATGATGAACAAAAACGTGAT")
+	first := EXAMPLE_ALIGNMENT_PROFILE
+	first.ReferenceSequences = ap.ReferenceSeqs{
+		"A": a.ReadString("WCMHWFYCMHWFPPAPGAPSGPAS"),
+	}
+	second := EXAMPLE_ALIGNMENT_PROFILE
+	second.ReferenceSequences = ap.ReferenceSeqs{
+		"A": a.ReadString("PPAPGAPSGPASKDERKNDEQKRD"),
+	}
+	genotyper, err := NewGenotyper(
+		[]string{"first", "second"},
+		[]ap.AlignmentProfile{first, second}, "A", Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	recombination, err := genotyper.Scan(
+		context.Background(), nas, ScanOptions{WindowSize: 18, WindowStep: 3})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(recombination.Breakpoints) != 1 {
+		t.Fatalf("Expected one breakpoint, got %#v", recombination.Breakpoints)
+	}
+	bp := recombination.Breakpoints[0]
+	if bp.From != "first" || bp.To != "second" || bp.Position < 31 || bp.Position > 43 {
+		t.Errorf("Expected a breakpoint from first to second near 37, got %#v", bp)
+	}
+	expect := []Segment{{"first", 1, bp.Position - 1}, {"second", bp.Position, len(nas)}}
+	if !reflect.DeepEqual(recombination.Segments, expect) {
+		t.Errorf(MSG_NOT_EQUAL, expect, recombination.Segments)
+	}
+
+	recombination, _ = genotyper.Scan(
+		context.Background(), nas[:36], ScanOptions{WindowSize: 18, WindowStep: 3})
+	if len(recombination.Breakpoints) != 0 || len(recombination.Segments) != 1 {
+		t.Errorf("Expected a single segment, got %#v", recombination.Segments)
+	}
+}
+
+func TestScanOptionsWithDefaults(t *testing.T) {
+	options := ScanOptions{}.withDefaults()
+	if *options.MinWindowMargin != DefaultMinWindowMargin {
+		t.Errorf(MSG_NOT_EQUAL, DefaultMinWindowMargin, *options.MinWindowMargin)
+	}
+	margin := 0.0
+	options = ScanOptions{MinWindowMargin: &margin}.withDefaults()
+	if *options.MinWindowMargin != 0 {
+		t.Errorf(MSG_NOT_EQUAL, 0.0, *options.MinWindowMargin)
+	}
+}
+
+func TestWindowStarts(t *testing.T) {
+	options := ScanOptions{WindowSize: 10, WindowStep: 4}
+	var cases = []struct {
+		length int
+		expect []int
+	}{
+		{0, nil},
+		{6, []int{0}},
+		{10, []int{0}},
+		{19, []int{0, 4, 8, 9}},
+		{20, []int{0, 4, 8, 10}},
+		{22, []int{0, 4, 8, 12}},
+	}
+	for _, c := range cases {
+		if starts := windowStarts(c.length, options); !reflect.DeepEqual(starts, c.expect) {
+			t.Errorf(MSG_NOT_EQUAL, c.expect, starts)
+		}
+	}
+}
+
+func TestAlignRegions(t *testing.T) {
+	profile := EXAMPLE_ALIGNMENT_PROFILE
+	profile.Regions = []ap.Region{
+		{Name: "A1", Gene: "A", Start: 1, End: 4},
+		{Name: "A2", Gene: "A", Start: 5, End: 19},
+	}
+	aln, err := New(profile, []ap.Gene{"A", "A2"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	results, _ := aln.Align(context.Background(), "seq", NSEQ)
+	gene, region := results[0].Report, results[1].Report
+	if gene == nil || region == nil {
+		t.Fatalf("Expected the gene and the region to align, got %#v", results)
+	}
+	expect := []alignment.RegionRange{
+		{Region: "A1", FirstAA: 1, LastAA: 4},
+		{Region: "A2", FirstAA: 1, LastAA: 15},
+	}
+	if !reflect.DeepEqual(gene.Regions, expect) {
+		t.Errorf(MSG_NOT_EQUAL, expect, gene.Regions)
+	}
+	if gene.FirstAA != 1 || gene.Mutations[0].Position != 9 ||
+		gene.Mutations[0].Region != "A2" || gene.Mutations[0].RegionPosition != 5 {
+		t.Errorf("Expected gene positions annotated with region positions, got %#v", gene)
+	}
+	if region.FirstAA != 5 || region.FirstNA != 13 || region.LastNA != 57 ||
+		!reflect.DeepEqual(region.Regions, expect[1:]) ||
+		region.CorrectedAminoAcids != "GPTPANIIGRNLLTQ" || region.AmbiguousCodons != 1 {
+		t.Errorf("Expected the report restricted to region A2, got %#v", region)
+	}
+
+	results, _ = aln.Align(context.Background(), "seq", NSEQ[:9])
+	if !errors.Is(results[1].Err, alignment.ErrNoOverlap) {
+		t.Errorf("Expected a sequence outside of A2 not to align to it, got %#v", results[1])
+	}
+}
+
+func TestAlignNucleotideReference(t *testing.T) {
+	profile := EXAMPLE_ALIGNMENT_PROFILE
+	profile.NucleotideReferenceSequences = ap.NucleotideReferenceSeqs{
+		"A": n.ReadString("ACAGTATTAGTAGGACCTACACCTGTCAACATAATCGGACGAAATCTGCTGACCCAG"),
+	}
+	profile.Regions = []ap.Region{{Name: "A2", Gene: "A", Start: 5, End: 19}}
+	aln, err := New(profile, []ap.Gene{"A", "A2"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	results, _ := aln.Align(context.Background(), "seq", NSEQ)
+	gene, region := results[0].Report, results[1].Report
+	if gene == nil || region == nil {
+		t.Fatalf("Expected the gene and the region to align, got %#v", results)
+	}
+	if len(gene.CodonDifferences) != 19 {
+		t.Fatalf("Expected a codon difference for each site, got %#v", gene.CodonDifferences)
+	}
+	expect := alignment.CodonDifference{
+		PosAA: 9, PosNA: 25, RefPosNA: 25, ReferenceCodon: "GTC",
+		CodonText: "GCC", Differences: 1, IsSynonymous: false,
+	}
+	if gene.CodonDifferences[8] != expect {
+		t.Errorf(MSG_NOT_EQUAL, expect, gene.CodonDifferences[8])
+	}
+	positions := func(diffs []alignment.CodonDifference) []int {
+		result := make([]int, len(diffs))
+		for i, diff := range diffs {
+			result[i] = diff.PosAA
+		}
+		return result
+	}
+	if result := positions(gene.SynonymousChanges); !reflect.DeepEqual(result, []int{2, 12, 14, 17, 18}) {
+		t.Errorf("Unexpected synonymous changes: %#v", gene.SynonymousChanges)
+	}
+	if result := positions(region.SynonymousChanges); !reflect.DeepEqual(result, []int{12, 14, 17, 18}) {
+		t.Errorf("Unexpected synonymous changes of region A2: %#v", region.SynonymousChanges)
+	}
+	if change := gene.SynonymousChanges[0]; change.ToString() != "2:GTA>GTR" {
+		t.Errorf("Unexpected synonymous change: %v", change.ToString())
+	}
+}
+
+func TestAlignGenome(t *testing.T) {
+	profile := EXAMPLE_ALIGNMENT_PROFILE
+	profile.Genome = &ap.Genome{Name: "G1", GeneStarts: map[ap.Gene]int{"A": 101}}
+	profile.Regions = []ap.Region{{Name: "A2", Gene: "A", Start: 5, End: 19}}
+	aln, err := New(profile, []ap.Gene{"A", "A2"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	results, _ := aln.Align(context.Background(), "seq", NSEQ)
+	gene, region := results[0].Report, results[1].Report
+	if gene == nil || region == nil {
+		t.Fatalf("Expected the gene and the region to align, got %#v", results)
+	}
+	if gene.GenomeFirstNA != 101 || gene.GenomeLastNA != 157 ||
+		gene.Mutations[0].GenomePosition != 125 || gene.AlignedSites[1].GenomePosition != 104 {
+		t.Errorf("Expected the genome positions of the gene, got %#v", gene)
+	}
+	if region.GenomeFirstNA != 113 || region.GenomeLastNA != 157 {
+		t.Errorf("Expected the genome positions of region A2, got %#v", region)
+	}
+}
+
+func TestAlignGenomeMode(t *testing.T) {
+	profile := EXAMPLE_ALIGNMENT_PROFILE
+	profile.ReferenceSequences = ap.ReferenceSeqs{
+		"A": profile.ReferenceSequences["A"],
+		"B": profile.ReferenceSequences["B"],
+		"C": profile.ReferenceSequences["A"],
+	}
+	if _, err := NewWithOptions(profile, []ap.Gene{"A"}, Options{GenomeMode: true}); err != ErrNoGenomeOrder {
+		t.Errorf(MSG_NOT_EQUAL, ErrNoGenomeOrder, err)
+	}
+	profile.Genome = &ap.Genome{Name: "G1", Order: []ap.Gene{"A", "C"}}
+	aln, err := NewWithOptions(profile, []ap.Gene{"A", "B", "C"}, Options{GenomeMode: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	seqs := make(chan fastareader.Sequence, 1)
+	seqs <- fastareader.Sequence{Name: "seq", Sequence: append(n.ReadString("TTTTTT"), NSEQ...)}
+	close(seqs)
+	result := <-aln.AlignStream(context.Background(), seqs, 1)
+	if result.Err != nil {
+		t.Fatalf("Unexpected error: %v", result.Err)
+	}
+	genes := result.Genes
+	if genes[0].Report == nil {
+		t.Errorf("Expected gene A to be placed, got %#v", genes[0])
+	}
+	if alnErr, ok := genes[1].Err.(*AlignmentError); !ok || alnErr.Err != alignment.ErrNoOverlap {
+		t.Errorf(MSG_NOT_EQUAL, &AlignmentError{"B", alignment.ErrNoOverlap}, genes[1].Err)
+	}
+	if alnErr, ok := genes[2].Err.(*AlignmentError); !ok || alnErr.Err != ErrConflictingPlacement {
+		t.Errorf(MSG_NOT_EQUAL, &AlignmentError{"C", ErrConflictingPlacement}, genes[2].Err)
+	}
+	expected := &GenomePlacement{
+		Placements:        []Placement{{Gene: "A", FirstNA: 7, LastNA: 63}},
+		InterGenicRegions: []InterGenicRegion{{Before: "A", FirstNA: 1, LastNA: 6}},
+		UnplacedGenes:     []ap.Gene{"C"},
+	}
+	if !reflect.DeepEqual(result.Genome, expected) {
+		t.Errorf(MSG_NOT_EQUAL, expected, result.Genome)
+	}
+}
+
+func TestResolvePlacements(t *testing.T) {
+	profile := EXAMPLE_ALIGNMENT_PROFILE
+	profile.Genome = &ap.Genome{
+		Name:     "G1",
+		Order:    []ap.Gene{"A", "B"},
+		Overlaps: []ap.GeneOverlap{{Genes: []ap.Gene{"A", "B"}, MaxLength: 10}},
+	}
+	aln, _ := NewWithOptions(profile, []ap.Gene{"A", "B"}, Options{GenomeMode: true})
+	placed := func(gene ap.Gene, first, last int, score float64) GeneResult {
+		report := &alignment.AlignmentReport{FirstNA: first, LastNA: last, Score: score}
+		return GeneResult{Gene: gene, Report: report, Score: score}
+	}
+	var cases = []struct {
+		a, b     GeneResult
+		expected []bool
+	}{
+		// B overlapping the end of A by no more than allowed
+		{placed("A", 1, 60, 50), placed("B", 51, 120, 40), []bool{true, true}},
+		// B overlapping A too much keeps the better scoring gene
+		{placed("A", 1, 60, 50), placed("B", 41, 120, 40), []bool{true, false}},
+		{placed("A", 1, 60, 30), placed("B", 41, 120, 40), []bool{false, true}},
+		// B placed before A
+		{placed("A", 61, 120, 50), placed("B", 1, 60, 40), []bool{true, false}},
+	}
+	for _, c := range cases {
+		results := []GeneResult{c.a, c.b}
+		aln.resolvePlacements(context.Background(), results, strand{}, strand{}, nil)
+		for i, result := range results {
+			if (result.Report != nil) != c.expected[i] {
+				t.Errorf("Expected %v to be placed: %v, got %v", result.Gene, c.expected[i], result.Err)
+			}
+		}
+		genome := aln.genomePlacement(results, 150)
+		last := genome.InterGenicRegions[len(genome.InterGenicRegions)-1]
+		if last.Before != "" || last.LastNA != 150 {
+			t.Errorf("Expected a region after the last gene, got %#v", genome.InterGenicRegions)
+		}
+	}
+}
+
+func TestResolvePlacementsSearchesAllSubsets(t *testing.T) {
+	profile := EXAMPLE_ALIGNMENT_PROFILE
+	profile.Genome = &ap.Genome{
+		Name:  "G1",
+		Order: []ap.Gene{"A", "B", "C"},
+		Overlaps: []ap.GeneOverlap{
+			{Genes: []ap.Gene{"A", "B"}, MaxLength: 10},
+			{Genes: []ap.Gene{"B", "C"}, MaxLength: 70},
+		},
+	}
+	aln, _ := NewWithOptions(profile, []ap.Gene{"A", "B"}, Options{GenomeMode: true})
+	placed := func(gene ap.Gene, first, last int, score float64) GeneResult {
+		report := &alignment.AlignmentReport{FirstNA: first, LastNA: last, Score: score}
+		return GeneResult{Gene: gene, Report: report, Score: score}
+	}
+	// A and B as well as B and C are compatible, but A and C aren't;
+	// B and C together score higher than C alone
+	results := []GeneResult{
+		placed("A", 1, 60, 10), placed("B", 51, 120, 10), placed("C", 55, 200, 100),
+	}
+	aln.resolvePlacements(context.Background(), results, strand{}, strand{}, nil)
+	expected := []bool{false, true, true}
+	for i, result := range results {
+		if (result.Report != nil) != expected[i] {
+			t.Errorf("Expected %v to be placed: %v, got %v", result.Gene, expected[i], result.Err)
+		}
+	}
+}
+
+func TestAlignGenomeModeRealignsRejectedGenes(t *testing.T) {
+	profile := EXAMPLE_ALIGNMENT_PROFILE
+	profile.ReferenceSequences = ap.ReferenceSeqs{
+		"A": profile.ReferenceSequences["A"],
+		"C": profile.ReferenceSequences["A"],
+	}
+	profile.Genome = &ap.Genome{Name: "G1", Order: []ap.Gene{"A", "C"}}
+	aln, err := NewWithOptions(profile, []ap.Gene{"A", "C"}, Options{GenomeMode: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// both genes align best against the first copy, which A keeps
+	exact := n.ReadString("ACAGTATTAGTAGGACCTACACCTGTCAACATAATTGGAAGAAATCTGTTGACCCAG")
+	results, err := aln.Align(context.Background(), "seq", append(exact, NSEQ...))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	first, second := results[0].Report, results[1].Report
+	if first == nil || first.FirstNA != 1 || first.LastNA != 57 {
+		t.Fatalf("Expected gene A to be placed at 1-57, got %#v", results[0])
+	}
+	if second == nil || second.FirstNA != 58 || second.LastNA != 114 {
+		t.Fatalf("Expected gene C to be placed at 58-114, got %#v", results[1])
+	}
+	if second.Mutations[0].NAPosition != 82 || second.AlignedSites[0].PosNA != 58 {
+		t.Errorf("Expected the positions of gene C to refer to the sequence, got %#v", second)
+	}
+}
@@ -0,0 +1,89 @@
+package aligner
+
+import (
+	"errors"
+	"fmt"
+	ap "github.com/hivdb/nucamino/alignmentprofile"
+)
+
+// Reported for genes a sequence didn't score high enough on when
+// genes are detected automatically.
+var ErrGeneNotDetected = errors.New("gene not detected")
+
+// Reported in genome mode for genes whose alignment conflicts with
+// the placement of other genes, such as a gene aligned before the
+// gene that precedes it in the genome.
+var ErrConflictingPlacement = errors.New("conflicts with the placement of other genes")
+
+// Returned by New in genome mode if the profile doesn't declare the
+// order of its genes in a genome.
+var ErrNoGenomeOrder = errors.New("the profile doesn't declare the order of its genes in a genome")
+
+// Returned by New when a requested gene isn't part of the profile.
+type UnknownGeneError struct {
+	Gene      ap.Gene
+	Available []ap.Gene
+}
+
+func (e *UnknownGeneError) Error() string {
+	return fmt.Sprintf(
+		"%v is not an available gene in the profile (available genes: %v)",
+		e.Gene, e.Available)
+}
+
+// Returned by New when no gene was requested.
+type NoGenesError struct{}
+
+func (e *NoGenesError) Error() string {
+	return "at least one gene is required"
+}
+
+// Returned when a sequence is given with a number of base qualities
+// that differs from its number of bases.
+type QualityLengthError struct {
+	Bases     int
+	Qualities int
+}
+
+func (e *QualityLengthError) Error() string {
+	return fmt.Sprintf(
+		"the sequence has %d bases but %d qualities", e.Bases, e.Qualities)
+}
+
+// Returned by NewGenotyper when no profile was given.
+type NoProfilesError struct{}
+
+func (e *NoProfilesError) Error() string {
+	return "at least one profile is required"
+}
+
+// Returned by NewGenotyper when the gene isn't part of one of the
+// profiles.
+type UnknownProfileGeneError struct {
+	Profile string
+	*UnknownGeneError
+}
+
+func (e *UnknownProfileGeneError) Error() string {
+	return fmt.Sprintf(
+		"%v is not an available gene in the profile %v (available genes: %v)",
+		e.Gene, e.Profile, e.Available)
+}
+
+// Wraps the error that prevented a sequence from being aligned
+// against a gene. The underlying error is ErrGeneNotDetected,
+// ErrConflictingPlacement or one of the errors defined by the
+// alignment package, such as alignment.ErrNoOverlap or
+// *alignment.RejectedError.
+type AlignmentError struct {
+	Gene ap.Gene
+	Err  error
+}
+
+func (e *AlignmentError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Gene, e.Err)
+}
+
+func (e *AlignmentError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,282 @@
+package aligner
+
+import (
+	"context"
+	"fmt"
+	"github.com/hivdb/nucamino/alignment"
+	ap "github.com/hivdb/nucamino/alignmentprofile"
+	h "github.com/hivdb/nucamino/scorehandler/general"
+	"math"
+	"sort"
+)
+
+// Where a gene was placed on a sequence. The positions refer to the
+// aligned strand.
+type Placement struct {
+	Gene                ap.Gene
+	FirstNA             int
+	LastNA              int
+	IsReverseComplement bool
+}
+
+func (p Placement) ToString() string {
+	return fmt.Sprintf("%s:%d-%d", p.Gene, p.FirstNA, p.LastNA)
+}
+
+// A part of a sequence between two placed genes, or before the first
+// or after the last of them. After and Before are the neighbouring
+// genes; After is empty for the part before the first gene, and
+// Before for the part after the last gene.
+type InterGenicRegion struct {
+	After   ap.Gene
+	Before  ap.Gene
+	FirstNA int
+	LastNA  int
+}
+
+func (r InterGenicRegion) ToString() string {
+	after, before := string(r.After), string(r.Before)
+	if after == "" {
+		after = "5'"
+	}
+	if before == "" {
+		before = "3'"
+	}
+	return fmt.Sprintf("%s>%s:%d-%d", after, before, r.FirstNA, r.LastNA)
+}
+
+// The genes placed on a sequence in genome mode, ordered by their
+// position on it, the inter-genic regions between them, and the
+// genes of the genome order that couldn't be placed.
+type GenomePlacement struct {
+	Placements        []Placement
+	InterGenicRegions []InterGenicRegion
+	UnplacedGenes     []ap.Gene
+}
+
+// The position of each gene of the genome order of the profile
+func (self *Aligner) genomeOrder() map[ap.Gene]int {
+	order := make(map[ap.Gene]int)
+	if self.profile.Genome != nil {
+		for i, gene := range self.profile.Genome.Order {
+			order[gene] = i
+		}
+	}
+	return order
+}
+
+// Whether gene x, which precedes gene y in the genome order, can be
+// placed where results x and y were aligned: on the same strand, with
+// y starting after x, and sharing no more bases than the genome
+// allows.
+func (self *Aligner) compatible(x, y *GeneResult) bool {
+	rx, ry := x.Report, y.Report
+	if rx.IsReverseComplement != ry.IsReverseComplement || ry.FirstNA <= rx.FirstNA {
+		return false
+	}
+	return rx.LastNA-ry.FirstNA+1 <= self.profile.Genome.MaxOverlap(x.Gene, y.Gene)
+}
+
+// Keep the set of aligned genes of the genome order with the highest
+// total score whose placements are compatible with each other. Each
+// of the other genes is aligned again within the part of the sequence
+// that its placed neighbours leave to it, and is rejected with
+// ErrConflictingPlacement if that doesn't place it either. Genes that
+// aren't part of the genome order aren't constrained.
+func (self *Aligner) resolvePlacements(
+	ctx context.Context, results []GeneResult, fwd strand, rc strand,
+	handlers []*h.GeneralScoreHandler) {
+	order := self.genomeOrder()
+	candidates := make([]int, 0, len(results))
+	for i := range results {
+		if _, ordered := order[results[i].Gene]; ordered && results[i].Report != nil {
+			candidates = append(candidates, i)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return order[results[candidates[i]].Gene] < order[results[candidates[j]].Gene]
+	})
+
+	isPlaced := self.bestPlacements(results, candidates)
+	for _, idx := range candidates {
+		if isPlaced[idx] {
+			continue
+		}
+		placed := make([]int, 0, len(candidates))
+		for _, other := range candidates {
+			if isPlaced[other] {
+				placed = append(placed, other)
+			}
+		}
+		if result, ok := self.placeBetween(ctx, idx, results, placed, fwd, rc, handlers); ok {
+			results[idx] = result
+			isPlaced[idx] = true
+			continue
+		}
+		results[idx].Report = nil
+		results[idx].Err = &AlignmentError{results[idx].Gene, ErrConflictingPlacement}
+	}
+}
+
+// The candidates (indexes of results, in genome order) with the
+// highest total score whose placements are all compatible with each
+// other. Every subset is searched, skipping those that can't beat
+// the best one found so far; genomes have few enough genes for this
+// to be cheap.
+func (self *Aligner) bestPlacements(results []GeneResult, candidates []int) map[int]bool {
+	// remaining[k] is the most the candidates from k on can add
+	remaining := make([]float64, len(candidates)+1)
+	for k := len(candidates) - 1; k >= 0; k-- {
+		remaining[k] = remaining[k+1] + math.Max(results[candidates[k]].Report.Score, 0)
+	}
+	var (
+		chosen, best []int
+		bestScore    float64
+		search       func(k int, score float64)
+	)
+	search = func(k int, score float64) {
+		if len(best) > 0 && score+remaining[k] <= bestScore {
+			return
+		}
+		if k == len(candidates) {
+			if len(chosen) > 0 {
+				best, bestScore = append(best[:0], chosen...), score
+			}
+			return
+		}
+		idx := candidates[k]
+		fits := true
+		for _, other := range chosen {
+			fits = fits && self.compatible(&results[other], &results[idx])
+		}
+		if fits {
+			chosen = append(chosen, idx)
+			search(k+1, score+results[idx].Report.Score)
+			chosen = chosen[:len(chosen)-1]
+		}
+		search(k+1, score)
+	}
+	search(0, 0)
+
+	isPlaced := make(map[int]bool)
+	for _, idx := range best {
+		isPlaced[idx] = true
+	}
+	return isPlaced
+}
+
+// Align the gene of results[idx] again within the part of the strand
+// of the placed genes (indexes of results, in genome order) between
+// its placed neighbours, overlapping them no more than the genome
+// allows. The new result is returned if the gene passes the
+// thresholds there and is compatible with every placed gene.
+func (self *Aligner) placeBetween(
+	ctx context.Context, idx int, results []GeneResult, placed []int,
+	fwd strand, rc strand, handlers []*h.GeneralScoreHandler) (GeneResult, bool) {
+	if len(placed) == 0 {
+		return GeneResult{}, false
+	}
+	order := self.genomeOrder()
+	gene := results[idx].Gene
+	var before, after *GeneResult
+	for _, other := range placed {
+		if order[results[other].Gene] < order[gene] {
+			before = &results[other]
+		} else if after == nil {
+			after = &results[other]
+		}
+	}
+	s, isReverseComplement := fwd, false
+	if results[placed[0]].Report.IsReverseComplement {
+		s, isReverseComplement = rc, true
+	}
+	first, last := 1, len(s.nas)
+	if before != nil {
+		first = before.Report.LastNA - self.profile.Genome.MaxOverlap(before.Gene, gene) + 1
+		if first <= before.Report.FirstNA {
+			first = before.Report.FirstNA + 1
+		}
+	}
+	if after != nil {
+		if end := after.Report.FirstNA + self.profile.Genome.MaxOverlap(gene, after.Gene) - 1; end < last {
+			last = end
+		}
+	}
+	if first < 1 || first > last {
+		return GeneResult{}, false
+	}
+	aligned, err := alignment.NewAlignmentInWindow(
+		ctx, s.nas, s.quality, self.refs[idx], handlers[idx], first, last)
+	if err != nil {
+		return GeneResult{}, false
+	}
+	result := self.makeGeneResult(idx, geneAlignment{
+		report: self.annotatedReport(idx, aligned, s.nas, isReverseComplement),
+		nas:    s.nas,
+		score:  results[idx].Score,
+	})
+	if result.Report == nil {
+		return GeneResult{}, false
+	}
+	for _, other := range placed {
+		x, y := &results[other], &result
+		if order[x.Gene] > order[gene] {
+			x, y = y, x
+		}
+		if !self.compatible(x, y) {
+			return GeneResult{}, false
+		}
+	}
+	return result, true
+}
+
+// Summarize the placement of the genes of the genome order on a
+// sequence of the given length, after resolvePlacements
+func (self *Aligner) genomePlacement(results []GeneResult, length int) *GenomePlacement {
+	order := self.genomeOrder()
+	genome := &GenomePlacement{
+		Placements:        make([]Placement, 0),
+		InterGenicRegions: make([]InterGenicRegion, 0),
+		UnplacedGenes:     make([]ap.Gene, 0),
+	}
+	aligned := make(map[ap.Gene]bool)
+	for _, result := range results {
+		if _, ordered := order[result.Gene]; !ordered || result.Report == nil {
+			continue
+		}
+		aligned[result.Gene] = true
+		genome.Placements = append(genome.Placements, Placement{
+			Gene:                result.Gene,
+			FirstNA:             result.Report.FirstNA,
+			LastNA:              result.Report.LastNA,
+			IsReverseComplement: result.Report.IsReverseComplement,
+		})
+	}
+	for _, gene := range self.profile.Genome.Order {
+		if !aligned[gene] {
+			genome.UnplacedGenes = append(genome.UnplacedGenes, gene)
+		}
+	}
+	sort.Slice(genome.Placements, func(i, j int) bool {
+		return genome.Placements[i].FirstNA < genome.Placements[j].FirstNA
+	})
+
+	end, after := 0, ap.Gene("")
+	for _, placement := range genome.Placements {
+		if placement.FirstNA > end+1 {
+			genome.InterGenicRegions = append(genome.InterGenicRegions, InterGenicRegion{
+				After: after, Before: placement.Gene,
+				FirstNA: end + 1, LastNA: placement.FirstNA - 1,
+			})
+		}
+		if placement.LastNA > end {
+			end, after = placement.LastNA, placement.Gene
+		}
+	}
+	if len(genome.Placements) > 0 && end < length {
+		genome.InterGenicRegions = append(genome.InterGenicRegions, InterGenicRegion{
+			After: after, FirstNA: end + 1, LastNA: length,
+		})
+	}
+	return genome
+}
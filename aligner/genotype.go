@@ -0,0 +1,134 @@
+package aligner
+
+import (
+	"context"
+	ap "github.com/hivdb/nucamino/alignmentprofile"
+	n "github.com/hivdb/nucamino/types/nucleic"
+	"github.com/hivdb/nucamino/utils/fastareader"
+	"sort"
+)
+
+// A Genotyper aligns sequences against the same gene of several
+// profiles, such as the profiles of the genotypes or subtypes of a
+// virus, and ranks the profiles by how well each sequence aligns to
+// them. Like an Aligner, it can be shared by any number of
+// goroutines.
+type Genotyper struct {
+	names    []string
+	aligners []*Aligner
+	minScore float64
+}
+
+// Create a Genotyper for gene of each of the profiles; names are the
+// names the profiles are reported by. Every profile must have the
+// gene. Alignments scoring less than options.MinDetectionScore (or
+// DefaultMinDetectionScore if it is zero) aren't ranked.
+func NewGenotyper(
+	names []string, profiles []ap.AlignmentProfile,
+	gene ap.Gene, options Options) (*Genotyper, error) {
+	if len(profiles) == 0 {
+		return nil, &NoProfilesError{}
+	}
+	aligners := make([]*Aligner, len(profiles))
+	for i, profile := range profiles {
+		aligner, err := NewWithOptions(profile, []ap.Gene{gene}, options)
+		if err != nil {
+			if geneErr, ok := err.(*UnknownGeneError); ok {
+				return nil, &UnknownProfileGeneError{names[i], geneErr}
+			}
+			return nil, err
+		}
+		aligners[i] = aligner
+	}
+	minScore := options.MinDetectionScore
+	if minScore == 0 {
+		minScore = DefaultMinDetectionScore
+	}
+	return &Genotyper{append([]string(nil), names...), aligners, minScore}, nil
+}
+
+// The names of the candidate profiles
+func (self *Genotyper) Profiles() []string {
+	return append([]string(nil), self.names...)
+}
+
+// Align a sequence against every candidate profile. The results are
+// ranked from the best to the worst normalized score, and the name
+// of the profile of each result is returned alongside. Profiles the
+// sequence couldn't be aligned to come last, as do those it scores
+// less than the minimum detection score on, which are reported with
+// ErrGeneNotDetected: otherwise a short or off-target sequence would
+// still be assigned a best profile. An error is only
+// returned if ctx is done before all profiles were tried, or if
+// quality doesn't have one value per base.
+func (self *Genotyper) Genotype(
+	ctx context.Context, name string,
+	nas []n.NucleicAcid, quality []int) ([]GeneResult, []string, error) {
+	masked, err := self.aligners[0].maskLowQuality(nas, quality)
+	if err != nil {
+		return nil, nil, err
+	}
+	return self.genotypeMasked(ctx, masked, quality)
+}
+
+func (self *Genotyper) genotypeMasked(
+	ctx context.Context,
+	nas []n.NucleicAcid, quality []int) ([]GeneResult, []string, error) {
+	results := make([]GeneResult, len(self.aligners))
+	for i, aligner := range self.aligners {
+		geneResults, err := aligner.alignMasked(ctx, nas, quality)
+		if err != nil {
+			return nil, nil, err
+		}
+		results[i] = geneResults[0]
+		if report := results[i].Report; report != nil && report.Score < self.minScore {
+			results[i].Report = nil
+			results[i].Err = &AlignmentError{results[i].Gene, ErrGeneNotDetected}
+		}
+	}
+	order := make([]int, len(results))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		r1, r2 := results[order[i]], results[order[j]]
+		if r1.Report == nil || r2.Report == nil {
+			return r2.Report == nil && r1.Report != nil
+		}
+		return r1.Report.NormalizedScore > r2.Report.NormalizedScore
+	})
+	ranked := make([]GeneResult, len(results))
+	names := make([]string, len(results))
+	for i, idx := range order {
+		ranked[i] = results[idx]
+		names[i] = self.names[idx]
+	}
+	return ranked, names, nil
+}
+
+// Genotype every sequence received from seqs, like
+// Aligner.AlignStream aligns them. The Genes of each Result are the
+// ranked results of Genotype, and Profiles are the names of their
+// profiles.
+func (self *Genotyper) GenotypeStream(
+	ctx context.Context, seqs <-chan fastareader.Sequence,
+	goroutines int) <-chan Result {
+	return alignStream(ctx, seqs, goroutines, func(seq indexedSequence) Result {
+		return self.genotypeSequence(ctx, seq)
+	})
+}
+
+func (self *Genotyper) genotypeSequence(ctx context.Context, seq indexedSequence) Result {
+	nas, err := self.aligners[0].maskLowQuality(seq.Sequence.Sequence, seq.Quality)
+	var (
+		genes    []GeneResult
+		profiles []string
+	)
+	if err == nil {
+		genes, profiles, err = self.genotypeMasked(ctx, nas, seq.Quality)
+	}
+	return Result{
+		Index: seq.index, Name: seq.Name, Sequence: nas,
+		Diagnostics: seq.Diagnostics, Genes: genes, Err: err, Profiles: profiles,
+	}
+}
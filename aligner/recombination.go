@@ -0,0 +1,202 @@
+package aligner
+
+import (
+	"context"
+	"fmt"
+	"github.com/hivdb/nucamino/alignment"
+	h "github.com/hivdb/nucamino/scorehandler/general"
+	n "github.com/hivdb/nucamino/types/nucleic"
+	"github.com/hivdb/nucamino/utils/fastareader"
+)
+
+// The defaults for the unset fields of ScanOptions. A window of 100
+// codons is long enough for the scores of related genotypes to be
+// told apart, and short enough to locate a breakpoint within about
+// 50 codons.
+const (
+	DefaultWindowSize      = 300
+	DefaultWindowStep      = 30
+	DefaultMinWindowMargin = 0.5
+)
+
+// How Scan divides a sequence into windows. WindowSize and
+// WindowStep are in bases; zero means the default. A window is only
+// assigned to the profile with the best score if that score exceeds
+// the second best by at least MinWindowMargin (in score per codon),
+// or DefaultMinWindowMargin if it is nil.
+type ScanOptions struct {
+	WindowSize      int
+	WindowStep      int
+	MinWindowMargin *float64
+}
+
+func (options ScanOptions) withDefaults() ScanOptions {
+	if options.WindowSize <= 0 {
+		options.WindowSize = DefaultWindowSize
+	}
+	if options.WindowStep <= 0 {
+		options.WindowStep = DefaultWindowStep
+	}
+	if options.MinWindowMargin == nil {
+		margin := DefaultMinWindowMargin
+		options.MinWindowMargin = &margin
+	}
+	return options
+}
+
+// A window of a sequence. Scores are the scores per codon of the
+// window against each profile, in the order of Genotyper.Profiles.
+// Best is the profile the window was assigned to, or empty if no
+// profile scored clearly better than the others.
+type Window struct {
+	FirstNA int
+	LastNA  int
+	Scores  []float64
+	Best    string
+}
+
+// A part of a sequence whose windows were assigned to the same
+// profile
+type Segment struct {
+	Profile string
+	FirstNA int
+	LastNA  int
+}
+
+func (s Segment) ToString() string {
+	return fmt.Sprintf("%s:%d-%d", s.Profile, s.FirstNA, s.LastNA)
+}
+
+// A position where the profile a sequence matches best changes.
+// Position is the first base of the segment of To.
+type Breakpoint struct {
+	Position int
+	From     string
+	To       string
+}
+
+func (b Breakpoint) ToString() string {
+	return fmt.Sprintf("%d:%s>%s", b.Position, b.From, b.To)
+}
+
+// The outcome of scanning a sequence. A sequence with more than one
+// segment is likely a recombinant of the profiles of its segments.
+// All positions refer to the scanned strand.
+type Recombination struct {
+	Windows     []Window
+	Segments    []Segment
+	Breakpoints []Breakpoint
+}
+
+// Score overlapping windows of a sequence against the gene of every
+// candidate profile, and find the breakpoints where the best scoring
+// profile changes. Each window is scored with the cheap scoring pass
+// that alignment.CalcScore performs, so the windows can match any
+// part of the gene. An error is only returned if ctx is done before
+// all windows were scored.
+func (self *Genotyper) Scan(
+	ctx context.Context, nas []n.NucleicAcid,
+	options ScanOptions) (*Recombination, error) {
+	options = options.withDefaults()
+	handlers := make([]*h.GeneralScoreHandler, len(self.aligners))
+	for i, aligner := range self.aligners {
+		borrowed := aligner.handlers.Get().([]*h.GeneralScoreHandler)
+		defer aligner.handlers.Put(borrowed)
+		handlers[i] = borrowed[0]
+	}
+	windows := make([]Window, 0)
+	for _, start := range windowStarts(len(nas), options) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		end := start + options.WindowSize
+		if end > len(nas) {
+			end = len(nas)
+		}
+		window := Window{FirstNA: start + 1, LastNA: end}
+		window.Scores = make([]float64, len(self.aligners))
+		codons := float64(end-start) / 3
+		best, second := -1, -1
+		for i, aligner := range self.aligners {
+			score := alignment.CalcScore(nas[start:end], aligner.refs[0], handlers[i])
+			window.Scores[i] = score / codons
+			if best < 0 || window.Scores[i] > window.Scores[best] {
+				best, second = i, best
+			} else if second < 0 || window.Scores[i] > window.Scores[second] {
+				second = i
+			}
+		}
+		if window.Scores[best] > 0 && (second < 0 ||
+			window.Scores[best]-window.Scores[second] >= *options.MinWindowMargin) {
+			window.Best = self.names[best]
+		}
+		windows = append(windows, window)
+	}
+	segments, breakpoints := findSegments(windows, len(nas))
+	return &Recombination{windows, segments, breakpoints}, nil
+}
+
+// The start of each window, counting from 0. The last window ends
+// at the end of the sequence; sequences shorter than a window are a
+// single window.
+func windowStarts(length int, options ScanOptions) []int {
+	if length == 0 {
+		return nil
+	}
+	starts := []int{0}
+	for start := options.WindowStep; start+options.WindowSize <= length; start += options.WindowStep {
+		starts = append(starts, start)
+	}
+	if last := length - options.WindowSize; last > starts[len(starts)-1] {
+		starts = append(starts, last)
+	}
+	return starts
+}
+
+// Merge consecutive windows assigned to the same profile into
+// segments. Windows that weren't assigned to any profile are
+// skipped; the breakpoint between two segments lies halfway between
+// the centers of their closest windows. The first and last segments
+// are extended to the ends of the sequence.
+func findSegments(windows []Window, length int) ([]Segment, []Breakpoint) {
+	segments := make([]Segment, 0)
+	breakpoints := make([]Breakpoint, 0)
+	var lastCenter int
+	for _, window := range windows {
+		if window.Best == "" {
+			continue
+		}
+		center := (window.FirstNA + window.LastNA) / 2
+		if len(segments) == 0 {
+			segments = append(segments, Segment{window.Best, 1, length})
+		} else if from := segments[len(segments)-1].Profile; from != window.Best {
+			position := (lastCenter+center)/2 + 1
+			segments[len(segments)-1].LastNA = position - 1
+			segments = append(segments, Segment{window.Best, position, length})
+			breakpoints = append(breakpoints, Breakpoint{position, from, window.Best})
+		}
+		lastCenter = center
+	}
+	return segments, breakpoints
+}
+
+// Genotype every sequence received from seqs like GenotypeStream
+// does, and scan it for recombination. The Recombination of each
+// Result is set unless no profile could be aligned. The strand of
+// the best alignment is scanned.
+func (self *Genotyper) ScanStream(
+	ctx context.Context, seqs <-chan fastareader.Sequence,
+	goroutines int, options ScanOptions) <-chan Result {
+	return alignStream(ctx, seqs, goroutines, func(seq indexedSequence) Result {
+		result := self.genotypeSequence(ctx, seq)
+		if result.Err != nil || len(result.Genes) == 0 || result.Genes[0].Report == nil {
+			return result
+		}
+		nas := result.Sequence
+		if result.Genes[0].Report.IsReverseComplement {
+			nas = n.ReverseComplement(nas)
+		}
+		result.Recombination, result.Err = self.Scan(ctx, nas, options)
+		return result
+	})
+}
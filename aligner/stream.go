@@ -0,0 +1,163 @@
+package aligner
+
+import (
+	"context"
+	n "github.com/hivdb/nucamino/types/nucleic"
+	"github.com/hivdb/nucamino/utils/fastareader"
+	"sync"
+)
+
+// The outcome of aligning one sequence of a stream. Index is the
+// position of the sequence in the input stream, starting from 0, and
+// Sequence is the aligned sequence itself, after masking its
+// low-quality bases. Diagnostics are passed on from the input
+// sequence. Err is only set if the context was done before the
+// sequence could be aligned, or if the sequence doesn't have one
+// quality per base (*QualityLengthError). Profiles is only set by a Genotyper: it
+// names the profile of each entry of Genes. Recombination is only
+// set by Genotyper.ScanStream, and Genome only in genome mode.
+type Result struct {
+	Index         int
+	Name          string
+	Sequence      []n.NucleicAcid
+	Diagnostics   fastareader.Diagnostics
+	Genes         []GeneResult
+	Err           error
+	Profiles      []string
+	Recombination *Recombination
+	Genome        *GenomePlacement
+}
+
+type indexedSequence struct {
+	index int
+	fastareader.Sequence
+}
+
+// Align every sequence received from seqs using the given number of
+// goroutines. The results are sent to the returned channel in input
+// order, as soon as each sequence and all of its predecessors are
+// aligned; the channel is closed after the last result. At most
+// four sequences per goroutine are held in memory at any time, so
+// inputs of any size can be aligned.
+//
+// The caller must drain the returned channel. If ctx is done, the
+// results that weren't received yet are dropped and the channel is
+// closed without waiting for seqs to be closed; the remaining
+// sequences of seqs are read and discarded in the background, so
+// that the goroutine sending them isn't blocked.
+func (self *Aligner) AlignStream(
+	ctx context.Context, seqs <-chan fastareader.Sequence,
+	goroutines int) <-chan Result {
+	return alignStream(ctx, seqs, goroutines, func(seq indexedSequence) Result {
+		nas, err := self.maskLowQuality(seq.Sequence.Sequence, seq.Quality)
+		var genes []GeneResult
+		if err == nil {
+			genes, err = self.alignMasked(ctx, nas, seq.Quality)
+		}
+		result := Result{
+			Index: seq.index, Name: seq.Name, Sequence: nas,
+			Diagnostics: seq.Diagnostics, Genes: genes, Err: err,
+		}
+		if err == nil && self.options.GenomeMode {
+			result.Genome = self.genomePlacement(genes, len(nas))
+		}
+		return result
+	})
+}
+
+// The implementation of AlignStream; align is called concurrently
+// for each sequence.
+func alignStream(
+	ctx context.Context, seqs <-chan fastareader.Sequence,
+	goroutines int, align func(seq indexedSequence) Result) <-chan Result {
+	if goroutines < 1 {
+		goroutines = 1
+	}
+	var (
+		wg         = sync.WaitGroup{}
+		maxPending = goroutines * 4
+		slots      = make(chan struct{}, maxPending)
+		seqChan    = make(chan indexedSequence, maxPending)
+		resultChan = make(chan Result, maxPending)
+		out        = make(chan Result)
+	)
+
+	go func() {
+		defer func() {
+			close(seqChan)
+			// drain seqs once ctx is done, so that its producer can
+			// finish, without holding up the results meanwhile
+			go func() {
+				for range seqs {
+				}
+			}()
+		}()
+		idx := 0
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case seq, ok := <-seqs:
+				if !ok {
+					return
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case slots <- struct{}{}:
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case seqChan <- indexedSequence{idx, seq}:
+				}
+				idx++
+			}
+		}
+	}()
+
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for seq := range seqChan {
+				result := align(seq)
+				select {
+				case <-ctx.Done():
+					// the results aren't sent anymore
+				case resultChan <- result:
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(resultChan)
+	}()
+
+	// Results arrive in whatever order the goroutines finish them;
+	// hold back the early ones until every preceding sequence was
+	// sent so that the output follows the input order.
+	go func() {
+		defer close(out)
+		pending := make(map[int]Result)
+		nextIdx := 0
+		for result := range resultChan {
+			pending[result.Index] = result
+			for {
+				next, found := pending[nextIdx]
+				if !found {
+					break
+				}
+				select {
+				case <-ctx.Done():
+				case out <- next:
+				}
+				delete(pending, nextIdx)
+				nextIdx++
+				<-slots
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,64 @@
+package aligner
+
+import (
+	"context"
+	ap "github.com/hivdb/nucamino/alignmentprofile"
+	"github.com/hivdb/nucamino/utils/fastareader"
+	"testing"
+	"time"
+)
+
+func TestAlignStreamCancelledDrainsInput(t *testing.T) {
+	aln, _ := New(EXAMPLE_ALIGNMENT_PROFILE, []ap.Gene{"A"})
+	ctx, cancel := context.WithCancel(context.Background())
+	seqs := make(chan fastareader.Sequence)
+	sent := make(chan int)
+	go func() {
+		count := 0
+		for ; count < 100; count++ {
+			if count == 2 {
+				cancel()
+			}
+			seqs <- fastareader.Sequence{Name: "seq", Sequence: NSEQ}
+		}
+		close(seqs)
+		sent <- count
+	}()
+	for range aln.AlignStream(ctx, seqs, 1) {
+	}
+	select {
+	case count := <-sent:
+		if count != 100 {
+			t.Errorf(MSG_NOT_EQUAL, 100, count)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("Expected the input to be drained after cancelling")
+	}
+}
+
+func TestAlignStreamCancelledWithOpenInput(t *testing.T) {
+	aln, _ := New(EXAMPLE_ALIGNMENT_PROFILE, []ap.Gene{"A"})
+	ctx, cancel := context.WithCancel(context.Background())
+	seqs := make(chan fastareader.Sequence)
+	defer close(seqs)
+	out := aln.AlignStream(ctx, seqs, 2)
+	seqs <- fastareader.Sequence{Name: "seq", Sequence: NSEQ}
+	cancel()
+	closed := make(chan struct{})
+	go func() {
+		for range out {
+		}
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Errorf("Expected the results to be closed while the input is still open")
+	}
+	// the input is still read after cancelling
+	select {
+	case seqs <- fastareader.Sequence{Name: "seq", Sequence: NSEQ}:
+	case <-time.After(time.Second):
+		t.Errorf("Expected the input to be drained after cancelling")
+	}
+}
@@ -1,9 +1,11 @@
 package alignment
 
 import (
-	"errors"
+	"context"
+	ap "github.com/hivdb/nucamino/alignmentprofile"
 	h "github.com/hivdb/nucamino/scorehandler/general"
 	a "github.com/hivdb/nucamino/types/amino"
+	c "github.com/hivdb/nucamino/types/codon"
 	f "github.com/hivdb/nucamino/types/frameshift"
 	m "github.com/hivdb/nucamino/types/mutation"
 	n "github.com/hivdb/nucamino/types/nucleic"
@@ -23,10 +25,18 @@ const (
 const negInf = -int((^uint(0))>>1) - 1
 const scoreTypeCount = 3
 
+// A score that is never chosen, but unlike negInf penalties can be
+// added to it without overflowing.
+const unreachable = negInf / 2
+
 type AlignedSite struct {
 	PosAA    int
 	PosNA    int
 	LengthNA int
+	// The position in the reference genome of the first base of the
+	// reference codon; only set if the profile places the gene in a
+	// genome.
+	GenomePosition int `json:",omitempty"`
 }
 
 type AlignmentReport struct {
@@ -41,10 +51,48 @@ type AlignmentReport struct {
 	ControlLine       string
 	NucleicAcidsLine  string
 	IsSimpleAlignment bool
+	// If set, the reverse complement of the input sequence was
+	// aligned, and all NA positions refer to the reverse complement.
+	IsReverseComplement bool
+	// The alignment score in the units of the alignment profile, and
+	// the same score divided by the number of aligned reference
+	// positions.
+	Score           float64
+	NormalizedScore float64
+	// Percentage of the complete codons that translate to the
+	// reference amino acid.
+	PercentIdentity float64
+	AmbiguousCodons int
+	StopCodons      int
+	PartialCodons   int
+	InsertionCodons int
+	DeletionCodons  int
+	// Fraction of the reference sequence covered by the alignment
+	Coverage float64
+	// The aligned part of the query with its frameshifts repaired,
+	// the translation of that sequence, and the edits that were made
+	// to repair the frameshifts.
+	CorrectedNucleicAcids string
+	CorrectedAminoAcids   string
+	FrameShiftEdits       []FrameShiftEdit
+	// The parts of the regions of the gene that the alignment covers;
+	// only set if the profile defines regions for the gene.
+	Regions []RegionRange `json:",omitempty"`
+	// The aligned codons compared with the nucleotide reference of
+	// the gene, and those of them that are synonymous changes; only
+	// set if the profile has a nucleotide reference for the gene.
+	CodonDifferences  []CodonDifference `json:",omitempty"`
+	SynonymousChanges []CodonDifference `json:",omitempty"`
+	// The genome positions of the first base of the codon at FirstAA
+	// and of the last base of the codon at LastAA; only set if the
+	// profile places the gene in a genome.
+	GenomeFirstNA int `json:",omitempty"`
+	GenomeLastNA  int `json:",omitempty"`
 }
 
 type Alignment struct {
 	nSeq                          []n.NucleicAcid
+	nQual                         []int
 	aSeq                          []a.AminoAcid
 	nSeqLen                       int
 	aSeqLen                       int
@@ -62,16 +110,20 @@ type Alignment struct {
 	constIndelCodonExtensionScore int
 	boundaryOnly                  bool
 	isSimpleAlignment             bool
+	mode                          ap.AlignmentMode
+	traceback                     *hirschbergTraceback
 	report                        *AlignmentReport
+	// The passes stop early once done is closed
+	done <-chan struct{}
 }
 
-func NewAlignment(nSeq []n.NucleicAcid, aSeq []a.AminoAcid, scoreHandler *h.GeneralScoreHandler) (*Alignment, error) {
+func newAlignment(nSeq []n.NucleicAcid, aSeq []a.AminoAcid, scoreHandler *h.GeneralScoreHandler) *Alignment {
 	nSeqLen := len(nSeq)
 	aSeqLen := len(aSeq)
 	supportPositionalIndel := scoreHandler.IsPositionalIndelScoreSupported()
 	constIndelCodonOpeningScore, constIndelCodonExtensionScore :=
 		scoreHandler.GetConstantIndelCodonScore()
-	result := &Alignment{
+	return &Alignment{
 		q:                             scoreHandler.GetGapOpeningScore(),
 		r:                             scoreHandler.GetGapExtensionScore(),
 		nSeq:                          nSeq,
@@ -83,18 +135,127 @@ func NewAlignment(nSeq []n.NucleicAcid, aSeq []a.AminoAcid, scoreHandler *h.Gene
 		supportPositionalIndel:        supportPositionalIndel,
 		constIndelCodonOpeningScore:   constIndelCodonOpeningScore,
 		constIndelCodonExtensionScore: constIndelCodonExtensionScore,
+		mode:                          scoreHandler.GetAlignmentMode(),
+	}
+}
+
+// Align nSeq to aSeq in the alignment mode of the score handler's
+// profile.
+func NewAlignment(nSeq []n.NucleicAcid, aSeq []a.AminoAcid, scoreHandler *h.GeneralScoreHandler) (*Alignment, error) {
+	return NewAlignmentWithMode(nSeq, aSeq, scoreHandler, scoreHandler.GetAlignmentMode())
+}
+
+// Align nSeq to aSeq, weighting the substitution score of each codon
+// by the Phred quality of its bases (nQual). The mutations of the
+// report are annotated with their codon quality.
+func NewAlignmentWithQuality(
+	nSeq []n.NucleicAcid, nQual []int, aSeq []a.AminoAcid,
+	scoreHandler *h.GeneralScoreHandler) (*Alignment, error) {
+	return NewAlignmentWithContext(context.Background(), nSeq, nQual, aSeq, scoreHandler)
+}
+
+// Align nSeq to aSeq like NewAlignmentWithQuality does (nQual may be
+// nil), giving up with ctx.Err() as soon as ctx is done, even in the
+// middle of the alignment.
+func NewAlignmentWithContext(
+	ctx context.Context, nSeq []n.NucleicAcid, nQual []int, aSeq []a.AminoAcid,
+	scoreHandler *h.GeneralScoreHandler) (*Alignment, error) {
+	return NewAlignmentInWindow(ctx, nSeq, nQual, aSeq, scoreHandler, 1, len(nSeq))
+}
+
+// Align the bases first to last of nSeq (counting from 1) to aSeq
+// like NewAlignmentWithContext does. The positions of the report
+// still refer to the whole of nSeq.
+func NewAlignmentInWindow(
+	ctx context.Context, nSeq []n.NucleicAcid, nQual []int, aSeq []a.AminoAcid,
+	scoreHandler *h.GeneralScoreHandler, first, last int) (*Alignment, error) {
+	nSeq = nSeq[first-1 : last]
+	if nQual != nil {
+		nQual = nQual[first-1 : last]
+	}
+	if err := checkInput(nSeq, aSeq); err != nil {
+		return nil, err
+	}
+	result := newAlignment(nSeq, aSeq, scoreHandler)
+	result.nQual = nQual
+	result.nSeqOffset = first - 1
+	result.done = ctx.Done()
+	ok := result.align()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNoOverlap
+	}
+	result.addScoreMetrics(len(aSeq))
+	return result, nil
+}
+
+// Align nSeq to aSeq in the given alignment mode, regardless of the
+// mode of the score handler's profile.
+func NewAlignmentWithMode(
+	nSeq []n.NucleicAcid, aSeq []a.AminoAcid,
+	scoreHandler *h.GeneralScoreHandler, mode ap.AlignmentMode) (*Alignment, error) {
+	if err := checkInput(nSeq, aSeq); err != nil {
+		return nil, err
 	}
+	result := newAlignment(nSeq, aSeq, scoreHandler)
+	result.mode = mode
 	ok := result.align()
 	if !ok {
-		return nil, errors.New("sequence misaligned")
+		return nil, ErrNoOverlap
 	}
+	result.addScoreMetrics(len(aSeq))
 	return result, nil
 }
 
+// Calculate the score of the best alignment between nSeq and aSeq,
+// in the units of the alignment profile. Only the boundary-only
+// forward pass is performed, so this is much cheaper than
+// NewAlignment; it's meant for deciding whether a sequence is worth
+// aligning at all.
+func CalcScore(nSeq []n.NucleicAcid, aSeq []a.AminoAcid, scoreHandler *h.GeneralScoreHandler) float64 {
+	return CalcScoreWithQuality(nSeq, nil, aSeq, scoreHandler)
+}
+
+// Calculate the score of the best alignment like CalcScore does,
+// weighting the substitution scores by the Phred quality of the bases
+// (nQual) like NewAlignmentWithQuality, so that the screening score
+// ranks sequences the same way as the alignment score. nQual may be
+// nil.
+func CalcScoreWithQuality(
+	nSeq []n.NucleicAcid, nQual []int, aSeq []a.AminoAcid,
+	scoreHandler *h.GeneralScoreHandler) float64 {
+	aln := newAlignment(nSeq, aSeq, scoreHandler)
+	aln.nQual = nQual
+	aln.boundaryOnly = true
+	_, _, maxScore, _ := aln.calcScoreMainForward()
+	return float64(maxScore) / float64(scoreHandler.GetScoreScale())
+}
+
 func (self *Alignment) GetReport() *AlignmentReport {
 	return self.report
 }
 
+// The score of the alignment; higher scores are better alignments
+func (self *Alignment) GetScore() int {
+	return self.maxScore
+}
+
+// The metrics which depend on the score and on the full length of
+// the reference; the codon counts are collected by generateReport.
+func (self *Alignment) addScoreMetrics(aSeqTotalLen int) {
+	report := self.report
+	alignedAAs := report.LastAA - report.FirstAA + 1
+	report.Score = float64(self.maxScore) / float64(self.scoreHandler.GetScoreScale())
+	if alignedAAs > 0 {
+		report.NormalizedScore = report.Score / float64(alignedAAs)
+	}
+	if aSeqTotalLen > 0 {
+		report.Coverage = float64(alignedAAs) / float64(aSeqTotalLen)
+	}
+}
+
 func (self *Alignment) generateReport() bool {
 	var (
 		nLine, aLine, cLine              string
@@ -102,6 +263,10 @@ func (self *Alignment) generateReport() bool {
 		mutList                          = make([]m.Mutation, 0, 10)
 		fsList                           = make([]f.FrameShift, 0, 3)
 		siteList                         = make([]AlignedSite, 0, 50)
+		completeCodons, identicalCodons  int
+		ambiguousCodons, stopCodons      int
+		partialCodons                    int
+		insertionCodons, deletionCodons  int
 		LastPosN                         = -1
 		LastPosA                         = -1
 		lastScoreType                    = GENERAL
@@ -118,7 +283,7 @@ func (self *Alignment) generateReport() bool {
 		if self.isSimpleAlignment {
 			endMtIdx = self.getMatrixIndex(GENERAL, posN-3, posA-1)
 		} else {
-			endMtIdx = self.nwMatrix[endMtIdx]
+			endMtIdx = self.getPrevMatrixIndex(endMtIdx)
 		}
 		if lastAA == 0 && lastNA == 0 {
 			if scoreType != GENERAL {
@@ -148,6 +313,10 @@ func (self *Alignment) generateReport() bool {
 				mutation = m.MakeMutation(
 					absPosA, absPosN,
 					self.nSeq[posN:LastPosN], self.aSeq[posA])
+				if mutation != nil && !mutation.IsDeletion && self.nQual != nil {
+					quality := self.getCodonQuality(posN+1, LastPosN)
+					mutation.CodonQuality = &quality
+				}
 				frameshift = f.MakeFrameShift(
 					absPosA, absPosN,
 					self.nSeq[posN:LastPosN])
@@ -172,6 +341,25 @@ func (self *Alignment) generateReport() bool {
 					PosNA:    absPosN,
 					LengthNA: lenNA,
 				})
+				if mutation != nil && mutation.IsDeletion {
+					deletionCodons++
+				} else if mutation != nil && mutation.IsPartial {
+					partialCodons++
+				} else if LastPosN-posN > 2 {
+					completeCodons++
+					codon := c.Codon{self.nSeq[posN], self.nSeq[posN+1], self.nSeq[posN+2]}
+					if codon.IsAmbiguous() {
+						ambiguousCodons++
+					}
+					if mutation == nil || strings.HasPrefix(mutation.Control, ":::") {
+						identicalCodons++
+					} else if strings.Contains(mutation.AminoAcidText, "*") {
+						stopCodons++
+					}
+					if mutation != nil && mutation.IsInsertion {
+						insertionCodons += len(mutation.GetInsertedCodons())
+					}
+				}
 			}
 			/* those are only for generate three lines */
 			if LastPosA > posA && LastPosN-posN > 2 && mutation == nil {
@@ -233,6 +421,18 @@ func (self *Alignment) generateReport() bool {
 		ControlLine:       cLine,
 		NucleicAcidsLine:  nLine,
 		IsSimpleAlignment: self.isSimpleAlignment,
+		AmbiguousCodons:   ambiguousCodons,
+		StopCodons:        stopCodons,
+		PartialCodons:     partialCodons,
+		InsertionCodons:   insertionCodons,
+		DeletionCodons:    deletionCodons,
+	}
+	self.report.CorrectedNucleicAcids,
+		self.report.CorrectedAminoAcids,
+		self.report.FrameShiftEdits = self.correctFrameShifts(siteList, mutList)
+	if completeCodons > 0 {
+		self.report.PercentIdentity =
+			float64(identicalCodons) * 100 / float64(completeCodons)
 	}
 	return true
 }
@@ -249,9 +449,37 @@ func (self *Alignment) getTypedPos(matrixIndex int) (scoreType tScoreType, posN
 	return
 }
 
-func (self *Alignment) setPrevMatrixIndex(scoreType tScoreType, posN int, posA int, prevMatrixIdx int) {
-	mtIdx := self.getMatrixIndex(scoreType, posN, posA)
-	self.nwMatrix[mtIdx] = prevMatrixIdx
+// Local alignments are only needed to find the boundaries of the
+// aligned segment; the traceback within those boundaries is the same
+// as for semi-global alignments.
+func (self *Alignment) isLocalPass() bool {
+	return self.mode == ap.Local && self.boundaryOnly
+}
+
+// The score of the first row and column of the GENERAL matrix. Only
+// global alignments penalize them, as an insertion of posN NAs or a
+// deletion of posA codons before the start of the alignment.
+func (self *Alignment) getBoundaryScore(posN int, posA int) int {
+	if self.mode != ap.Global || (posN == 0 && posA == 0) {
+		return 0
+	}
+	if posA == 0 {
+		return self.q + self.r*posN
+	}
+	return self.q + self.constIndelCodonOpeningScore +
+		posA*(self.r+self.r+self.r+self.constIndelCodonExtensionScore)
+}
+
+// Whether an alignment may end at the given position
+func (self *Alignment) isEndPosition(posN int, posA int) bool {
+	switch {
+	case self.mode == ap.Global:
+		return posN == self.nSeqLen && posA == self.aSeqLen
+	case self.isLocalPass():
+		return true
+	default:
+		return posN == self.nSeqLen || posA == self.aSeqLen
+	}
 }
 
 func (self *Alignment) getNA(nPos int) n.NucleicAcid {
@@ -262,6 +490,28 @@ func (self *Alignment) getAA(aPos int) a.AminoAcid {
 	return self.aSeq[aPos-1]
 }
 
+// The lowest quality of the bases firstPosN to lastPosN
+func (self *Alignment) getCodonQuality(firstPosN int, lastPosN int) int {
+	quality := self.nQual[firstPosN-1]
+	for _, q := range self.nQual[firstPosN:lastPosN] {
+		if q < quality {
+			quality = q
+		}
+	}
+	return quality
+}
+
+// Whether done was closed; the scores of a pass that stopped early
+// are meaningless
+func (self *Alignment) isCancelled() bool {
+	select {
+	case <-self.done:
+		return true
+	default:
+		return false
+	}
+}
+
 func (self *Alignment) align() bool {
 	var (
 		startPosN, startPosA           int
@@ -270,29 +520,60 @@ func (self *Alignment) align() bool {
 	self.boundaryOnly = true
 	// set boundary for nSeq, so we don't have to build a huge nSeqLen * aSeqLen matrix
 	endPosN, endPosA, self.maxScore, simplesCount = self.calcScoreMainForward()
+	if self.isCancelled() {
+		return false
+	}
 	self.nSeq = self.nSeq[:endPosN]
 	self.nSeqLen = len(self.nSeq)
+	if self.nQual != nil {
+		self.nQual = self.nQual[:endPosN]
+	}
 	self.aSeq = self.aSeq[:endPosA]
 	self.aSeqLen = len(self.aSeq)
 	if self.nSeqLen == 0 || self.aSeqLen == 0 {
 		return false
 	}
-	startPosN, startPosA, _ = self.calcScoreMainBackward()
-	self.nSeqOffset = startPosN - 1
+	if self.mode == ap.Global {
+		// global alignments always start at the beginning
+		startPosN, startPosA = 1, 1
+	} else {
+		startPosN, startPosA, _ = self.calcScoreMainBackward()
+		if self.isCancelled() {
+			return false
+		}
+	}
+	self.nSeqOffset += startPosN - 1
 	self.aSeqOffset = startPosA - 1
 	self.boundaryOnly = false
 	self.nSeq = self.nSeq[startPosN-1:]
 	self.nSeqLen = len(self.nSeq)
+	if self.nQual != nil {
+		self.nQual = self.nQual[startPosN-1:]
+	}
 	self.aSeq = self.aSeq[startPosA-1:]
 	self.aSeqLen = len(self.aSeq)
 	if endPosA-self.aSeqOffset == simplesCount {
 		self.isSimpleAlignment = true
-		self.endPosN = endPosN - self.nSeqOffset
+		self.endPosN = endPosN - startPosN + 1
 		self.endPosA = endPosA - self.aSeqOffset
 		return self.generateReport()
 	}
 	typedPosLen := scoreTypeCount * (self.nSeqLen + 1) * (self.aSeqLen + 1)
-	self.nwMatrix = make([]int, typedPosLen)
+	if typedPosLen > tracebackThreshold {
+		self.traceback = newHirschbergTraceback(self.nSeqLen)
+		defer func() { self.traceback = nil }()
+	} else {
+		self.nwMatrix = make([]int, typedPosLen)
+	}
 	self.endPosN, self.endPosA, self.maxScore, _ = self.calcScoreMainForward()
+	if self.isCancelled() {
+		return false
+	}
+	if self.traceback != nil {
+		self.findTracebackPath(0, nil, nil, self.getMatrixIndex(GENERAL, self.endPosN, self.endPosA))
+		if self.isCancelled() {
+			return false
+		}
+	}
 	return self.generateReport()
 }
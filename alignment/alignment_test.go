@@ -1,10 +1,12 @@
 package alignment
 
 import (
-	"errors"
+	"context"
 	ap "github.com/hivdb/nucamino/alignmentprofile"
+	"github.com/hivdb/nucamino/alignmentprofile/builtin"
 	h "github.com/hivdb/nucamino/scorehandler/general"
 	a "github.com/hivdb/nucamino/types/amino"
+	c "github.com/hivdb/nucamino/types/codon"
 	f "github.com/hivdb/nucamino/types/frameshift"
 	m "github.com/hivdb/nucamino/types/mutation"
 	n "github.com/hivdb/nucamino/types/nucleic"
@@ -178,9 +180,108 @@ func TestMisaligned(t *testing.T) {
 	if aln != nil {
 		t.Errorf(MSG_NOT_EQUAL, nil, aln)
 	}
-	errExpect := errors.New("sequence misaligned")
-	if !reflect.DeepEqual(err, errExpect) {
-		t.Errorf(MSG_NOT_EQUAL, errExpect, err)
+	if err != ErrNoOverlap {
+		t.Errorf(MSG_NOT_EQUAL, ErrNoOverlap, err)
+	}
+}
+
+func TestInvalidInput(t *testing.T) {
+	handler := h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE)
+	var cases = []struct {
+		nseq   string
+		aseq   string
+		expect error
+	}{
+		{"", "TVLVG", ErrEmptySequence},
+		{"ACAGTR", "", ErrEmptyReference},
+		{"AC", "TVLVG", &TooShortError{2}},
+	}
+	for _, c := range cases {
+		aln, err := NewAlignment(n.ReadString(c.nseq), a.ReadString(c.aseq), handler)
+		if aln != nil || !reflect.DeepEqual(err, c.expect) {
+			t.Errorf(MSG_NOT_EQUAL, c.expect, err)
+		}
+	}
+}
+
+func TestNewAlignmentWithContext(t *testing.T) {
+	handler := h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE)
+	result, err := NewAlignmentWithContext(context.Background(), NSEQ, nil, ASEQ, handler)
+	if err != nil || result.GetReport().LastAA != 19 {
+		t.Errorf("Expected the sequence to be aligned, got %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := NewAlignmentWithContext(ctx, NSEQ, nil, ASEQ, handler); err != context.Canceled {
+		t.Errorf(MSG_NOT_EQUAL, context.Canceled, err)
+	}
+}
+
+func TestNewAlignmentInWindow(t *testing.T) {
+	handler := h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE)
+	padded := append(n.ReadString("TTTTTT"), NSEQ...)
+	padded = append(padded, n.ReadString("TTTTTT")...)
+	whole, _ := NewAlignmentWithContext(context.Background(), NSEQ, nil, ASEQ, handler)
+	result, err := NewAlignmentInWindow(
+		context.Background(), padded, nil, ASEQ, handler, 7, len(NSEQ)+6)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expect, report := whole.GetReport(), result.GetReport()
+	if report.FirstNA != expect.FirstNA+6 || report.LastNA != expect.LastNA+6 ||
+		report.Mutations[0].NAPosition != expect.Mutations[0].NAPosition+6 ||
+		report.AlignedSites[0].PosNA != expect.AlignedSites[0].PosNA+6 {
+		t.Errorf("Expected the positions of %#v shifted by 6, got %#v", expect, report)
+	}
+	if report.CorrectedNucleicAcids != expect.CorrectedNucleicAcids {
+		t.Errorf(MSG_NOT_EQUAL, expect.CorrectedNucleicAcids, report.CorrectedNucleicAcids)
+	}
+}
+
+func TestCheckThresholds(t *testing.T) {
+	report := &AlignmentReport{
+		NormalizedScore: 4.5,
+		Coverage:        0.8,
+		FrameShifts:     []f.FrameShift{{}},
+		StopCodons:      2,
+	}
+	intPtr := func(value int) *int { return &value }
+	floatPtr := func(value float64) *float64 { return &value }
+	var cases = []struct {
+		thresholds ap.Thresholds
+		expect     error
+	}{
+		{ap.Thresholds{}, nil},
+		{ap.Thresholds{MinScorePerCodon: floatPtr(2), MaxFrameShifts: intPtr(1)}, nil},
+		{ap.Thresholds{MinScorePerCodon: floatPtr(10)},
+			&LowScoreError{4.5, 10}},
+		{ap.Thresholds{MinCoverage: floatPtr(0.9)},
+			&LowCoverageError{0.8, 0.9}},
+		{ap.Thresholds{MaxFrameShifts: intPtr(0)},
+			&TooManyFrameShiftsError{1, 0}},
+		{ap.Thresholds{MaxFrameShifts: intPtr(1), MaxStopCodons: intPtr(1)},
+			&TooManyStopCodonsError{2, 1}},
+	}
+	for _, c := range cases {
+		err := report.CheckThresholds(c.thresholds)
+		if c.expect == nil {
+			if err != nil {
+				t.Errorf(MSG_NOT_EQUAL, nil, err)
+			}
+			continue
+		}
+		rejected, ok := err.(*RejectedError)
+		if !ok || rejected.Report != report || !reflect.DeepEqual(rejected.Reason, c.expect) {
+			t.Errorf(MSG_NOT_EQUAL, &RejectedError{c.expect, report}, err)
+		}
+	}
+}
+
+func TestCalcScore(t *testing.T) {
+	handler := h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE)
+	score := CalcScore(NSEQ, ASEQ, handler)
+	if score != 91 {
+		t.Errorf(MSG_NOT_EQUAL, 91.0, score)
 	}
 }
 
@@ -200,35 +301,98 @@ func TestGetReport(t *testing.T) {
 		},
 		FrameShifts: []f.FrameShift{},
 		AlignedSites: []AlignedSite{
-			AlignedSite{1, 1, 3},
-			AlignedSite{2, 4, 3},
-			AlignedSite{3, 7, 3},
-			AlignedSite{4, 10, 3},
-			AlignedSite{5, 13, 3},
-			AlignedSite{6, 16, 3},
-			AlignedSite{7, 19, 3},
-			AlignedSite{8, 22, 3},
-			AlignedSite{9, 25, 0},
-			AlignedSite{10, 25, 3},
-			AlignedSite{11, 28, 3},
-			AlignedSite{12, 31, 3},
-			AlignedSite{13, 34, 3},
-			AlignedSite{14, 37, 6},
-			AlignedSite{15, 43, 3},
-			AlignedSite{16, 46, 3},
-			AlignedSite{17, 49, 3},
-			AlignedSite{18, 52, 3},
+			AlignedSite{1, 1, 3, 0},
+			AlignedSite{2, 4, 3, 0},
+			AlignedSite{3, 7, 3, 0},
+			AlignedSite{4, 10, 3, 0},
+			AlignedSite{5, 13, 3, 0},
+			AlignedSite{6, 16, 3, 0},
+			AlignedSite{7, 19, 3, 0},
+			AlignedSite{8, 22, 3, 0},
+			AlignedSite{9, 25, 0, 0},
+			AlignedSite{10, 25, 3, 0},
+			AlignedSite{11, 28, 3, 0},
+			AlignedSite{12, 31, 3, 0},
+			AlignedSite{13, 34, 3, 0},
+			AlignedSite{14, 37, 6, 0},
+			AlignedSite{15, 43, 3, 0},
+			AlignedSite{16, 46, 3, 0},
+			AlignedSite{17, 49, 3, 0},
+			AlignedSite{18, 52, 3, 0},
 		},
 		AminoAcidsLine:    "T  V  L  V  G  P  T  P  V  N  I  I  G  R     N  L  L  T  ",
 		ControlLine:       "::::::::::::::::::::::::---:::::::::::::::+++::::::::::::",
 		NucleicAcidsLine:  "ACAGTRTTAGTAGGACCTACACCT   AACATAATTGGAAGAAAAAATCTGTTGACY",
 		IsSimpleAlignment: false,
+		Score:             58,
+		NormalizedScore:   58.0 / 18,
+		PercentIdentity:   100,
+		AmbiguousCodons:   2,
+		InsertionCodons:   1,
+		DeletionCodons:    1,
+		Coverage:          18.0 / 19,
+
+		CorrectedNucleicAcids: "ACAGTRTTAGTAGGACCTACACCTAACATAATTGGAAGAAAAAATCTGTTGACY",
+		CorrectedAminoAcids:   "TVLVGPTPNIIGRKNLLT",
+		FrameShiftEdits:       []FrameShiftEdit{},
 	}
 	if !reflect.DeepEqual(result, expect) {
 		t.Errorf(MSG_NOT_EQUAL, expect, result)
 	}
 }
 
+func TestFrameShiftCorrection(t *testing.T) {
+	var cases = []struct {
+		nseq      string
+		corrected string
+		aas       string
+		edits     []FrameShiftEdit
+	}{
+		{
+			"ACAGTRTTAGTAGGACCTTTACACCTGCCAACATAATTGGAAGAAATCTGTTGACYCAG",
+			"ACAGTRTTAGTAGGACCTACACCTGCCAACATAATTGGAAGAAATCTGTTGACYCAG",
+			"TVLVGPTPANIIGRNLLTQ",
+			[]FrameShiftEdit{{Position: 6, NAPosition: 19, Removed: "TT"}},
+		},
+		{
+			"ACAGTRTTAGTAGGACCTACCTGCCAACATAATTGGAAGAAATCTGTTGACYCAG",
+			"ACAGTRTTAGTAGGACCTANNCCTGCCAACATAATTGGAAGAAATCTGTTGACYCAG",
+			"TVLVGPXPANIIGRNLLTQ",
+			[]FrameShiftEdit{{Position: 7, NAPosition: 20, Added: "NN"}},
+		},
+		{
+			"ACAGTRTTAGTAGGACCTACACCTGCCAACATAATTGGAAGAAATCTGTTGACYCAG",
+			"ACAGTRTTAGTAGGACCTACACCTGCCAACATAATTGGAAGAAATCTGTTGACYCAG",
+			"TVLVGPTPANIIGRNLLTQ",
+			[]FrameShiftEdit{},
+		},
+	}
+	handler := h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE)
+	for _, c := range cases {
+		aln, _ := NewAlignment(n.ReadString(c.nseq), ASEQ, handler)
+		report := aln.GetReport()
+		if report.CorrectedNucleicAcids != c.corrected {
+			t.Errorf(MSG_NOT_EQUAL, c.corrected, report.CorrectedNucleicAcids)
+		}
+		if report.CorrectedAminoAcids != c.aas {
+			t.Errorf(MSG_NOT_EQUAL, c.aas, report.CorrectedAminoAcids)
+		}
+		if !reflect.DeepEqual(report.FrameShiftEdits, c.edits) {
+			t.Errorf(MSG_NOT_EQUAL, c.edits, report.FrameShiftEdits)
+		}
+	}
+}
+
+func TestMixtureTranslation(t *testing.T) {
+	nseq := n.ReadString("ACAGTRTTAGTAGGACCTACACCTGCCAACATAATTGGARGAAATCTGTTGACYCAG")
+	handler := h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE)
+	aln, _ := NewAlignment(nseq, ASEQ, handler)
+	expect := "TVLVGPTPANIIG[RG]NLLTQ"
+	if result := aln.GetReport().CorrectedAminoAcids; result != expect {
+		t.Errorf(MSG_NOT_EQUAL, expect, result)
+	}
+}
+
 func TestPosInsCodonScore(t *testing.T) {
 	nseq := n.ReadString("ACAGTRTTAGTAGGACCTACACCTttttttGCCAACATAATTGGAAGAAATCTGTTGACYCAG")
 	handler := h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE)
@@ -304,3 +468,164 @@ func TestPosInsCodonScore(t *testing.T) {
 	}
 
 }
+
+func TestGlobalAlignment(t *testing.T) {
+	handler := h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE)
+	// three leading NAs cost an insertion of 3 NAs in global mode
+	nseq := n.ReadString("GGGACAGTRTTAGTAGGACCTACACCTGCCAACATAATTGGAAGAAATCTGTTGACYCAG")
+	semiGlobal, _ := NewAlignment(nseq, ASEQ, handler)
+	global, _ := NewAlignmentWithMode(nseq, ASEQ, handler, ap.Global)
+	if semiGlobal.GetScore() != 9100 {
+		t.Errorf(MSG_NOT_EQUAL, 9100, semiGlobal.GetScore())
+	}
+	if global.GetScore() != 7500 {
+		t.Errorf(MSG_NOT_EQUAL, 7500, global.GetScore())
+	}
+	if report := global.GetReport(); report.FirstNA != 4 || report.FirstAA != 1 {
+		t.Errorf(MSG_NOT_EQUAL, [2]int{4, 1}, [2]int{report.FirstNA, report.FirstAA})
+	}
+	// the last four codons are missing, which costs a deletion of
+	// four codons in global mode
+	nseq = n.ReadString("ACAGTRTTAGTAGGACCTACACCTGCCAACATAATTGGAAGAAAT")
+	semiGlobal, _ = NewAlignment(nseq, ASEQ, handler)
+	global, _ = NewAlignmentWithMode(nseq, ASEQ, handler, ap.Global)
+	if semiGlobal.GetScore() != 7300 {
+		t.Errorf(MSG_NOT_EQUAL, 7300, semiGlobal.GetScore())
+	}
+	if global.GetScore() != 4700 {
+		t.Errorf(MSG_NOT_EQUAL, 4700, global.GetScore())
+	}
+}
+
+func TestLocalAlignment(t *testing.T) {
+	handler := h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE)
+	// only AAs 5 to 14 are present, surrounded by unrelated NAs
+	nseq := n.ReadString("TTTTTTTTTTTTGGACCTACACCTGCCAACATAATTGGAAGATTTTTTTTTTTTTT")
+	semiGlobal, _ := NewAlignment(nseq, ASEQ, handler)
+	if report := semiGlobal.GetReport(); report.FirstAA == 5 {
+		t.Errorf("Expected the semi-global alignment to extend beyond AA 5")
+	}
+	local, _ := NewAlignmentWithMode(nseq, ASEQ, handler, ap.Local)
+	report := local.GetReport()
+	expect := [4]int{5, 14, 13, 42}
+	result := [4]int{report.FirstAA, report.LastAA, report.FirstNA, report.LastNA}
+	if result != expect {
+		t.Errorf(MSG_NOT_EQUAL, expect, result)
+	}
+	if local.GetScore() <= semiGlobal.GetScore() {
+		t.Errorf("Expected the local alignment to score higher than %v, got %v",
+			semiGlobal.GetScore(), local.GetScore())
+	}
+}
+
+// Back-translate an amino acid sequence, always using the first
+// codon (in ACGT order) that translates to each amino acid.
+func backTranslate(aas []a.AminoAcid) []n.NucleicAcid {
+	bases := []n.NucleicAcid{n.A, n.C, n.G, n.T}
+	firstCodons := make(map[a.AminoAcid]c.Codon)
+	for _, b1 := range bases {
+		for _, b2 := range bases {
+			for _, b3 := range bases {
+				codon := c.Codon{b1, b2, b3}
+				if codon.IsStopCodon() {
+					continue
+				}
+				aa := codon.ToAminoAcidUnsafe()
+				if _, found := firstCodons[aa]; !found {
+					firstCodons[aa] = codon
+				}
+			}
+		}
+	}
+	nas := make([]n.NucleicAcid, 0, len(aas)*3)
+	for _, aa := range aas {
+		codon := firstCodons[aa]
+		nas = append(nas, codon.Base1, codon.Base2, codon.Base3)
+	}
+	return nas
+}
+
+// A POL-length sequence with substitutions, codon insertions and
+// deletions, and frameshifts
+func longTestSequence() ([]n.NucleicAcid, []a.AminoAcid) {
+	profile, _ := builtin.Get("hiv1b")
+	aseq := profile.ReferenceSequences["POL"]
+	nas := backTranslate(aseq)
+	edited := make([]n.NucleicAcid, 0, len(nas)+100)
+	for i, na := range nas {
+		switch {
+		case i%97 == 5:
+			// substitution
+			edited = append(edited, na.Complement())
+		case i%331 == 100:
+			// codon deletion
+		case i%401 == 200:
+			// codon insertion
+			edited = append(edited, na, n.G, n.G, n.C)
+		case i%523 == 300:
+			// frameshift insertion
+			edited = append(edited, na, n.T, n.T)
+		case i%613 == 400:
+			// frameshift deletion
+		default:
+			edited = append(edited, na)
+		}
+	}
+	return edited[30:], aseq
+}
+
+func alignWithTracebackThreshold(nseq []n.NucleicAcid, aseq []a.AminoAcid, threshold int) (*AlignmentReport, error) {
+	defaultThreshold := tracebackThreshold
+	tracebackThreshold = threshold
+	defer func() { tracebackThreshold = defaultThreshold }()
+	handler := h.New(ap.Gene("A"), EXAMPLE_ALIGNMENT_PROFILE)
+	aln, err := NewAlignment(nseq, aseq, handler)
+	if err != nil {
+		return nil, err
+	}
+	return aln.GetReport(), nil
+}
+
+func TestHirschbergTraceback(t *testing.T) {
+	longNSeq, longASeq := longTestSequence()
+	var cases = []struct {
+		nseq []n.NucleicAcid
+		aseq []a.AminoAcid
+	}{
+		{n.ReadString("ACAGTRTTAGTAGGACCTACACCTAACATAATTGGAAGAAAAAATCTGTTGACYCA"), ASEQ},
+		{n.ReadString("ACAGTRTTAGTAGGACCTTTTACACCTGCCAACATAATTGGAAGAAATCTGTTGACYCAG"), ASEQ},
+		{n.ReadString("ACAGTRTTAGTAGGACCTTTACACCTGCCAACATAATTGGAAGAAATCTGTTGACYCAG"), ASEQ},
+		{n.ReadString("ACAGTRTTAGTAGGACCTACACCTGCCAACATAATTGGAAGAAATCTGTTGACYCAG"), ASEQ},
+		{longNSeq, longASeq},
+	}
+	for _, cs := range cases {
+		expect, err := alignWithTracebackThreshold(cs.nseq, cs.aseq, int(^uint(0)>>1))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		// a threshold of 0 splits the path down to single columns
+		for _, threshold := range []int{0, 1 << 18} {
+			result, err := alignWithTracebackThreshold(cs.nseq, cs.aseq, threshold)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(result, expect) {
+				t.Errorf(MSG_NOT_EQUAL, expect, result)
+			}
+		}
+	}
+}
+
+func BenchmarkAlignFullMatrix(b *testing.B) {
+	nseq, aseq := longTestSequence()
+	for i := 0; i < b.N; i++ {
+		alignWithTracebackThreshold(nseq, aseq, int(^uint(0)>>1))
+	}
+}
+
+func BenchmarkAlignHirschberg(b *testing.B) {
+	nseq, aseq := longTestSequence()
+	for i := 0; i < b.N; i++ {
+		alignWithTracebackThreshold(nseq, aseq, 1<<18)
+	}
+}
@@ -147,8 +147,14 @@ func (self *Alignment) calcScoreBackward(
 		}
 		if posN < self.nSeqLen-2 {
 			prevNA2 = self.getNA(posN + 2)
-			tmpScore, present := sh.GetCachedSubstitutionScore(posA, curNA, prevNA, prevNA2, curAA)
-			if !present {
+			var tmpScore int
+			if self.nQual != nil {
+				tmpScore = sh.GetQualityWeightedSubstitutionScore(
+					posA, curNA, prevNA, prevNA2, curAA,
+					self.getCodonQuality(posN, posN+2))
+			} else if cached, present := sh.GetCachedSubstitutionScore(posA, curNA, prevNA, prevNA2, curAA); present {
+				tmpScore = cached
+			} else {
 				tmpScore = sh.GetSubstitutionScoreNoCache(posA, curNA, prevNA, prevNA2, curAA)
 			}
 			if cand := /* #4 */ gScore31 + tmpScore; cand > score {
@@ -165,6 +171,10 @@ func (self *Alignment) calcScoreBackward(
 		if cand := /* #10 */ dScore00; cand >= score {
 			score = cand // ""
 		}
+		if score < 0 && self.isLocalPass() {
+			// a local alignment can end anywhere
+			score = 0
+		}
 	}
 	return score
 }
@@ -191,7 +201,7 @@ func (self *Alignment) calcScoreMainBackward() (int, int, int) {
 		dScore11, dScore21 int
 	)
 
-	for j := self.aSeqLen; j >= 1; j-- {
+	for j := self.aSeqLen; j >= 1 && !self.isCancelled(); j-- {
 		gScore30, iScore30 = negInf, negInf
 		gScore20, iScore20 = negInf, negInf
 		gScore10, iScore10 = negInf, negInf
@@ -230,7 +240,7 @@ func (self *Alignment) calcScoreMainBackward() (int, int, int) {
 
 			gScoresCur[i] = gScore00
 
-			if (i == 1 || j == 1) && gScore00 > maxScore {
+			if gScore00 > maxScore && (i == 1 || j == 1 || self.isLocalPass()) {
 				maxScore = gScore00
 				maxScorePosN = i
 				maxScorePosA = j
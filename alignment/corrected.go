@@ -0,0 +1,104 @@
+package alignment
+
+import (
+	c "github.com/hivdb/nucamino/types/codon"
+	m "github.com/hivdb/nucamino/types/mutation"
+	n "github.com/hivdb/nucamino/types/nucleic"
+	u "github.com/hivdb/nucamino/utils"
+	"strings"
+)
+
+// A change made to the query sequence to repair a frameshift at the
+// reference position Position. Insertions are repaired by removing
+// the extra bases (Removed) that follow the codon; NAPosition is the
+// position of the first removed base. Deletions are repaired by
+// adding Ns (Added) to the incomplete codon; NAPosition is the
+// position of the base the Ns were added before.
+type FrameShiftEdit struct {
+	Position   int
+	NAPosition int
+	Removed    string
+	Added      string
+}
+
+// Build the in-frame version of the aligned part of the query and
+// its translation. Codons that may encode more than one amino acid
+// are translated using the mixture notation ("[KR]"); the repaired
+// codons of frameshift deletions are translated as "X".
+func (self *Alignment) correctFrameShifts(
+	sites []AlignedSite, mutations []m.Mutation) (string, string, []FrameShiftEdit) {
+	return correctSites(self.nSeq, self.nSeqOffset, sites, mutations)
+}
+
+// The implementation of correctFrameShifts; nSeq is the query without
+// its first nSeqOffset bases.
+func correctSites(
+	nSeq []n.NucleicAcid, nSeqOffset int,
+	sites []AlignedSite, mutations []m.Mutation) (string, string, []FrameShiftEdit) {
+	var (
+		nas          []n.NucleicAcid
+		aas          string
+		edits        = make([]FrameShiftEdit, 0, 1)
+		partialTexts = make(map[int]string)
+	)
+	for _, mutation := range mutations {
+		if mutation.IsPartial {
+			partialTexts[mutation.Position] = mutation.CodonText
+		}
+	}
+	for _, site := range sites {
+		start := site.PosNA - 1 - nSeqOffset
+		switch {
+		case site.LengthNA == 0:
+			continue
+		case site.LengthNA < 3:
+			codonText, found := partialTexts[site.PosAA]
+			if !found || 3-strings.Count(codonText, " ") != site.LengthNA {
+				// assume the missing bases are at the end of the codon
+				codonText = u.PadRightSpace(
+					n.WriteString(nSeq[start:start+site.LengthNA]), 3)
+			}
+			posNA := site.PosNA
+			for i := 0; i < len(codonText); i++ {
+				if codonText[i] != ' ' {
+					posNA++
+					continue
+				}
+				if i > 0 && codonText[i-1] == ' ' {
+					edits[len(edits)-1].Added += "N"
+				} else {
+					edits = append(edits, FrameShiftEdit{
+						Position:   site.PosAA,
+						NAPosition: posNA,
+						Added:      "N",
+					})
+				}
+			}
+			nas = append(nas, n.ReadString(strings.Replace(codonText, " ", "N", -1))...)
+			aas += "X"
+		default:
+			extra := (site.LengthNA - 3) % 3
+			end := start + site.LengthNA - extra
+			for pos := start; pos < end; pos += 3 {
+				codon := c.Codon{nSeq[pos], nSeq[pos+1], nSeq[pos+2]}
+				aas += mixtureText(codon.ToAminoAcidsText())
+			}
+			nas = append(nas, nSeq[start:end]...)
+			if extra > 0 {
+				edits = append(edits, FrameShiftEdit{
+					Position:   site.PosAA,
+					NAPosition: site.PosNA + site.LengthNA - extra,
+					Removed:    n.WriteString(nSeq[end : end+extra]),
+				})
+			}
+		}
+	}
+	return n.WriteString(nas), aas, edits
+}
+
+func mixtureText(aas string) string {
+	if len(aas) > 1 {
+		return "[" + aas + "]"
+	}
+	return aas
+}
@@ -0,0 +1,134 @@
+package alignment
+
+import (
+	"errors"
+	"fmt"
+	ap "github.com/hivdb/nucamino/alignmentprofile"
+	a "github.com/hivdb/nucamino/types/amino"
+	n "github.com/hivdb/nucamino/types/nucleic"
+)
+
+// Returned by NewAlignment when the nucleotide sequence is empty.
+var ErrEmptySequence = errors.New("empty sequence")
+
+// Returned by NewAlignment when the reference sequence is empty.
+var ErrEmptyReference = errors.New("empty reference sequence")
+
+// Returned by NewAlignment when no part of the sequence could be
+// aligned to the reference.
+var ErrNoOverlap = errors.New("sequence doesn't overlap the reference")
+
+// Deprecated: use ErrNoOverlap, which is the same error.
+var ErrMisaligned = ErrNoOverlap
+
+// Returned by NewAlignment when the nucleotide sequence is too short
+// to contain a single codon.
+type TooShortError struct {
+	Length int
+}
+
+func (e *TooShortError) Error() string {
+	return fmt.Sprintf("sequence of %d bases is too short to contain a codon", e.Length)
+}
+
+func checkInput(nSeq []n.NucleicAcid, aSeq []a.AminoAcid) error {
+	switch {
+	case len(nSeq) == 0:
+		return ErrEmptySequence
+	case len(aSeq) == 0:
+		return ErrEmptyReference
+	case len(nSeq) < 3:
+		return &TooShortError{len(nSeq)}
+	}
+	return nil
+}
+
+// The alignment score per aligned reference position is below
+// Thresholds.MinScorePerCodon.
+type LowScoreError struct {
+	ScorePerCodon    float64
+	MinScorePerCodon float64
+}
+
+func (e *LowScoreError) Error() string {
+	return fmt.Sprintf(
+		"score per aligned codon %.2f is below the minimum of %.2f",
+		e.ScorePerCodon, e.MinScorePerCodon)
+}
+
+// The alignment covers less of the reference than
+// Thresholds.MinCoverage.
+type LowCoverageError struct {
+	Coverage    float64
+	MinCoverage float64
+}
+
+func (e *LowCoverageError) Error() string {
+	return fmt.Sprintf(
+		"coverage %.1f%% is below the minimum of %.1f%%",
+		e.Coverage*100, e.MinCoverage*100)
+}
+
+// The alignment has more frameshifts than Thresholds.MaxFrameShifts.
+type TooManyFrameShiftsError struct {
+	FrameShifts    int
+	MaxFrameShifts int
+}
+
+func (e *TooManyFrameShiftsError) Error() string {
+	return fmt.Sprintf(
+		"%d frameshifts exceed the maximum of %d",
+		e.FrameShifts, e.MaxFrameShifts)
+}
+
+// The alignment has more stop codons than Thresholds.MaxStopCodons.
+type TooManyStopCodonsError struct {
+	StopCodons    int
+	MaxStopCodons int
+}
+
+func (e *TooManyStopCodonsError) Error() string {
+	return fmt.Sprintf(
+		"%d stop codons exceed the maximum of %d",
+		e.StopCodons, e.MaxStopCodons)
+}
+
+// An alignment that was completed but failed one of the quality
+// thresholds. Reason is one of LowScoreError, LowCoverageError,
+// TooManyFrameShiftsError and TooManyStopCodonsError; Report is the
+// rejected alignment.
+type RejectedError struct {
+	Reason error
+	Report *AlignmentReport
+}
+
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("alignment rejected: %v", e.Reason)
+}
+
+func (e *RejectedError) Unwrap() error {
+	return e.Reason
+}
+
+// Check the report against the thresholds, returning a
+// *RejectedError for the first threshold that isn't met.
+func (self *AlignmentReport) CheckThresholds(thresholds ap.Thresholds) error {
+	var reason error
+	switch {
+	case thresholds.MinScorePerCodon != nil &&
+		self.NormalizedScore < *thresholds.MinScorePerCodon:
+		reason = &LowScoreError{self.NormalizedScore, *thresholds.MinScorePerCodon}
+	case thresholds.MinCoverage != nil &&
+		self.Coverage < *thresholds.MinCoverage:
+		reason = &LowCoverageError{self.Coverage, *thresholds.MinCoverage}
+	case thresholds.MaxFrameShifts != nil &&
+		len(self.FrameShifts) > *thresholds.MaxFrameShifts:
+		reason = &TooManyFrameShiftsError{len(self.FrameShifts), *thresholds.MaxFrameShifts}
+	case thresholds.MaxStopCodons != nil &&
+		self.StopCodons > *thresholds.MaxStopCodons:
+		reason = &TooManyStopCodonsError{self.StopCodons, *thresholds.MaxStopCodons}
+	default:
+		return nil
+	}
+	return &RejectedError{reason, self}
+}
@@ -1,6 +1,7 @@
 package alignment
 
 import (
+	ap "github.com/hivdb/nucamino/alignmentprofile"
 	n "github.com/hivdb/nucamino/types/nucleic"
 )
 
@@ -21,13 +22,16 @@ func (self *Alignment) calcExtInsScoreForward(
 	//var control string
 	if posN == 0 && posA > 0 {
 		score = 0 // no penalty for initial gaps
+		if self.mode == ap.Global {
+			score = unreachable
+		}
 		if calcMtIdx {
 			prevMatrixIdx = self.getMatrixIndex(GENERAL, 0, 0)
 		}
 		//control = strings.Repeat("---", pos.a)
 	} else {
 		score = negInf
-		if posA == self.aSeqLen {
+		if posA == self.aSeqLen && self.mode != ap.Global {
 			// no penalty for trailing gaps
 			r, q, insOpeningScore, insExtensionScore = 0, 0, 0, 0
 		} else {
@@ -83,6 +87,9 @@ func (self *Alignment) calcDelScoreForward(
 	//var control string
 	if posN > 0 && posA == 0 {
 		score = 0 // no penalty for initial gaps
+		if self.mode == ap.Global {
+			score = unreachable
+		}
 		if calcMtIdx {
 			prevMatrixIdx = self.getMatrixIndex(GENERAL, 0, 0)
 		}
@@ -94,7 +101,7 @@ func (self *Alignment) calcDelScoreForward(
 			q, q2, r, r2      = self.q, self.q, self.r, self.r
 		)
 		score = negInf
-		if posN == self.nSeqLen {
+		if posN == self.nSeqLen && self.mode != ap.Global {
 			// no penalty for trailing gaps
 			q, r, delOpeningScore, delExtensionScore = 0, 0, 0, 0
 		} else {
@@ -164,7 +171,7 @@ func (self *Alignment) calcScoreForward(
 		calcMtIdx     = !self.boundaryOnly
 	)
 	if posN == 0 || posA == 0 {
-		score = 0
+		score = self.getBoundaryScore(posN, posA)
 		if calcMtIdx {
 			prevMatrixIdx = self.getMatrixIndex(GENERAL, posN, posA)
 		}
@@ -211,8 +218,14 @@ func (self *Alignment) calcScoreForward(
 		}
 		if posN > 2 {
 			prevNA2 = self.getNA(posN - 2)
-			tmpScore, present := sh.GetCachedSubstitutionScore(posA+self.aSeqOffset, prevNA2, prevNA, curNA, curAA)
-			if !present {
+			var tmpScore int
+			if self.nQual != nil {
+				tmpScore = sh.GetQualityWeightedSubstitutionScore(
+					posA+self.aSeqOffset, prevNA2, prevNA, curNA, curAA,
+					self.getCodonQuality(posN-2, posN))
+			} else if cached, present := sh.GetCachedSubstitutionScore(posA+self.aSeqOffset, prevNA2, prevNA, curNA, curAA); present {
+				tmpScore = cached
+			} else {
 				tmpScore = sh.GetSubstitutionScoreNoCache(posA+self.aSeqOffset, prevNA2, prevNA, curNA, curAA)
 			}
 			if cand := /* #4 */ gScore31 + tmpScore; cand > score {
@@ -245,18 +258,33 @@ func (self *Alignment) calcScoreForward(
 				prevMatrixIdx = self.getMatrixIndex(DEL, posN, posA) //, ""
 			}
 		}
+		if score < 0 && self.isLocalPass() {
+			// a local alignment can start anywhere
+			score = 0
+			isSimple = false
+		}
 	}
 	return score, prevMatrixIdx, isSimple
 }
 
 func (self *Alignment) calcScoreMainForward() (int, int, int, int) {
+	return self.calcScoreColumnsForward(
+		0, self.aSeqLen, self.nSeqLen,
+		make([]int, self.nSeqLen+1), make([]int, self.nSeqLen+1))
+}
+
+// Run the forward pass over the columns firstA to lastA (inclusive)
+// and the rows up to lastN. gScores and dScores hold the GENERAL and
+// DEL scores of column firstA-1; the scores of the first column don't
+// depend on them.
+func (self *Alignment) calcScoreColumnsForward(
+	firstA int, lastA int, lastN int,
+	gScores []int, dScores []int) (int, int, int, int) {
 	var (
 		maxScore               = negInf
 		maxScorePosN           = 0
 		maxScorePosA           = 0
 		simplesCountAtMaxScore = 0
-		gScores                = make([]int, self.nSeqLen+1)
-		dScores                = make([]int, self.nSeqLen+1)
 		simplesCountMt         = make([]int, self.nSeqLen+1)
 		gScoresCur             = make([]int, self.nSeqLen+1)
 		dScoresCur             = make([]int, self.nSeqLen+1)
@@ -278,11 +306,11 @@ func (self *Alignment) calcScoreMainForward() (int, int, int, int) {
 		calcMtIdx          = !self.boundaryOnly
 	)
 
-	for j := 0; j <= self.aSeqLen; j++ {
+	for j := firstA; j <= lastA && !self.isCancelled(); j++ {
 		gScore30, iScore30 = negInf, negInf
 		gScore20, iScore20 = negInf, negInf
 		gScore10, iScore10 = negInf, negInf
-		for i := 0; i <= self.nSeqLen; i++ {
+		for i := 0; i <= lastN; i++ {
 			gScore01 = gScores[i]
 			dScore01 = dScores[i]
 			gScore11, gScore21, gScore31 = negInf, negInf, negInf
@@ -338,7 +366,7 @@ func (self *Alignment) calcScoreMainForward() (int, int, int, int) {
 				self.setPrevMatrixIndex(GENERAL, i, j, prevMtIdx)
 			}
 
-			if (i == self.nSeqLen || j == self.aSeqLen) && gScore00 > maxScore {
+			if gScore00 > maxScore && self.isEndPosition(i, j) {
 				maxScore = gScore00
 				maxScorePosN = i
 				maxScorePosA = j
@@ -353,6 +381,9 @@ func (self *Alignment) calcScoreMainForward() (int, int, int, int) {
 		simplesCountMt, simplesCountMtCur = simplesCountMtCur, simplesCountMt
 		dScores, dScoresCur = dScoresCur, dScores
 
+		if self.traceback != nil {
+			self.traceback.endColumn(j, gScores, dScores)
+		}
 	}
 	return maxScorePosN, maxScorePosA, maxScore, simplesCountAtMaxScore
 }
@@ -0,0 +1,21 @@
+package alignment
+
+// Set the genome positions of the report, its mutations, frameshifts
+// and aligned sites. start is the genome position of the first base
+// of the reference of the gene.
+func (self *AlignmentReport) AnnotateGenome(start int) {
+	genomePosition := func(pos int) int {
+		return start + (pos-1)*3
+	}
+	self.GenomeFirstNA = genomePosition(self.FirstAA)
+	self.GenomeLastNA = genomePosition(self.LastAA) + 2
+	for i := range self.Mutations {
+		self.Mutations[i].GenomePosition = genomePosition(self.Mutations[i].Position)
+	}
+	for i := range self.FrameShifts {
+		self.FrameShifts[i].GenomePosition = genomePosition(self.FrameShifts[i].Position)
+	}
+	for i := range self.AlignedSites {
+		self.AlignedSites[i].GenomePosition = genomePosition(self.AlignedSites[i].PosAA)
+	}
+}
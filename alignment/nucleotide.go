@@ -0,0 +1,84 @@
+package alignment
+
+import (
+	"fmt"
+	m "github.com/hivdb/nucamino/types/mutation"
+	n "github.com/hivdb/nucamino/types/nucleic"
+	"strings"
+)
+
+// An aligned codon compared with the codon of the nucleotide
+// reference. RefPosNA is the position of the first base of the
+// reference codon in the nucleotide reference of the gene, counting
+// from 1. Differences is the number of bases that aren't identical
+// to the reference; ambiguous bases count as differences. A codon is
+// synonymous if it differs from the reference codon but translates
+// to the reference amino acid.
+type CodonDifference struct {
+	PosAA          int
+	PosNA          int
+	RefPosNA       int
+	ReferenceCodon string
+	CodonText      string
+	Differences    int
+	IsSynonymous   bool
+}
+
+func (d CodonDifference) ToString() string {
+	return fmt.Sprintf("%d:%s>%s", d.PosAA, d.ReferenceCodon, d.CodonText)
+}
+
+// Compare the codon of every aligned site with the nucleotide
+// reference of the gene, and set CodonDifferences and
+// SynonymousChanges. nSeq is the sequence that was aligned. Sites of
+// deletions and partial codons have no codon to compare; of an
+// insertion, only the codon aligned with the reference is compared.
+func (self *AlignmentReport) CompareNucleotides(nucRef, nSeq []n.NucleicAcid) {
+	mutations := make(map[int]*m.Mutation)
+	for i := range self.Mutations {
+		mutations[self.Mutations[i].Position] = &self.Mutations[i]
+	}
+	self.CodonDifferences = make([]CodonDifference, 0, len(self.AlignedSites))
+	self.SynonymousChanges = make([]CodonDifference, 0)
+	for _, site := range self.AlignedSites {
+		mutation := mutations[site.PosAA]
+		if site.LengthNA < 3 || (mutation != nil && (mutation.IsDeletion || mutation.IsPartial)) {
+			continue
+		}
+		refPosNA := (site.PosAA-1)*3 + 1
+		refCodon := nucRef[refPosNA-1 : refPosNA+2]
+		codon := nSeq[site.PosNA-1 : site.PosNA+2]
+		diff := CodonDifference{
+			PosAA:          site.PosAA,
+			PosNA:          site.PosNA,
+			RefPosNA:       refPosNA,
+			ReferenceCodon: n.WriteString(refCodon),
+			CodonText:      n.WriteString(codon),
+		}
+		for k := range codon {
+			if codon[k] != refCodon[k] {
+				diff.Differences++
+			}
+		}
+		diff.IsSynonymous = diff.Differences > 0 &&
+			(mutation == nil || strings.HasPrefix(mutation.Control, ":::"))
+		self.CodonDifferences = append(self.CodonDifferences, diff)
+		if diff.IsSynonymous {
+			self.SynonymousChanges = append(self.SynonymousChanges, diff)
+		}
+	}
+}
+
+// The codon differences at the positions that accept returns true for
+func filterCodonDifferences(diffs []CodonDifference, accept func(int) bool) []CodonDifference {
+	if diffs == nil {
+		return nil
+	}
+	result := make([]CodonDifference, 0, len(diffs))
+	for _, diff := range diffs {
+		if accept(diff.PosAA) {
+			result = append(result, diff)
+		}
+	}
+	return result
+}
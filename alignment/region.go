@@ -0,0 +1,147 @@
+package alignment
+
+import (
+	ap "github.com/hivdb/nucamino/alignmentprofile"
+	c "github.com/hivdb/nucamino/types/codon"
+	f "github.com/hivdb/nucamino/types/frameshift"
+	m "github.com/hivdb/nucamino/types/mutation"
+	n "github.com/hivdb/nucamino/types/nucleic"
+	"strings"
+)
+
+// The part of a region that an alignment covers, in positions of the
+// region
+type RegionRange struct {
+	Region  string
+	FirstAA int
+	LastAA  int
+}
+
+// The first and last gene positions of region that the report covers
+func (self *AlignmentReport) regionBounds(region ap.Region) (int, int, bool) {
+	first, last := self.FirstAA, self.LastAA
+	if region.Start > first {
+		first = region.Start
+	}
+	if region.End < last {
+		last = region.End
+	}
+	return first, last, first <= last
+}
+
+// Annotate the mutations and frameshifts that are part of one of the
+// regions with the region and their position in it, and set Regions
+// to the parts of the regions the alignment covers. The regions must
+// be regions of the gene the report is for.
+func (self *AlignmentReport) AnnotateRegions(regions []ap.Region) {
+	self.Regions = nil
+	for _, region := range regions {
+		first, last, overlaps := self.regionBounds(region)
+		if !overlaps {
+			continue
+		}
+		self.Regions = append(self.Regions, RegionRange{
+			region.Name, region.RelativePosition(first), region.RelativePosition(last),
+		})
+		for i := range self.Mutations {
+			if mut := &self.Mutations[i]; region.Contains(mut.Position) {
+				mut.Region, mut.RegionPosition = region.Name, region.RelativePosition(mut.Position)
+			}
+		}
+		for i := range self.FrameShifts {
+			if fs := &self.FrameShifts[i]; region.Contains(fs.Position) {
+				fs.Region, fs.RegionPosition = region.Name, region.RelativePosition(fs.Position)
+			}
+		}
+	}
+}
+
+// The report restricted to one region of its gene. nSeq is the
+// sequence that was aligned. Positions stay gene positions; the
+// region positions are given by Regions and by the region annotations
+// of the mutations and frameshifts. The codon counts, the coverage
+// and the corrected sequences are those of the region, while the
+// scores and the alignment lines are those of the whole gene.
+// ErrNoOverlap is returned if the alignment doesn't cover any part of
+// the region.
+func (self *AlignmentReport) ForRegion(
+	region ap.Region, nSeq []n.NucleicAcid) (*AlignmentReport, error) {
+	first, last, overlaps := self.regionBounds(region)
+	if !overlaps {
+		return nil, ErrNoOverlap
+	}
+	report := *self
+	report.FirstAA, report.LastAA = first, last
+	report.Mutations = make([]m.Mutation, 0, len(self.Mutations))
+	for _, mut := range self.Mutations {
+		if region.Contains(mut.Position) {
+			report.Mutations = append(report.Mutations, mut)
+		}
+	}
+	report.FrameShifts = make([]f.FrameShift, 0)
+	for _, fs := range self.FrameShifts {
+		if region.Contains(fs.Position) {
+			report.FrameShifts = append(report.FrameShifts, fs)
+		}
+	}
+	report.AlignedSites = make([]AlignedSite, 0, region.Length())
+	for _, site := range self.AlignedSites {
+		if region.Contains(site.PosAA) {
+			report.AlignedSites = append(report.AlignedSites, site)
+		}
+	}
+	report.CodonDifferences = filterCodonDifferences(self.CodonDifferences, region.Contains)
+	report.SynonymousChanges = filterCodonDifferences(self.SynonymousChanges, region.Contains)
+	report.AnnotateRegions([]ap.Region{region})
+	mutations := make(map[int]*m.Mutation)
+	for i := range report.Mutations {
+		mutations[report.Mutations[i].Position] = &report.Mutations[i]
+	}
+
+	var completeCodons, identicalCodons int
+	report.AmbiguousCodons, report.StopCodons, report.PartialCodons = 0, 0, 0
+	report.InsertionCodons, report.DeletionCodons = 0, 0
+	report.FirstNA, report.LastNA = 0, 0
+	for _, site := range report.AlignedSites {
+		if site.LengthNA > 0 {
+			if report.FirstNA == 0 {
+				report.FirstNA = site.PosNA
+			}
+			report.LastNA = site.PosNA + site.LengthNA - 1
+		}
+		mutation := mutations[site.PosAA]
+		switch {
+		case mutation != nil && mutation.IsDeletion:
+			report.DeletionCodons++
+		case mutation != nil && mutation.IsPartial:
+			report.PartialCodons++
+		case site.LengthNA > 2:
+			completeCodons++
+			codon := c.Codon{nSeq[site.PosNA-1], nSeq[site.PosNA], nSeq[site.PosNA+1]}
+			if codon.IsAmbiguous() {
+				report.AmbiguousCodons++
+			}
+			if mutation == nil || strings.HasPrefix(mutation.Control, ":::") {
+				identicalCodons++
+			} else if strings.Contains(mutation.AminoAcidText, "*") {
+				report.StopCodons++
+			}
+			if mutation != nil && mutation.IsInsertion {
+				report.InsertionCodons += len(mutation.GetInsertedCodons())
+			}
+		}
+	}
+	report.PercentIdentity = 0
+	if completeCodons > 0 {
+		report.PercentIdentity = float64(identicalCodons) * 100 / float64(completeCodons)
+	}
+	report.Coverage = float64(last-first+1) / float64(region.Length())
+	if self.GenomeFirstNA != 0 {
+		report.GenomeFirstNA = self.GenomeFirstNA + (first-self.FirstAA)*3
+		report.GenomeLastNA = self.GenomeLastNA - (self.LastAA-last)*3
+	}
+	report.CorrectedNucleicAcids,
+		report.CorrectedAminoAcids,
+		report.FrameShiftEdits = correctSites(nSeq, 0, report.AlignedSites, report.Mutations)
+	return &report, nil
+}
@@ -0,0 +1,172 @@
+package alignment
+
+// Alignments whose nwMatrix would have more entries than this use a
+// hirschbergTraceback instead. The default keeps the matrix of a
+// single alignment under 32 MB on 64-bit platforms; the same limit
+// applies to the blocks of the hirschbergTraceback.
+var tracebackThreshold = 1 << 22
+
+// Keeping the whole nwMatrix in memory takes 3 * (nSeqLen + 1) *
+// (aSeqLen + 1) ints, which is too much for near full-length genomes.
+// Instead, the traceback path is found by divide and conquer, as in
+// Hirschberg's algorithm: a forward pass over the columns firstA to
+// lastA carries, for every cell after the middle column, the cell at
+// which its traceback enters the middle column (its anchor). The
+// anchor of the end cell splits the path into two halves, which are
+// solved the same way, until a range of columns is narrow enough to
+// keep its previous matrix indexes in a block. Since the anchors
+// follow the same previous matrix indexes as the full matrix, the
+// path is identical to the one the full matrix gives.
+//
+// Unlike in Hirschberg's algorithm, a pass can't skip the rows before
+// the start of its part of the path, since the scores and ties of
+// the path depend on them; only the rows after its end are skipped.
+// This takes O(nSeqLen * log(aSeqLen)) memory besides the block,
+// which is bounded by tracebackThreshold, and O(nSeqLen * aSeqLen *
+// log(aSeqLen)) time.
+type hirschbergTraceback struct {
+	// Number of columns the block has room for
+	blockSize int
+	// The previous matrix indexes of the columns blockStart to
+	// blockStart+blockSize-1, or -1 if no block is being built.
+	blockStart int
+	block      []int
+	// The column whose crossing is being looked for, or -1. anchors
+	// holds the anchors of the column being computed, prevAnchors
+	// those of the column before it; midScores the GENERAL and DEL
+	// scores of column mid.
+	mid         int
+	anchors     []int
+	prevAnchors []int
+	midScores   [2][]int
+	// The previous matrix index of each cell on the traceback path
+	path map[int]int
+}
+
+func newHirschbergTraceback(nSeqLen int) *hirschbergTraceback {
+	blockSize := tracebackThreshold / (scoreTypeCount * (nSeqLen + 1))
+	if blockSize < 1 {
+		blockSize = 1
+	}
+	return &hirschbergTraceback{
+		blockSize:   blockSize,
+		blockStart:  -1,
+		block:       make([]int, scoreTypeCount*blockSize*(nSeqLen+1)),
+		mid:         -1,
+		anchors:     make([]int, scoreTypeCount*(nSeqLen+1)),
+		prevAnchors: make([]int, scoreTypeCount*(nSeqLen+1)),
+		path:        make(map[int]int),
+	}
+}
+
+// Called by the forward pass once column posA is complete
+func (tb *hirschbergTraceback) endColumn(posA int, gScores []int, dScores []int) {
+	if tb.mid < 0 {
+		return
+	}
+	if posA == tb.mid {
+		tb.midScores = [2][]int{
+			append([]int(nil), gScores...),
+			append([]int(nil), dScores...),
+		}
+	}
+	tb.anchors, tb.prevAnchors = tb.prevAnchors, tb.anchors
+}
+
+func (self *Alignment) getBlockIndex(scoreType tScoreType, posN int, posA int) int {
+	tb := self.traceback
+	return (int(scoreType)*tb.blockSize+posA-tb.blockStart)*(self.nSeqLen+1) + posN
+}
+
+func (self *Alignment) getAnchorIndex(scoreType tScoreType, posN int) int {
+	return int(scoreType)*(self.nSeqLen+1) + posN
+}
+
+// The traceback stops at the first row and column; the previous
+// matrix index of a GENERAL cell there is the cell itself.
+func isTracebackEnd(posN int, posA int) bool {
+	return posN == 0 || posA == 0
+}
+
+// Find the traceback path from matrixIdx back to column firstA.
+// gScores and dScores hold the GENERAL and DEL scores of column
+// firstA-1.
+func (self *Alignment) findTracebackPath(firstA int, gScores []int, dScores []int, matrixIdx int) {
+	tb := self.traceback
+	scoreType, posN, lastA := self.getTypedPos(matrixIdx)
+	if isTracebackEnd(posN, lastA) {
+		if scoreType == GENERAL {
+			tb.path[matrixIdx] = matrixIdx
+		}
+		return
+	}
+	gStart := make([]int, self.nSeqLen+1)
+	dStart := make([]int, self.nSeqLen+1)
+	copy(gStart, gScores)
+	copy(dStart, dScores)
+	if lastA-firstA < tb.blockSize {
+		tb.blockStart = firstA
+		self.calcScoreColumnsForward(firstA, lastA, posN, gStart, dStart)
+		for !self.isCancelled() {
+			scoreType, posN, posA := self.getTypedPos(matrixIdx)
+			if isTracebackEnd(posN, posA) {
+				if scoreType == GENERAL {
+					tb.path[matrixIdx] = matrixIdx
+				}
+				break
+			}
+			if posA < firstA {
+				break
+			}
+			prevMatrixIdx := tb.block[self.getBlockIndex(scoreType, posN, posA)]
+			tb.path[matrixIdx] = prevMatrixIdx
+			matrixIdx = prevMatrixIdx
+		}
+		tb.blockStart = -1
+		return
+	}
+
+	mid := firstA + (lastA-firstA)/2
+	tb.mid = mid
+	self.calcScoreColumnsForward(firstA, lastA, posN, gStart, dStart)
+	tb.mid = -1
+	if self.isCancelled() {
+		return
+	}
+	// the last column was moved to prevAnchors by endColumn
+	anchor := tb.prevAnchors[self.getAnchorIndex(scoreType, posN)]
+	midScores := tb.midScores
+	self.findTracebackPath(mid+1, midScores[0], midScores[1], matrixIdx)
+	self.findTracebackPath(firstA, gScores, dScores, anchor)
+}
+
+func (self *Alignment) setPrevMatrixIndex(scoreType tScoreType, posN int, posA int, prevMatrixIdx int) {
+	tb := self.traceback
+	if tb == nil {
+		mtIdx := self.getMatrixIndex(scoreType, posN, posA)
+		self.nwMatrix[mtIdx] = prevMatrixIdx
+		return
+	}
+	switch {
+	case tb.blockStart >= 0:
+		tb.block[self.getBlockIndex(scoreType, posN, posA)] = prevMatrixIdx
+	case tb.mid >= 0 && posA > tb.mid:
+		anchor := prevMatrixIdx
+		prevType, prevN, prevA := self.getTypedPos(prevMatrixIdx)
+		if prevA > tb.mid && !isTracebackEnd(prevN, prevA) {
+			if prevA == posA {
+				anchor = tb.anchors[self.getAnchorIndex(prevType, prevN)]
+			} else {
+				anchor = tb.prevAnchors[self.getAnchorIndex(prevType, prevN)]
+			}
+		}
+		tb.anchors[self.getAnchorIndex(scoreType, posN)] = anchor
+	}
+}
+
+func (self *Alignment) getPrevMatrixIndex(matrixIdx int) int {
+	if tb := self.traceback; tb != nil {
+		return tb.path[matrixIdx]
+	}
+	return self.nwMatrix[matrixIdx]
+}
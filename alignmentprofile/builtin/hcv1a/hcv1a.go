@@ -49,6 +49,13 @@ var HCV1ARefLookup = ap.ReferenceSeqs{
 	"NS5B": HCV1ASEQ_NS5B,
 }
 
+// The start of each gene in H77
+var HCV1AGenome = &ap.Genome{
+	Name:       "H77",
+	GeneStarts: map[ap.Gene]int{"NS3": 3420, "NS5A": 6258, "NS5B": 7602},
+	Order:      []ap.Gene{"NS3", "NS5A", "NS5B"},
+}
+
 var Profile = ap.AlignmentProfile{
 	StopCodonPenalty:         4,
 	GapOpeningPenalty:        10,
@@ -57,4 +64,5 @@ var Profile = ap.AlignmentProfile{
 	IndelCodonExtensionBonus: 2,
 	GeneIndelScores:          hcv1aPositionalIndelScores,
 	ReferenceSequences:       HCV1ARefLookup,
+	Genome:                   HCV1AGenome,
 }
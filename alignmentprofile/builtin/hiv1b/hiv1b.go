@@ -215,6 +215,22 @@ var HIV1BRefLookup = ap.ReferenceSeqs{
 	"GP41": HIV1BSEQ_GP41,
 }
 
+// The proteins of POL, numbered from the start of each protein
+var HIV1BRegions = []ap.Region{
+	{Name: "PR", Gene: "POL", Start: 57, End: 155, Aliases: []string{"Protease"}},
+	{Name: "RT", Gene: "POL", Start: 156, End: 715, Aliases: []string{"ReverseTranscriptase"}},
+	{Name: "IN", Gene: "POL", Start: 716, End: 1003, Aliases: []string{"Integrase"}},
+}
+
+// The start of each gene in HXB2
+var HIV1BGenome = &ap.Genome{
+	Name:       "HXB2",
+	GeneStarts: map[ap.Gene]int{"GAG": 790, "POL": 2085, "GP41": 7758},
+	Order:      []ap.Gene{"GAG", "POL", "GP41"},
+	// gag and pol share about 200 bases; leave room for insertions
+	Overlaps: []ap.GeneOverlap{{Genes: []ap.Gene{"GAG", "POL"}, MaxLength: 250}},
+}
+
 var Profile = ap.AlignmentProfile{
 	StopCodonPenalty:         4,
 	GapOpeningPenalty:        10,
@@ -223,4 +239,6 @@ var Profile = ap.AlignmentProfile{
 	IndelCodonExtensionBonus: 2,
 	GeneIndelScores:          hiv1bPositionalIndelScores,
 	ReferenceSequences:       HIV1BRefLookup,
+	Genome:                   HIV1BGenome,
+	Regions:                  HIV1BRegions,
 }
@@ -50,6 +50,13 @@ var HIV2ARefLookup = ap.ReferenceSeqs{
 	"POL": HIV2ASEQ_POL,
 }
 
+// The proteins of POL, numbered from the start of each protein
+var HIV2ARegions = []ap.Region{
+	{Name: "PR", Gene: "POL", Start: 86, End: 184, Aliases: []string{"Protease"}},
+	{Name: "RT", Gene: "POL", Start: 185, End: 743, Aliases: []string{"ReverseTranscriptase"}},
+	{Name: "IN", Gene: "POL", Start: 744, End: 1036, Aliases: []string{"Integrase"}},
+}
+
 var Profile = ap.AlignmentProfile{
 	StopCodonPenalty:         4,
 	GapOpeningPenalty:        10,
@@ -58,4 +65,5 @@ var Profile = ap.AlignmentProfile{
 	IndelCodonExtensionBonus: 2,
 	GeneIndelScores:          hiv2APositionalIndelScores,
 	ReferenceSequences:       HIV2ARefLookup,
+	Regions:                  HIV2ARegions,
 }
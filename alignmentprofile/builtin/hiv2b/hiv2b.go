@@ -50,6 +50,13 @@ var HIV2BRefLookup = ap.ReferenceSeqs{
 	"POL": HIV2BSEQ_POL,
 }
 
+// The proteins of POL, numbered from the start of each protein
+var HIV2BRegions = []ap.Region{
+	{Name: "PR", Gene: "POL", Start: 85, End: 183, Aliases: []string{"Protease"}},
+	{Name: "RT", Gene: "POL", Start: 184, End: 742, Aliases: []string{"ReverseTranscriptase"}},
+	{Name: "IN", Gene: "POL", Start: 743, End: 1038, Aliases: []string{"Integrase"}},
+}
+
 var Profile = ap.AlignmentProfile{
 	StopCodonPenalty:         4,
 	GapOpeningPenalty:        10,
@@ -58,4 +65,5 @@ var Profile = ap.AlignmentProfile{
 	IndelCodonExtensionBonus: 2,
 	GeneIndelScores:          hiv2BPositionalIndelScores,
 	ReferenceSequences:       HIV2BRefLookup,
+	Regions:                  HIV2BRegions,
 }
@@ -7,6 +7,9 @@ package alignmentprofile
 
 import (
 	"bytes"
+	"sort"
+	"strconv"
+	"strings"
 	"text/template"
 )
 
@@ -15,10 +18,89 @@ GapOpeningPenalty: {{.GapOpeningPenalty}}
 GapExtensionPenalty: {{.GapExtensionPenalty}}
 IndelCodonOpeningBonus: {{.IndelCodonOpeningBonus}}
 IndelCodonExtensionBonus: {{.IndelCodonExtensionBonus}}
+{{ with .AlignmentMode -}}
+AlignmentMode: {{.}}
+{{end -}}
+{{ with .Thresholds -}}
+Thresholds:
+{{- with .MinScorePerCodon}}
+  MinScorePerCodon: {{.}}
+{{- end}}
+{{- with .MinCoverage}}
+  MinCoverage: {{.}}
+{{- end}}
+{{- with .MaxFrameShifts}}
+  MaxFrameShifts: {{.}}
+{{- end}}
+{{- with .MaxStopCodons}}
+  MaxStopCodons: {{.}}
+{{- end}}
+{{end -}}
+{{ with .RawSubstitutionMatrix -}}
+SubstitutionMatrix:{{formatMatrix . ""}}
+{{end -}}
+{{ if .RawGeneParameters -}}
+GeneParameters:
+{{ range $gene, $params := .RawGeneParameters }}  {{$gene}}:
+{{- with $params.StopCodonPenalty}}
+    StopCodonPenalty: {{.}}
+{{- end}}
+{{- with $params.GapOpeningPenalty}}
+    GapOpeningPenalty: {{.}}
+{{- end}}
+{{- with $params.GapExtensionPenalty}}
+    GapExtensionPenalty: {{.}}
+{{- end}}
+{{- with $params.IndelCodonOpeningBonus}}
+    IndelCodonOpeningBonus: {{.}}
+{{- end}}
+{{- with $params.IndelCodonExtensionBonus}}
+    IndelCodonExtensionBonus: {{.}}
+{{- end}}
+{{- with $params.RawSubstitutionMatrix}}
+    SubstitutionMatrix:{{formatMatrix . "    "}}
+{{- end}}
+{{end -}}
+{{end -}}
+{{ if .Regions -}}
+Regions:
+{{ range .Regions }}  - Name: {{.Name}}
+    Gene: {{.Gene}}
+    Start: {{.Start}}
+    End: {{.End}}
+{{- with .Aliases}}
+    Aliases: [ {{join . ", "}} ]
+{{- end}}
+{{end -}}
+{{end -}}
 ReferenceSequences:
 {{ range $gene, $seq := .ReferenceSequences }}  {{$gene}}:
     {{$seq}}
 {{end -}}
+{{ if .NucleotideReferenceSequences -}}
+NucleotideReferenceSequences:
+{{ range $gene, $seq := .NucleotideReferenceSequences }}  {{$gene}}:
+    {{$seq}}
+{{end -}}
+{{end -}}
+{{ with .Genome -}}
+Genome:
+  Name: {{.Name}}
+  GeneStarts:
+{{- range $gene, $start := .GeneStarts}}
+    {{$gene}}: {{$start}}
+{{- end}}
+{{- with .Order}}
+  Order: [ {{joinGenes . ", "}} ]
+{{- end}}
+{{- with .Overlaps}}
+  Overlaps:
+{{- range .}}
+    - Genes: [ {{joinGenes .Genes ", "}} ]
+      MaxLength: {{.MaxLength}}
+{{- end}}
+{{- end}}
+{{end -}}
 {{ if .RawIndelScores -}} PositionalIndelScores: {{- end }}
 {{range $gene, $rawIndels := .RawIndelScores}}  {{$gene}}:
 {{- range $rawIndels}}
@@ -28,8 +110,57 @@ ReferenceSequences:
 
 var profileTemplate *template.Template
 
+func joinInts(values []int) string {
+	texts := make([]string, len(values))
+	for i, value := range values {
+		texts[i] = strconv.Itoa(value)
+	}
+	return strings.Join(texts, ", ")
+}
+
+func joinGenes(genes []Gene, sep string) string {
+	texts := make([]string, len(genes))
+	for i, gene := range genes {
+		texts[i] = string(gene)
+	}
+	return strings.Join(texts, sep)
+}
+
+// Render the value of a SubstitutionMatrix key: the name of a
+// built-in matrix goes on the same line, the rows of an inline matrix
+// are nested below the key with the given indentation.
+func formatMatrix(raw *rawSubstitutionMatrix, indent string) string {
+	if raw.Name != "" {
+		return " " + raw.Name
+	}
+	var buff bytes.Buffer
+	buff.WriteString("\n" + indent + "  AminoAcids: " + raw.AminoAcids)
+	buff.WriteString("\n" + indent + "  Scores:")
+	aas := make([]string, 0, len(raw.Scores))
+	for aa := range raw.Scores {
+		aas = append(aas, aa)
+	}
+	sort.Strings(aas)
+	for _, aa := range aas {
+		key := aa
+		if key == "*" {
+			// a bare "*" would be read as a YAML alias
+			key = `"*"`
+		}
+		buff.WriteString("\n" + indent + "    " + key + ": [ " + joinInts(raw.Scores[aa]) + " ]")
+	}
+	return buff.String()
+}
+
 func init() {
-	profileTemplate = template.Must(template.New("alignmentprofile").Parse(profileTemplateSrc))
+	profileTemplate = template.Must(
+		template.New("alignmentprofile").
+			Funcs(template.FuncMap{
+				"formatMatrix": formatMatrix,
+				"join":         strings.Join,
+				"joinGenes":    joinGenes,
+			}).
+			Parse(profileTemplateSrc))
 }
 
 func Format(ap AlignmentProfile) string {
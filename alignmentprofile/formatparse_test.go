@@ -2,6 +2,7 @@ package alignmentprofile
 
 import (
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -48,3 +49,185 @@ func TestParseFormatRoundTrip(t *testing.T) {
 		t.Errorf("%v != %v", formatted, exampleProfileYAML)
 	}
 }
+
+var geneParametersProfileYAML = `StopCodonPenalty: 1
+GapOpeningPenalty: 2
+GapExtensionPenalty: 3
+IndelCodonOpeningBonus: 4
+IndelCodonExtensionBonus: 5
+GeneParameters:
+  A:
+    GapOpeningPenalty: 0
+    IndelCodonExtensionBonus: 7
+  B:
+    StopCodonPenalty: 6
+    SubstitutionMatrix: BLOSUM45
+ReferenceSequences:
+  A:
+    TTALIEPPVYPIVEHSDEKTAHEEH
+  B:
+    CSNELVISHEADPVWRSAVLRGAP
+PositionalIndelScores:
+  A:
+    - [ ins, 3, 4, 5 ]
+`
+
+func TestGeneParametersRoundTrip(t *testing.T) {
+	parsed, err := Parse(geneParametersProfileYAML)
+	if err != nil {
+		t.Errorf("Unexpected error while parsing example YAML: %v", err)
+		t.FailNow()
+	}
+	params := parsed.GeneParameters["A"]
+	if params.GapOpeningPenalty == nil || *params.GapOpeningPenalty != 0 {
+		t.Errorf("Expected gap opening penalty override 0 for gene A")
+	}
+	if params.StopCodonPenalty != nil {
+		t.Errorf("Unexpected stop codon penalty override for gene A")
+	}
+	formatted := Format(*parsed)
+	if formatted != geneParametersProfileYAML {
+		t.Errorf("%v != %v", formatted, geneParametersProfileYAML)
+	}
+}
+
+func TestGeneParametersForUnknownGene(t *testing.T) {
+	src := strings.Replace(geneParametersProfileYAML, "  B:\n    Stop", "  C:\n    Stop", 1)
+	_, err := Parse(src)
+	if err == nil {
+		t.Errorf("Expected error for GeneParameters of an unknown gene")
+	}
+}
+
+var regionsProfileYAML = `StopCodonPenalty: 1
+GapOpeningPenalty: 2
+GapExtensionPenalty: 3
+IndelCodonOpeningBonus: 4
+IndelCodonExtensionBonus: 5
+Regions:
+  - Name: A1
+    Gene: A
+    Start: 1
+    End: 10
+    Aliases: [ First, FirstHalf ]
+  - Name: A2
+    Gene: A
+    Start: 11
+    End: 25
+ReferenceSequences:
+  A:
+    TTALIEPPVYPIVEHSDEKTAHEEH
+  B:
+    CSNELVISHEADPVWRSAVLRGAP
+
+`
+
+func TestRegionsRoundTrip(t *testing.T) {
+	parsed, err := Parse(regionsProfileYAML)
+	if err != nil {
+		t.Errorf("Unexpected error while parsing example YAML: %v", err)
+		t.FailNow()
+	}
+	region, found := parsed.FindRegion(" first ")
+	if !found || region.Name != "A1" || region.Length() != 10 {
+		t.Errorf("Expected the alias First to find region A1, got %#v", region)
+	}
+	if regions := parsed.RegionsOf("A"); len(regions) != 2 || regions[1].RelativePosition(11) != 1 {
+		t.Errorf("Expected the regions A1 and A2 of gene A, got %#v", regions)
+	}
+	if regions := parsed.RegionsOf("B"); len(regions) != 0 {
+		t.Errorf("Expected no regions of gene B, got %#v", regions)
+	}
+	formatted := Format(*parsed)
+	if formatted != regionsProfileYAML {
+		t.Errorf("%v != %v", formatted, regionsProfileYAML)
+	}
+}
+
+func TestInvalidRegions(t *testing.T) {
+	cases := []struct{ old, new string }{
+		{"Gene: A\n    Start: 11", "Gene: C\n    Start: 11"},
+		{"End: 25", "End: 26"},
+		{"Start: 11", "Start: 0"},
+		{"Name: A2", "Name: a1"},
+		{"Name: A2", "Name: B"},
+		{"FirstHalf", "A2"},
+	}
+	for _, c := range cases {
+		src := strings.Replace(regionsProfileYAML, c.old, c.new, 1)
+		if _, err := Parse(src); err == nil {
+			t.Errorf("Expected an error after replacing %q by %q", c.old, c.new)
+		}
+	}
+}
+
+var nucleotideReferencesProfileYAML = `StopCodonPenalty: 1
+GapOpeningPenalty: 2
+GapExtensionPenalty: 3
+IndelCodonOpeningBonus: 4
+IndelCodonExtensionBonus: 5
+ReferenceSequences:
+  A:
+    MKLXT
+  B:
+    CSN
+NucleotideReferenceSequences:
+  A:
+    ATGAAGCTTNNNACN
+Genome:
+  Name: G1
+  GeneStarts:
+    A: 101
+    B: 120
+  Order: [ A, B ]
+  Overlaps:
+    - Genes: [ A, B ]
+      MaxLength: 5
+
+`
+
+func TestNucleotideReferencesAndGenomeRoundTrip(t *testing.T) {
+	parsed, err := Parse(nucleotideReferencesProfileYAML)
+	if err != nil {
+		t.Errorf("Unexpected error while parsing example YAML: %v", err)
+		t.FailNow()
+	}
+	if len(parsed.NucleotideReferenceSequences) != 1 ||
+		len(parsed.NucleotideReferenceSequences["A"]) != 15 {
+		t.Errorf("Expected a nucleotide reference of gene A, got %#v", parsed.NucleotideReferenceSequences)
+	}
+	if start, found := parsed.Genome.StartOf("B"); !found || start != 120 {
+		t.Errorf("Expected gene B to start at 120 of the genome, got %#v", parsed.Genome)
+	}
+	if overlap := parsed.Genome.MaxOverlap("B", "A"); overlap != 5 {
+		t.Errorf("Expected genes A and B to overlap by up to 5 bases, got %d", overlap)
+	}
+	formatted := Format(*parsed)
+	if formatted != nucleotideReferencesProfileYAML {
+		t.Errorf("%v != %v", formatted, nucleotideReferencesProfileYAML)
+	}
+}
+
+func TestInvalidNucleotideReferencesAndGenome(t *testing.T) {
+	cases := []struct{ old, new string }{
+		{"  A:\n    ATG", "  C:\n    ATG"},
+		{"ACN", "AC"},
+		{"ATGAAG", "ATGAAC"},
+		{"CTT", "YTT"},
+		{"CTT", "CTU"},
+		{"Name: G1", "Name: ''"},
+		{"B: 120", "C: 120"},
+		{"A: 101", "A: 0"},
+		{"Order: [ A, B ]", "Order: [ A, C ]"},
+		{"Order: [ A, B ]", "Order: [ A, B, A ]"},
+		{"Genes: [ A, B ]", "Genes: [ A, A ]"},
+		{"Genes: [ A, B ]", "Genes: [ A ]"},
+		{"MaxLength: 5", "MaxLength: 0"},
+	}
+	for _, c := range cases {
+		src := strings.Replace(nucleotideReferencesProfileYAML, c.old, c.new, 1)
+		if _, err := Parse(src); err == nil {
+			t.Errorf("Expected an error after replacing %q by %q", c.old, c.new)
+		}
+	}
+}
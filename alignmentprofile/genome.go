@@ -0,0 +1,130 @@
+package alignmentprofile
+
+import (
+	"fmt"
+	"sort"
+)
+
+// The placement of the genes in a reference genome, such as HXB2.
+// GeneStarts is the genome position of the first base of the
+// reference of each gene, counting from 1; genes without a start
+// aren't placed. Order lists the genes in the order they appear in
+// the genome, which is what genome mode places them by; genes may
+// only overlap as far as Overlaps allows.
+type Genome struct {
+	Name       string        `yaml:"Name"`
+	GeneStarts map[Gene]int  `yaml:"GeneStarts"`
+	Order      []Gene        `yaml:"Order,flow,omitempty"`
+	Overlaps   []GeneOverlap `yaml:"Overlaps,omitempty"`
+}
+
+// Two genes whose reading frames may share up to MaxLength bases,
+// such as HIV-1 gag and pol
+type GeneOverlap struct {
+	Genes     []Gene `yaml:"Genes,flow"`
+	MaxLength int    `yaml:"MaxLength"`
+}
+
+// The number of bases genes g1 and g2 may share
+func (genome *Genome) MaxOverlap(g1, g2 Gene) int {
+	if genome == nil {
+		return 0
+	}
+	for _, overlap := range genome.Overlaps {
+		if (overlap.Genes[0] == g1 && overlap.Genes[1] == g2) ||
+			(overlap.Genes[0] == g2 && overlap.Genes[1] == g1) {
+			return overlap.MaxLength
+		}
+	}
+	return 0
+}
+
+// The genome position of the first base of the reference of gene g
+func (genome *Genome) StartOf(g Gene) (int, bool) {
+	if genome == nil {
+		return 0, false
+	}
+	start, found := genome.GeneStarts[g]
+	return start, found
+}
+
+// Check that the genome is named, that it only places and orders
+// genes of the profile, and that overlaps are between two ordered
+// genes
+func (profile AlignmentProfile) validateGenome() error {
+	genome := profile.Genome
+	if genome == nil {
+		return nil
+	}
+	if genome.Name == "" {
+		return fmt.Errorf("Genome without a name")
+	}
+	for gene, start := range genome.GeneStarts {
+		if _, found := profile.ReferenceSequences[gene]; !found {
+			return fmt.Errorf("Genome start given for unknown gene '%v'", gene)
+		}
+		if start < 1 {
+			return fmt.Errorf("The genome start of gene '%v' must be at least 1, got %d", gene, start)
+		}
+	}
+	ordered := make(map[Gene]bool)
+	for _, gene := range genome.Order {
+		if _, found := profile.ReferenceSequences[gene]; !found {
+			return fmt.Errorf("Genome order given for unknown gene '%v'", gene)
+		}
+		if ordered[gene] {
+			return fmt.Errorf("Gene '%v' is listed more than once in the genome order", gene)
+		}
+		ordered[gene] = true
+	}
+	for _, overlap := range genome.Overlaps {
+		if len(overlap.Genes) != 2 || !ordered[overlap.Genes[0]] || !ordered[overlap.Genes[1]] ||
+			overlap.Genes[0] == overlap.Genes[1] {
+			return fmt.Errorf("Overlap %v must name two different genes of the genome order", overlap.Genes)
+		}
+		if overlap.MaxLength < 1 {
+			return fmt.Errorf("The overlap of %v must be at least 1 base, got %d", overlap.Genes, overlap.MaxLength)
+		}
+	}
+	return nil
+}
+
+// A position in the reference of a gene: the amino acid position and
+// the base of the codon, counting from 1
+type GenePosition struct {
+	Gene      Gene
+	Position  int
+	CodonBase int
+}
+
+// The genome positions of the first and last base of the codon at
+// position pos of the reference of gene g. Not found if the genome
+// doesn't place the gene or pos isn't a position of its reference.
+func (profile AlignmentProfile) GenomePositionsOf(g Gene, pos int) (int, int, bool) {
+	start, found := profile.Genome.StartOf(g)
+	if !found || pos < 1 || pos > len(profile.ReferenceSequences[g]) {
+		return 0, 0, false
+	}
+	first := start + (pos-1)*3
+	return first, first + 2, true
+}
+
+// The codons of all genes that contain the genome position
+// genomePos, ordered by gene. Genes that overlap have a codon each.
+func (profile AlignmentProfile) GenePositionsAt(genomePos int) []GenePosition {
+	positions := make([]GenePosition, 0)
+	if profile.Genome == nil {
+		return positions
+	}
+	for gene, start := range profile.Genome.GeneStarts {
+		offset := genomePos - start
+		if offset < 0 || offset >= len(profile.ReferenceSequences[gene])*3 {
+			continue
+		}
+		positions = append(positions, GenePosition{gene, offset/3 + 1, offset%3 + 1})
+	}
+	sort.Slice(positions, func(i, j int) bool {
+		return positions[i].Gene < positions[j].Gene
+	})
+	return positions
+}
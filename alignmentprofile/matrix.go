@@ -0,0 +1,236 @@
+package alignmentprofile
+
+import (
+	"fmt"
+	d "github.com/hivdb/nucamino/data"
+	a "github.com/hivdb/nucamino/types/amino"
+	"strings"
+)
+
+// The scores for substituting one amino acid by another, indexed by
+// a.AminoAcid, including the codes X, Stop, B and Z. Name is the name
+// of a built-in matrix, or empty if the scores were given inline in
+// the profile.
+type SubstitutionMatrix struct {
+	Name   string
+	Scores [a.NumAminoAcidCodes][a.NumAminoAcidCodes]int
+}
+
+// Retrieve one of the built-in substitution matrices (see
+// data.SubstitutionMatrixNames); the name is case-insensitive.
+func BuiltinSubstitutionMatrix(name string) (*SubstitutionMatrix, error) {
+	name = strings.ToUpper(name)
+	lookup, found := d.LookupSubstitutionMatrix(name)
+	if !found {
+		return nil, fmt.Errorf(
+			"Unknown substitution matrix '%v' (expecting one of %v, or an inline matrix)",
+			name, strings.Join(d.SubstitutionMatrixNames(), ", "))
+	}
+	matrix := SubstitutionMatrix{Name: name}
+	for _, aa1 := range a.AminoAcidCodes {
+		for _, aa2 := range a.AminoAcidCodes {
+			matrix.Scores[aa1][aa2] = int(lookup(aa1, aa2))
+		}
+	}
+	return &matrix, nil
+}
+
+// The score of substituting aa1 by aa2
+func (m *SubstitutionMatrix) Score(aa1 a.AminoAcid, aa2 a.AminoAcid) int {
+	return m.Scores[aa1][aa2]
+}
+
+// Check that substituting aa1 by aa2 scores the same as substituting
+// aa2 by aa1.
+func (m *SubstitutionMatrix) validate() error {
+	for _, aa1 := range a.AminoAcidCodes {
+		for _, aa2 := range a.AminoAcidCodes {
+			if m.Scores[aa1][aa2] != m.Scores[aa2][aa1] {
+				return fmt.Errorf(
+					"Substitution matrix is not symmetric: %v->%v is %v but %v->%v is %v",
+					a.ToString(aa1), a.ToString(aa2), m.Scores[aa1][aa2],
+					a.ToString(aa2), a.ToString(aa1), m.Scores[aa2][aa1])
+			}
+		}
+	}
+	return nil
+}
+
+// This structure is a de-serialization target for the
+// SubstitutionMatrix of a serialized profile, which is either the
+// name of a built-in matrix:
+//
+//	SubstitutionMatrix: BLOSUM45
+//
+// or an inline matrix whose columns are given by AminoAcids. The 20
+// standard amino acids are required; the scores of the codes X, *, B
+// and Z are derived from them unless they are listed as well (see
+// fillExtendedScores):
+//
+//	SubstitutionMatrix:
+//	  AminoAcids: ARNDCQEGHILKMFPSTWYV
+//	  Scores:
+//	    A: [ 4, -1, -2, ... ]
+//	    R: [ -1, 5, 0, ... ]
+//	    ...
+type rawSubstitutionMatrix struct {
+	Name       string
+	AminoAcids string
+	Scores     map[string][]int
+}
+
+func (t *rawSubstitutionMatrix) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var name string
+	if err := unmarshal(&name); err == nil {
+		t.Name = name
+		return nil
+	}
+	var inline struct {
+		AminoAcids string           `yaml:"AminoAcids"`
+		Scores     map[string][]int `yaml:"Scores"`
+	}
+	if err := unmarshal(&inline); err != nil {
+		return err
+	}
+	t.AminoAcids = inline.AminoAcids
+	t.Scores = inline.Scores
+	return nil
+}
+
+func readMatrixAminoAcid(src string) (a.AminoAcid, bool) {
+	aas, err := a.Parse(src)
+	if err != nil || len(aas) != 1 {
+		return 0, false
+	}
+	return aas[0], true
+}
+
+// Construct a SubstitutionMatrix from a rawSubstitutionMatrix,
+// checking that an inline matrix has a score for every pair of
+// standard amino acids.
+func (raw rawSubstitutionMatrix) asMatrix() (*SubstitutionMatrix, error) {
+	if raw.Name != "" {
+		return BuiltinSubstitutionMatrix(raw.Name)
+	}
+	columns := make([]a.AminoAcid, 0, a.NumAminoAcidCodes)
+	var seen [a.NumAminoAcidCodes]bool
+	for _, char := range strings.ToUpper(strings.Join(strings.Fields(raw.AminoAcids), "")) {
+		aa, ok := readMatrixAminoAcid(string(char))
+		if !ok {
+			return nil, fmt.Errorf("Invalid amino acid '%c' in substitution matrix", char)
+		}
+		if seen[aa] {
+			return nil, fmt.Errorf("Duplicated amino acid '%c' in substitution matrix", char)
+		}
+		seen[aa] = true
+		columns = append(columns, aa)
+	}
+	for _, aa := range a.AminoAcids {
+		if !seen[aa] {
+			return nil, fmt.Errorf(
+				"Substitution matrix must list all %d standard amino acids in AminoAcids, missing %v",
+				a.NumAminoAcids, a.ToString(aa))
+		}
+	}
+
+	var matrix SubstitutionMatrix
+	var seenRows [a.NumAminoAcidCodes]bool
+	for rowSrc, scores := range raw.Scores {
+		aa1, ok := readMatrixAminoAcid(rowSrc)
+		if !ok {
+			return nil, fmt.Errorf("Invalid amino acid '%v' in substitution matrix", rowSrc)
+		}
+		if seenRows[aa1] {
+			return nil, fmt.Errorf("Duplicated amino acid '%v' in substitution matrix", rowSrc)
+		}
+		seenRows[aa1] = true
+		if !seen[aa1] {
+			return nil, fmt.Errorf(
+				"Substitution matrix row %v is not listed in AminoAcids", rowSrc)
+		}
+		if len(scores) != len(columns) {
+			return nil, fmt.Errorf(
+				"Substitution matrix row %v has %d scores, expecting %d",
+				rowSrc, len(scores), len(columns))
+		}
+		for i, score := range scores {
+			matrix.Scores[aa1][columns[i]] = score
+		}
+	}
+	for _, aa := range columns {
+		if !seenRows[aa] {
+			return nil, fmt.Errorf("Substitution matrix is missing row %v", a.ToString(aa))
+		}
+	}
+	matrix.fillExtendedScores(seen)
+	if err := matrix.validate(); err != nil {
+		return nil, err
+	}
+	return &matrix, nil
+}
+
+// Construct a rawSubstitutionMatrix from a SubstitutionMatrix.
+// Built-in matrices are serialized by name; inline matrices list
+// their columns in the order of a.AminoAcidCodes.
+func (m *SubstitutionMatrix) asRaw() *rawSubstitutionMatrix {
+	if m.Name != "" {
+		return &rawSubstitutionMatrix{Name: m.Name}
+	}
+	raw := rawSubstitutionMatrix{
+		AminoAcids: a.WriteString(a.AminoAcidCodes[:]),
+		Scores:     make(map[string][]int, a.NumAminoAcidCodes),
+	}
+	for _, aa1 := range a.AminoAcidCodes {
+		row := make([]int, a.NumAminoAcidCodes)
+		for i, aa2 := range a.AminoAcidCodes {
+			row[i] = m.Scores[aa1][aa2]
+		}
+		raw.Scores[a.ToString(aa1)] = row
+	}
+	return &raw
+}
+
+// Derive the scores of the amino acid codes that weren't listed in an
+// inline matrix. The score of B, Z and X is the mean score of the
+// amino acids they stand for (rounded towards zero); Stop scores the
+// lowest score of the matrix against everything but itself, and 1
+// against itself.
+func (m *SubstitutionMatrix) fillExtendedScores(listed [a.NumAminoAcidCodes]bool) {
+	members := func(aa a.AminoAcid) []a.AminoAcid {
+		if listed[aa] {
+			return []a.AminoAcid{aa}
+		}
+		return a.AmbiguousCodeMembers[aa]
+	}
+	lowest := 0
+	for _, aa1 := range a.AminoAcids {
+		for _, aa2 := range a.AminoAcids {
+			if m.Scores[aa1][aa2] < lowest {
+				lowest = m.Scores[aa1][aa2]
+			}
+		}
+	}
+	for _, aa1 := range a.AminoAcidCodes {
+		for _, aa2 := range a.AminoAcidCodes {
+			if listed[aa1] && listed[aa2] {
+				continue
+			}
+			if !listed[a.Stop] && (aa1 == a.Stop || aa2 == a.Stop) {
+				if aa1 == aa2 {
+					m.Scores[aa1][aa2] = 1
+				} else {
+					m.Scores[aa1][aa2] = lowest
+				}
+				continue
+			}
+			sum, count := 0, 0
+			for _, member1 := range members(aa1) {
+				for _, member2 := range members(aa2) {
+					sum += m.Scores[member1][member2]
+					count++
+				}
+			}
+			m.Scores[aa1][aa2] = sum / count
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package alignmentprofile
+
+import (
+	"fmt"
+	a "github.com/hivdb/nucamino/types/amino"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const matrixProfileHeader = `StopCodonPenalty: 1
+GapOpeningPenalty: 2
+GapExtensionPenalty: 3
+IndelCodonOpeningBonus: 4
+IndelCodonExtensionBonus: 5
+`
+
+const matrixProfileFooter = `ReferenceSequences:
+  A:
+    TTALIEPPVYPIVEHSDEKTAHEEH
+`
+
+// An inline matrix scoring identical amino acids 2 and everything
+// else -1, with the given rows replaced.
+func inlineMatrixYAML(columns string, rows map[string]string) string {
+	src := "SubstitutionMatrix:\n  AminoAcids: " + columns + "\n  Scores:\n"
+	for _, row := range columns {
+		scores := make([]string, len(columns))
+		for i, col := range columns {
+			if row == col {
+				scores[i] = "2"
+			} else {
+				scores[i] = "-1"
+			}
+		}
+		text := strings.Join(scores, ", ")
+		if replaced, ok := rows[string(row)]; ok {
+			text = replaced
+		}
+		src += fmt.Sprintf("    %c: [ %s ]\n", row, text)
+	}
+	return matrixProfileHeader + src + matrixProfileFooter
+}
+
+func TestParseNamedSubstitutionMatrix(t *testing.T) {
+	src := matrixProfileHeader + "SubstitutionMatrix: blosum45\n" + matrixProfileFooter
+	profile, err := Parse(src)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	matrix := profile.SubstitutionMatrix
+	if matrix.Name != "BLOSUM45" {
+		t.Errorf("Expected matrix BLOSUM45, got %v", matrix.Name)
+	}
+	if score := matrix.Score(a.W, a.W); score != 15 {
+		t.Errorf("Expected W->W to score 15, got %v", score)
+	}
+	if score := matrix.Score(a.A, a.R); score != -2 {
+		t.Errorf("Expected A->R to score -2, got %v", score)
+	}
+}
+
+func TestParseUnknownSubstitutionMatrix(t *testing.T) {
+	src := matrixProfileHeader + "SubstitutionMatrix: BLOSUM99\n" + matrixProfileFooter
+	_, err := Parse(src)
+	if err == nil {
+		t.Errorf("Expected error for unknown substitution matrix")
+	}
+}
+
+func TestDefaultSubstitutionMatrix(t *testing.T) {
+	matrix := exampleProfile.SubstitutionMatrixOrDefault()
+	if matrix.Name != "BLOSUM62" {
+		t.Errorf("Expected matrix BLOSUM62, got %v", matrix.Name)
+	}
+	if score := matrix.Score(a.W, a.W); score != 11 {
+		t.Errorf("Expected W->W to score 11, got %v", score)
+	}
+}
+
+func TestParseInlineSubstitutionMatrix(t *testing.T) {
+	// the columns are deliberately not in alphabetical order
+	columns := "ARNDCQEGHILKMFPSTWYV"
+	profile, err := Parse(inlineMatrixYAML(columns, nil))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	matrix := profile.SubstitutionMatrix
+	if matrix.Name != "" {
+		t.Errorf("Expected an unnamed matrix, got %v", matrix.Name)
+	}
+	for _, aa1 := range a.AminoAcids {
+		for _, aa2 := range a.AminoAcids {
+			expect := -1
+			if aa1 == aa2 {
+				expect = 2
+			}
+			if score := matrix.Score(aa1, aa2); score != expect {
+				t.Errorf("Expected %v->%v to score %v, got %v",
+					a.ToString(aa1), a.ToString(aa2), expect, score)
+			}
+		}
+	}
+}
+
+func TestParseInvalidInlineSubstitutionMatrix(t *testing.T) {
+	var cases = []struct {
+		desc string
+		src  string
+	}{
+		{"missing column", inlineMatrixYAML("ARNDCQEGHILKMFPSTWY", nil)},
+		{"short row", inlineMatrixYAML("ARNDCQEGHILKMFPSTWYV", map[string]string{
+			"A": "2, -1",
+		})},
+		{"asymmetric", inlineMatrixYAML("ARNDCQEGHILKMFPSTWYV", map[string]string{
+			"A": "2, 3, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1",
+		})},
+	}
+	for _, c := range cases {
+		_, err := Parse(c.src)
+		if err == nil {
+			t.Errorf("Expected error for %v substitution matrix", c.desc)
+		}
+	}
+}
+
+func TestSubstitutionMatrixRoundTrip(t *testing.T) {
+	named, _ := BuiltinSubstitutionMatrix("PAM250")
+	inline := *named
+	inline.Name = ""
+	inline.Scores[a.A][a.C] = 7
+	inline.Scores[a.C][a.A] = 7
+	for _, matrix := range []*SubstitutionMatrix{named, &inline} {
+		profile := exampleProfile
+		profile.SubstitutionMatrix = matrix
+		parsed, err := Parse(Format(profile))
+		if err != nil {
+			t.Fatalf("Unexpected error while parsing formatted profile: %v", err)
+		}
+		if !reflect.DeepEqual(*parsed, profile) {
+			t.Errorf("%v != %v", *parsed, profile)
+		}
+	}
+}
+
+func TestBuiltinExtendedScores(t *testing.T) {
+	matrix, _ := BuiltinSubstitutionMatrix("BLOSUM62")
+	var cases = []struct {
+		aa1, aa2 a.AminoAcid
+		expect   int
+	}{
+		{a.B, a.D, 4},
+		{a.D, a.B, 4},
+		{a.Z, a.E, 4},
+		{a.X, a.A, 0},
+		{a.Stop, a.W, -4},
+		{a.Stop, a.Stop, 1},
+	}
+	for _, c := range cases {
+		if score := matrix.Score(c.aa1, c.aa2); score != c.expect {
+			t.Errorf("Expected %v->%v to score %v, got %v",
+				a.ToString(c.aa1), a.ToString(c.aa2), c.expect, score)
+		}
+	}
+}
+
+func TestParseInlineSubstitutionMatrixExtendedScores(t *testing.T) {
+	profile, err := Parse(inlineMatrixYAML("ARNDCQEGHILKMFPSTWYVB", map[string]string{
+		"B": "-1, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1, 3",
+	}))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	matrix := profile.SubstitutionMatrix
+	var cases = []struct {
+		aa1, aa2 a.AminoAcid
+		expect   int
+	}{
+		// listed
+		{a.B, a.B, 3},
+		{a.B, a.N, -1},
+		// mean of Q->Q (2) and E->Q (-1)
+		{a.Z, a.Q, 0},
+		// mean of all amino acids against A: (2 - 19) / 20
+		{a.X, a.A, 0},
+		{a.Stop, a.A, -1},
+		{a.Stop, a.Stop, 1},
+	}
+	for _, c := range cases {
+		if score := matrix.Score(c.aa1, c.aa2); score != c.expect {
+			t.Errorf("Expected %v->%v to score %v, got %v",
+				a.ToString(c.aa1), a.ToString(c.aa2), c.expect, score)
+		}
+	}
+}
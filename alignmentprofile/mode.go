@@ -0,0 +1,46 @@
+package alignmentprofile
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Selects how the ends of the sequences are treated by the
+// alignment algorithm.
+type AlignmentMode int
+
+const (
+	// Leading and trailing gaps are free, so the alignment may start
+	// and end anywhere on either the nucleotide or the reference
+	// sequence. This is the default.
+	SemiGlobal AlignmentMode = iota
+	// Both sequences are aligned from end to end; leading and
+	// trailing gaps are penalized like any other indel.
+	Global
+	// Only the best scoring segment is aligned; the alignment may
+	// start and end in the middle of both sequences.
+	Local
+)
+
+var alignmentModeNames = []string{"semiglobal", "global", "local"}
+
+func (mode AlignmentMode) String() string {
+	if int(mode) < 0 || int(mode) >= len(alignmentModeNames) {
+		return fmt.Sprintf("AlignmentMode(%d)", int(mode))
+	}
+	return alignmentModeNames[mode]
+}
+
+// Parse the name of an alignment mode ("semiglobal", "global" or
+// "local"); the name is case-insensitive.
+func ParseAlignmentMode(name string) (AlignmentMode, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for mode, modeName := range alignmentModeNames {
+		if name == modeName {
+			return AlignmentMode(mode), nil
+		}
+	}
+	return SemiGlobal, fmt.Errorf(
+		"Unknown alignment mode '%v' (expecting one of %v)",
+		name, strings.Join(alignmentModeNames, ", "))
+}
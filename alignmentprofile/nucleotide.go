@@ -0,0 +1,57 @@
+package alignmentprofile
+
+import (
+	"fmt"
+	a "github.com/hivdb/nucamino/types/amino"
+	c "github.com/hivdb/nucamino/types/codon"
+	n "github.com/hivdb/nucamino/types/nucleic"
+	"github.com/hivdb/nucamino/utils"
+	"strings"
+)
+
+type NucleotideReferenceSeqs map[Gene][]n.NucleicAcid
+
+// Parse a nucleotide sequence of IUPAC codes; unlike
+// nucleic.ReadString, unknown codes are an error.
+func parseNucleotides(src string) ([]n.NucleicAcid, error) {
+	src = utils.StripWhiteSpace(src)
+	nas := make([]n.NucleicAcid, 0, len(src))
+	for idx, code := range src {
+		na, present := n.LookupNucleicAcid(code)
+		if !present {
+			return nil, fmt.Errorf("Invalid nucleotide '%c' at position %d", code, idx+1)
+		}
+		nas = append(nas, na)
+	}
+	return nas, nil
+}
+
+// Check that every nucleotide reference belongs to a gene and
+// translates to the amino acid reference of the gene. Reference
+// positions X, B and Z accept any codon.
+func (profile AlignmentProfile) validateNucleotideReferences() error {
+	for gene, nas := range profile.NucleotideReferenceSequences {
+		ref, found := profile.ReferenceSequences[gene]
+		if !found {
+			return fmt.Errorf("Nucleotide reference given for unknown gene '%v'", gene)
+		}
+		if len(nas) != len(ref)*3 {
+			return fmt.Errorf(
+				"The nucleotide reference of gene '%v' has %d bases instead of %d",
+				gene, len(nas), len(ref)*3)
+		}
+		for idx, aa := range ref {
+			if aa == a.X || aa == a.B || aa == a.Z {
+				continue
+			}
+			codon := c.Codon{nas[idx*3], nas[idx*3+1], nas[idx*3+2]}
+			if translated := codon.ToAminoAcidsText(); translated != a.ToString(aa) {
+				return fmt.Errorf(
+					"Codon %v at position %d of the nucleotide reference of gene '%v' translates to %v instead of %v",
+					codon.ToString(), idx+1, gene, strings.Join(strings.Split(translated, ""), "/"),
+					a.ToString(aa))
+			}
+		}
+	}
+	return nil
+}
@@ -1,6 +1,11 @@
 package alignmentprofile
 
-import "testing"
+import (
+	a "github.com/hivdb/nucamino/types/amino"
+	"reflect"
+	"strings"
+	"testing"
+)
 
 func TestUnmarshalEmpty(t *testing.T) {
 	src := ""
@@ -21,3 +26,77 @@ IndelCodonExtensionBonus: 0`
 		t.Errorf("Expected error when missing ReferenceSequences")
 	}
 }
+
+func TestParseAlignmentMode(t *testing.T) {
+	src := `AlignmentMode: Local
+ReferenceSequences:
+  A: TTALIEPPVYPIVEHSDEKTAHEEH`
+	profile, err := Parse(src)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if profile.AlignmentMode != Local {
+		t.Errorf("Expected alignment mode %v, got %v", Local, profile.AlignmentMode)
+	}
+	if formatted := Format(*profile); !strings.Contains(formatted, "\nAlignmentMode: local\n") {
+		t.Errorf("Expected alignment mode in formatted profile: %v", formatted)
+	}
+	_, err = Parse(strings.Replace(src, "Local", "partial", 1))
+	if err == nil {
+		t.Errorf("Expected error for unknown alignment mode")
+	}
+}
+
+func TestParseExtendedReferenceAminoAcids(t *testing.T) {
+	src := `ReferenceSequences:
+  A: TTALXEPB ZVY*`
+	profile, err := Parse(src)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expect := a.ReadString("TTALXEPBZVY*")
+	if refSeq := profile.ReferenceSequences["A"]; !reflect.DeepEqual(refSeq, expect) {
+		t.Errorf("Expected reference sequence %v, got %v", expect, refSeq)
+	}
+}
+
+func TestParseInvalidReferenceAminoAcid(t *testing.T) {
+	src := `ReferenceSequences:
+  A: TTALIEPPVYPIVEHSDEKTAHEEH
+  B: CSNEL VIS1HEADPVWRSAVLRGAP`
+	_, err := Parse(src)
+	if err == nil {
+		t.Fatalf("Expected error for invalid reference amino acid")
+	}
+	expect := "Invalid reference sequence for B: Invalid amino acid '1' at position 9"
+	if !strings.Contains(err.Error(), expect) {
+		t.Errorf("Expected error %q, got %q", expect, err.Error())
+	}
+}
+
+func TestParseThresholds(t *testing.T) {
+	src := `Thresholds:
+  MinScorePerCodon: 1.5
+  MaxFrameShifts: 0
+ReferenceSequences:
+  A: TTALIEPPVYPIVEHSDEKTAHEEH`
+	profile, err := Parse(src)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	thresholds := profile.Thresholds
+	if thresholds.MinScorePerCodon == nil || *thresholds.MinScorePerCodon != 1.5 ||
+		thresholds.MaxFrameShifts == nil || *thresholds.MaxFrameShifts != 0 ||
+		thresholds.MinCoverage != nil || thresholds.MaxStopCodons != nil {
+		t.Errorf("Unexpected thresholds: %#v", thresholds)
+	}
+	expect := "\nThresholds:\n  MinScorePerCodon: 1.5\n  MaxFrameShifts: 0\n"
+	if formatted := Format(*profile); !strings.Contains(formatted, expect) {
+		t.Errorf("Expected thresholds in formatted profile: %v", formatted)
+	}
+
+	_, err = Parse("Thresholds:\n  MinCoverage: 2\n" + src[strings.Index(src, "Reference"):])
+	if err == nil {
+		t.Errorf("Expected error for a coverage above 1")
+	}
+}
@@ -5,7 +5,9 @@ package alignmentprofile
 
 import (
 	"fmt"
+	d "github.com/hivdb/nucamino/data"
 	a "github.com/hivdb/nucamino/types/amino"
+	n "github.com/hivdb/nucamino/types/nucleic"
 	"sort"
 )
 
@@ -14,17 +16,92 @@ type PositionalIndelScores map[int]([2]int)
 type GenePositionalIndelScores map[Gene]PositionalIndelScores
 type ReferenceSeqs map[Gene][]a.AminoAcid
 
+// Overrides of the profile-wide alignment parameters for a single
+// gene. Nil fields fall back to the profile's value.
+type GeneParameters struct {
+	StopCodonPenalty         *int
+	GapOpeningPenalty        *int
+	GapExtensionPenalty      *int
+	IndelCodonOpeningBonus   *int
+	IndelCodonExtensionBonus *int
+	SubstitutionMatrix       *SubstitutionMatrix
+}
+
+// The limits an alignment has to meet to be reported; alignments
+// that don't are rejected. Nil fields aren't checked. The score per
+// codon is the alignment score divided by the number of aligned
+// reference positions, and the coverage is the aligned fraction of
+// the reference.
+type Thresholds struct {
+	MinScorePerCodon *float64 `yaml:"MinScorePerCodon"`
+	MinCoverage      *float64 `yaml:"MinCoverage"`
+	MaxFrameShifts   *int     `yaml:"MaxFrameShifts"`
+	MaxStopCodons    *int     `yaml:"MaxStopCodons"`
+}
+
+// Whether no threshold is set
+func (t Thresholds) IsEmpty() bool {
+	return t.MinScorePerCodon == nil && t.MinCoverage == nil &&
+		t.MaxFrameShifts == nil && t.MaxStopCodons == nil
+}
+
+// The thresholds of t, replaced by those that are set in overrides
+func (t Thresholds) Override(overrides Thresholds) Thresholds {
+	if overrides.MinScorePerCodon != nil {
+		t.MinScorePerCodon = overrides.MinScorePerCodon
+	}
+	if overrides.MinCoverage != nil {
+		t.MinCoverage = overrides.MinCoverage
+	}
+	if overrides.MaxFrameShifts != nil {
+		t.MaxFrameShifts = overrides.MaxFrameShifts
+	}
+	if overrides.MaxStopCodons != nil {
+		t.MaxStopCodons = overrides.MaxStopCodons
+	}
+	return t
+}
+
+// Check that the coverage is a fraction and that the maximums aren't
+// negative.
+func (t Thresholds) Validate() error {
+	if t.MinCoverage != nil && (*t.MinCoverage < 0 || *t.MinCoverage > 1) {
+		return fmt.Errorf("MinCoverage must be between 0 and 1, got %v", *t.MinCoverage)
+	}
+	if t.MaxFrameShifts != nil && *t.MaxFrameShifts < 0 {
+		return fmt.Errorf("MaxFrameShifts can't be negative, got %v", *t.MaxFrameShifts)
+	}
+	if t.MaxStopCodons != nil && *t.MaxStopCodons < 0 {
+		return fmt.Errorf("MaxStopCodons can't be negative, got %v", *t.MaxStopCodons)
+	}
+	return nil
+}
+
 // This stores the all the information needed to align a sequence to a
 // reference: reference sequences, alignment parameters, and
-// positional indel scores.
+// positional indel scores. A nil SubstitutionMatrix means the default
+// matrix (BLOSUM62) is used. GeneParameters optionally overrides the
+// parameters for individual genes. Thresholds decide which alignments
+// are rejected. Regions name parts of the reference sequences, which
+// can be aligned and reported like genes. NucleotideReferenceSequences
+// optionally give the codons of the reference of a gene, so that
+// changes that don't alter the amino acid can be reported, and
+// Genome optionally places the genes in a reference genome.
 type AlignmentProfile struct {
-	StopCodonPenalty         int
-	GapOpeningPenalty        int
-	GapExtensionPenalty      int
-	IndelCodonOpeningBonus   int
-	IndelCodonExtensionBonus int
-	GeneIndelScores          GenePositionalIndelScores
-	ReferenceSequences       ReferenceSeqs
+	StopCodonPenalty             int
+	GapOpeningPenalty            int
+	GapExtensionPenalty          int
+	IndelCodonOpeningBonus       int
+	IndelCodonExtensionBonus     int
+	AlignmentMode                AlignmentMode
+	Thresholds                   Thresholds
+	SubstitutionMatrix           *SubstitutionMatrix
+	GeneParameters               map[Gene]GeneParameters
+	GeneIndelScores              GenePositionalIndelScores
+	ReferenceSequences           ReferenceSeqs
+	NucleotideReferenceSequences NucleotideReferenceSeqs
+	Genome                       *Genome
+	Regions                      []Region
 }
 
 // An array of all the genes supported by this alignment profile.
@@ -67,6 +144,20 @@ func (profile AlignmentProfile) rawIndelScores() map[string][]rawIndelScore {
 	return result
 }
 
+func (params GeneParameters) asRaw() rawGeneParameters {
+	raw := rawGeneParameters{
+		StopCodonPenalty:         params.StopCodonPenalty,
+		GapOpeningPenalty:        params.GapOpeningPenalty,
+		GapExtensionPenalty:      params.GapExtensionPenalty,
+		IndelCodonOpeningBonus:   params.IndelCodonOpeningBonus,
+		IndelCodonExtensionBonus: params.IndelCodonExtensionBonus,
+	}
+	if params.SubstitutionMatrix != nil {
+		raw.RawSubstitutionMatrix = params.SubstitutionMatrix.asRaw()
+	}
+	return raw
+}
+
 func (profile AlignmentProfile) asRaw() rawAlignmentProfile {
 	var raw rawAlignmentProfile
 	raw.StopCodonPenalty = profile.StopCodonPenalty
@@ -75,27 +166,109 @@ func (profile AlignmentProfile) asRaw() rawAlignmentProfile {
 	raw.IndelCodonOpeningBonus = profile.IndelCodonOpeningBonus
 	raw.IndelCodonExtensionBonus = profile.IndelCodonExtensionBonus
 
+	if profile.AlignmentMode != SemiGlobal {
+		raw.AlignmentMode = profile.AlignmentMode.String()
+	}
+
+	if !profile.Thresholds.IsEmpty() {
+		thresholds := profile.Thresholds
+		raw.Thresholds = &thresholds
+	}
+
+	if profile.SubstitutionMatrix != nil {
+		raw.RawSubstitutionMatrix = profile.SubstitutionMatrix.asRaw()
+	}
+
+	if len(profile.GeneParameters) > 0 {
+		raw.RawGeneParameters = make(map[string]rawGeneParameters)
+		for gene, params := range profile.GeneParameters {
+			raw.RawGeneParameters[string(gene)] = params.asRaw()
+		}
+	}
+
 	raw.ReferenceSequences = make(map[string]string)
 	for gene, aaSeq := range profile.ReferenceSequences {
 		raw.ReferenceSequences[string(gene)] = a.WriteString(aaSeq)
 	}
 
+	if len(profile.NucleotideReferenceSequences) > 0 {
+		raw.NucleotideReferenceSequences = make(map[string]string)
+		for gene, naSeq := range profile.NucleotideReferenceSequences {
+			raw.NucleotideReferenceSequences[string(gene)] = n.WriteString(naSeq)
+		}
+	}
+
 	if profile.GeneIndelScores != nil {
 		raw.RawIndelScores = profile.rawIndelScores()
 	}
+
+	raw.Genome = profile.Genome
+	raw.Regions = profile.Regions
 	return raw
 }
 
+// A copy of the profile whose alignment parameters have been replaced
+// by the overrides for gene g, if there are any.
+func (profile AlignmentProfile) ForGene(g Gene) AlignmentProfile {
+	params, found := profile.GeneParameters[g]
+	if !found {
+		return profile
+	}
+	if params.StopCodonPenalty != nil {
+		profile.StopCodonPenalty = *params.StopCodonPenalty
+	}
+	if params.GapOpeningPenalty != nil {
+		profile.GapOpeningPenalty = *params.GapOpeningPenalty
+	}
+	if params.GapExtensionPenalty != nil {
+		profile.GapExtensionPenalty = *params.GapExtensionPenalty
+	}
+	if params.IndelCodonOpeningBonus != nil {
+		profile.IndelCodonOpeningBonus = *params.IndelCodonOpeningBonus
+	}
+	if params.IndelCodonExtensionBonus != nil {
+		profile.IndelCodonExtensionBonus = *params.IndelCodonExtensionBonus
+	}
+	if params.SubstitutionMatrix != nil {
+		profile.SubstitutionMatrix = params.SubstitutionMatrix
+	}
+	return profile
+}
+
+// The substitution matrix used for aligning against this profile
+func (profile *AlignmentProfile) SubstitutionMatrixOrDefault() *SubstitutionMatrix {
+	if profile.SubstitutionMatrix != nil {
+		return profile.SubstitutionMatrix
+	}
+	matrix, err := BuiltinSubstitutionMatrix(d.DefaultSubstitutionMatrix)
+	if err != nil {
+		panic(err)
+	}
+	return matrix
+}
+
 // Retrieve the positional indel scores for a Gene.
 func (profile *AlignmentProfile) PositionalIndelScoresFor(g Gene) (PositionalIndelScores, bool) {
 	scores, found := profile.GeneIndelScores[g]
 	return scores, found
 }
 
-// Check that the profile isn't empty
+// Check that the profile isn't empty and that its regions, nucleotide
+// references and genome are valid
 func (profile AlignmentProfile) validate() error {
 	if len(profile.ReferenceSequences) == 0 {
 		return fmt.Errorf("Missing key: ReferenceSequence")
 	}
-	return nil
+	for gene := range profile.GeneParameters {
+		if _, found := profile.ReferenceSequences[gene]; !found {
+			return fmt.Errorf("GeneParameters given for unknown gene '%v'", gene)
+		}
+	}
+	if err := profile.validateNucleotideReferences(); err != nil {
+		return err
+	}
+	if err := profile.validateGenome(); err != nil {
+		return err
+	}
+	return profile.validateRegions()
 }
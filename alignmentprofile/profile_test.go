@@ -90,3 +90,32 @@ func TestRetrievingPositionalIndelScores(t *testing.T) {
 		t.Errorf("Found positional indel scores for non-existent gene in example profile")
 	}
 }
+
+func TestForGene(t *testing.T) {
+	gapOpening := 20
+	matrix, _ := BuiltinSubstitutionMatrix("BLOSUM80")
+	profile := exampleProfile
+	profile.GeneParameters = map[Gene]GeneParameters{
+		"B": GeneParameters{
+			GapOpeningPenalty:  &gapOpening,
+			SubstitutionMatrix: matrix,
+		},
+	}
+	if geneProfile := profile.ForGene("A"); !reflect.DeepEqual(geneProfile, profile) {
+		t.Errorf("Expected gene A to use the profile-wide parameters")
+	}
+	geneProfile := profile.ForGene("B")
+	if geneProfile.GapOpeningPenalty != 20 {
+		t.Errorf("Expected gap opening penalty 20, got %v", geneProfile.GapOpeningPenalty)
+	}
+	if geneProfile.GapExtensionPenalty != profile.GapExtensionPenalty {
+		t.Errorf("Expected gap extension penalty %v, got %v",
+			profile.GapExtensionPenalty, geneProfile.GapExtensionPenalty)
+	}
+	if geneProfile.SubstitutionMatrix != matrix {
+		t.Errorf("Expected gene B to use BLOSUM80")
+	}
+	if profile.GapOpeningPenalty != 2 {
+		t.Errorf("ForGene modified the original profile")
+	}
+}
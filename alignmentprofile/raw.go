@@ -56,6 +56,35 @@ func (a byPositionAndKind) Less(i, j int) bool {
 	return false
 }
 
+// The per-gene overrides of a serialized profile. Parameters that
+// aren't given are left nil.
+type rawGeneParameters struct {
+	StopCodonPenalty         *int                   `yaml:"StopCodonPenalty"`
+	GapOpeningPenalty        *int                   `yaml:"GapOpeningPenalty"`
+	GapExtensionPenalty      *int                   `yaml:"GapExtensionPenalty"`
+	IndelCodonOpeningBonus   *int                   `yaml:"IndelCodonOpeningBonus"`
+	IndelCodonExtensionBonus *int                   `yaml:"IndelCodonExtensionBonus"`
+	RawSubstitutionMatrix    *rawSubstitutionMatrix `yaml:"SubstitutionMatrix"`
+}
+
+func (raw rawGeneParameters) asGeneParameters() (*GeneParameters, error) {
+	params := GeneParameters{
+		StopCodonPenalty:         raw.StopCodonPenalty,
+		GapOpeningPenalty:        raw.GapOpeningPenalty,
+		GapExtensionPenalty:      raw.GapExtensionPenalty,
+		IndelCodonOpeningBonus:   raw.IndelCodonOpeningBonus,
+		IndelCodonExtensionBonus: raw.IndelCodonExtensionBonus,
+	}
+	if raw.RawSubstitutionMatrix != nil {
+		matrix, err := raw.RawSubstitutionMatrix.asMatrix()
+		if err != nil {
+			return nil, err
+		}
+		params.SubstitutionMatrix = matrix
+	}
+	return &params, nil
+}
+
 // This is an intermediate datatype between an AlignmentProfile and
 // the YAML that represents it. The YAML is formatted for editing,
 // while the AlignmentProfile is formatted for ease of
@@ -63,13 +92,20 @@ func (a byPositionAndKind) Less(i, j int) bool {
 // converted to an AlignmentProfile, or contructed from an
 // AlignmentProfile.
 type rawAlignmentProfile struct {
-	StopCodonPenalty         int                        `yaml:"StopCodonPenalty"`
-	GapOpeningPenalty        int                        `yaml:"GapOpeningPenalty"`
-	GapExtensionPenalty      int                        `yaml:"GapExtensionPenalty"`
-	IndelCodonOpeningBonus   int                        `yaml:"IndelCodonOpeningBonus"`
-	IndelCodonExtensionBonus int                        `yaml:"IndelCodonExtensionBonus"`
-	RawIndelScores           map[string][]rawIndelScore `yaml:"PositionalIndelScores,flow"`
-	ReferenceSequences       map[string]string          `yaml:"ReferenceSequences"`
+	StopCodonPenalty             int                          `yaml:"StopCodonPenalty"`
+	GapOpeningPenalty            int                          `yaml:"GapOpeningPenalty"`
+	GapExtensionPenalty          int                          `yaml:"GapExtensionPenalty"`
+	IndelCodonOpeningBonus       int                          `yaml:"IndelCodonOpeningBonus"`
+	IndelCodonExtensionBonus     int                          `yaml:"IndelCodonExtensionBonus"`
+	AlignmentMode                string                       `yaml:"AlignmentMode"`
+	Thresholds                   *Thresholds                  `yaml:"Thresholds"`
+	RawSubstitutionMatrix        *rawSubstitutionMatrix       `yaml:"SubstitutionMatrix"`
+	RawGeneParameters            map[string]rawGeneParameters `yaml:"GeneParameters"`
+	RawIndelScores               map[string][]rawIndelScore   `yaml:"PositionalIndelScores,flow"`
+	ReferenceSequences           map[string]string            `yaml:"ReferenceSequences"`
+	NucleotideReferenceSequences map[string]string            `yaml:"NucleotideReferenceSequences"`
+	Genome                       *Genome                      `yaml:"Genome"`
+	Regions                      []Region                     `yaml:"Regions"`
 }
 
 // Construct a GenePositionalIndelScores instance from a
@@ -105,12 +141,61 @@ func (raw rawAlignmentProfile) asProfile() (*AlignmentProfile, error) {
 	profile.IndelCodonOpeningBonus = raw.IndelCodonOpeningBonus
 	profile.IndelCodonExtensionBonus = raw.IndelCodonExtensionBonus
 
+	if raw.AlignmentMode != "" {
+		mode, err := ParseAlignmentMode(raw.AlignmentMode)
+		if err != nil {
+			return nil, err
+		}
+		profile.AlignmentMode = mode
+	}
+
+	if raw.Thresholds != nil {
+		if err := raw.Thresholds.Validate(); err != nil {
+			return nil, fmt.Errorf("Invalid Thresholds: %v", err)
+		}
+		profile.Thresholds = *raw.Thresholds
+	}
+
+	if raw.RawSubstitutionMatrix != nil {
+		matrix, err := raw.RawSubstitutionMatrix.asMatrix()
+		if err != nil {
+			return nil, err
+		}
+		profile.SubstitutionMatrix = matrix
+	}
+
+	if len(raw.RawGeneParameters) > 0 {
+		profile.GeneParameters = make(map[Gene]GeneParameters)
+		for geneSrc, rawParams := range raw.RawGeneParameters {
+			params, err := rawParams.asGeneParameters()
+			if err != nil {
+				return nil, fmt.Errorf("Invalid GeneParameters for %v: %v", geneSrc, err)
+			}
+			profile.GeneParameters[Gene(geneSrc)] = *params
+		}
+	}
+
 	if len(raw.ReferenceSequences) == 0 {
 		return nil, fmt.Errorf("Missing key: ReferenceSequences")
 	} else {
 		profile.ReferenceSequences = make(ReferenceSeqs)
 		for geneSrc, aaSrc := range raw.ReferenceSequences {
-			profile.ReferenceSequences[Gene(geneSrc)] = a.ReadString(aaSrc)
+			aaSeq, err := a.Parse(aaSrc)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid reference sequence for %v: %v", geneSrc, err)
+			}
+			profile.ReferenceSequences[Gene(geneSrc)] = aaSeq
+		}
+	}
+
+	if len(raw.NucleotideReferenceSequences) > 0 {
+		profile.NucleotideReferenceSequences = make(NucleotideReferenceSeqs)
+		for geneSrc, naSrc := range raw.NucleotideReferenceSequences {
+			naSeq, err := parseNucleotides(naSrc)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid nucleotide reference sequence for %v: %v", geneSrc, err)
+			}
+			profile.NucleotideReferenceSequences[Gene(geneSrc)] = naSeq
 		}
 	}
 
@@ -124,5 +209,7 @@ func (raw rawAlignmentProfile) asProfile() (*AlignmentProfile, error) {
 		profile.GeneIndelScores = *geneIndelScores
 	}
 
+	profile.Genome = raw.Genome
+	profile.Regions = raw.Regions
 	return &profile, nil
 }
@@ -0,0 +1,98 @@
+package alignmentprofile
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A named part of the reference sequence of a gene, such as a
+// protein of a polyprotein. Start and End are positions in the
+// reference of Gene, counting from 1; both are part of the region.
+// Aliases are alternative names the region can be requested by.
+type Region struct {
+	Name    string   `yaml:"Name"`
+	Gene    Gene     `yaml:"Gene"`
+	Start   int      `yaml:"Start"`
+	End     int      `yaml:"End"`
+	Aliases []string `yaml:"Aliases,flow,omitempty"`
+}
+
+// Whether s is the name or one of the aliases of the region,
+// ignoring case
+func (r Region) Matches(s string) bool {
+	candidate := strings.TrimSpace(s)
+	if strings.EqualFold(candidate, r.Name) {
+		return true
+	}
+	for _, alias := range r.Aliases {
+		if strings.EqualFold(candidate, alias) {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether the gene position pos is part of the region
+func (r Region) Contains(pos int) bool {
+	return pos >= r.Start && pos <= r.End
+}
+
+// The number of reference positions of the region
+func (r Region) Length() int {
+	return r.End - r.Start + 1
+}
+
+// The position in the region of the gene position pos
+func (r Region) RelativePosition(pos int) int {
+	return pos - r.Start + 1
+}
+
+// The regions of gene g, in the order they were defined
+func (profile AlignmentProfile) RegionsOf(g Gene) []Region {
+	regions := make([]Region, 0)
+	for _, region := range profile.Regions {
+		if region.Gene == g {
+			regions = append(regions, region)
+		}
+	}
+	return regions
+}
+
+// Find the region that a name or alias refers to
+func (profile AlignmentProfile) FindRegion(s string) (Region, bool) {
+	for _, region := range profile.Regions {
+		if region.Matches(s) {
+			return region, true
+		}
+	}
+	return Region{}, false
+}
+
+// Check that every region lies within the reference of its gene, and
+// that no region name or alias is used twice or is the name of a
+// gene.
+func (profile AlignmentProfile) validateRegions() error {
+	names := make(map[string]bool)
+	for _, region := range profile.Regions {
+		ref, found := profile.ReferenceSequences[region.Gene]
+		if !found {
+			return fmt.Errorf("Region '%v' given for unknown gene '%v'", region.Name, region.Gene)
+		}
+		if region.Start < 1 || region.End < region.Start || region.End > len(ref) {
+			return fmt.Errorf(
+				"Region '%v' (%d-%d) isn't within the %d positions of gene '%v'",
+				region.Name, region.Start, region.End, len(ref), region.Gene)
+		}
+		for _, name := range append([]string{region.Name}, region.Aliases...) {
+			key := strings.ToUpper(strings.TrimSpace(name))
+			if key == "" {
+				return fmt.Errorf("Region of gene '%v' without a name", region.Gene)
+			}
+			if _, isGene := profile.ReferenceSequences[Gene(key)]; isGene || names[key] {
+				return fmt.Errorf("Region name '%v' is used more than once", name)
+			}
+			names[key] = true
+		}
+	}
+	return nil
+}
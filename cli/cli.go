@@ -1,29 +1,39 @@
 package cli
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
+	"github.com/hivdb/nucamino/aligner"
 	"github.com/hivdb/nucamino/alignment"
 	ap "github.com/hivdb/nucamino/alignmentprofile"
-	h "github.com/hivdb/nucamino/scorehandler/general"
-	a "github.com/hivdb/nucamino/types/amino"
+	"github.com/hivdb/nucamino/utils/compression"
 	"github.com/hivdb/nucamino/utils/fastareader"
+	"io"
 	"log"
 	"os"
 	"runtime"
-	"sync"
 )
 
+// The alignment result of a sequence against one gene. Index,
+// DetectedGenes, Diagnostics, Genotype and Genome are only set by the
+// JSON writer, since the JSON format has no per-sequence record.
 type AlignmentResult struct {
-	Name   string
-	Report *alignment.AlignmentReport
-	Error  string
-	Err    error
+	Index         int `json:",omitempty"`
+	Name          string
+	Report        *alignment.AlignmentReport
+	Error         string
+	Err           error
+	DetectedGenes []detectedGene           `json:",omitempty"`
+	Diagnostics   *fastareader.Diagnostics `json:",omitempty"`
+	Genotype      *genotypeCall            `json:",omitempty"`
+	Genome        *aligner.GenomePlacement `json:",omitempty"`
 }
 
 func validOutputFormat(format string) bool {
-	validFormats := []string{"json", "tsv"}
+	validFormats := []string{
+		"json", "ndjson", "tsv", "na-fasta", "aa-fasta",
+		"corrected-na-fasta", "corrected-aa-fasta", "vcf",
+	}
 	for _, validFormat := range validFormats {
 		if format == validFormat {
 			return true
@@ -32,99 +42,42 @@ func validOutputFormat(format string) bool {
 	return false
 }
 
-func writeTSV(
-	file *os.File, textGenes []string,
-	seqs []fastareader.Sequence, resultMap map[string][]AlignmentResult) {
-
-	genesCount := len(textGenes)
-	file.WriteString("Sequence Name")
-	for _, textGene := range textGenes {
-		file.WriteString("\t" + textGene + " FirstAA")
-		file.WriteString("\t" + textGene + " LastAA")
-		file.WriteString("\t" + textGene + " FirstNA")
-		file.WriteString("\t" + textGene + " LastNA")
-		file.WriteString("\t" + textGene + " Mutations")
-		file.WriteString("\t" + textGene + " FrameShifts")
-	}
-	file.WriteString("\n")
-	for _, seq := range seqs {
-		result := resultMap[seq.Name]
-		if result == nil {
-			continue
+func makeAlignmentResult(name string, geneResult aligner.GeneResult) AlignmentResult {
+	if geneResult.Err != nil {
+		err := geneResult.Err
+		if alnErr, ok := err.(*aligner.AlignmentError); ok {
+			err = alnErr.Err
 		}
-		file.WriteString(seq.Name)
-		for i := 0; i < genesCount; i++ {
-			err := result[i].Err
-			if err != nil {
-				file.WriteString("\tNA\tNA\tNA\tNA\tNA\tNA")
-				continue
-			}
-			r := result[i].Report
-			file.WriteString(fmt.Sprintf(
-				"\t%d\t%d\t%d\t%d\t%s\t%s",
-				r.FirstAA, r.LastAA,
-				r.FirstNA, r.LastNA,
-				func() string {
-					var muts bytes.Buffer
-					for _, mut := range r.Mutations {
-						muts.WriteString(mut.ToString())
-						muts.WriteString(",")
-					}
-					if muts.Len() > 0 {
-						muts.Truncate(muts.Len() - 1)
-					}
-					return muts.String()
-				}(),
-				func() string {
-					var fss bytes.Buffer
-					for _, fs := range r.FrameShifts {
-						fss.WriteString(fs.ToString())
-						fss.WriteString(",")
-					}
-					if fss.Len() > 0 {
-						fss.Truncate(fss.Len() - 1)
-					}
-					return fss.String()
-				}(),
-			))
-		}
-		file.WriteString("\n")
+		return AlignmentResult{Name: name, Error: err.Error(), Err: err}
 	}
+	return AlignmentResult{Name: name, Report: geneResult.Report}
 }
 
-func writeJSON(
-	file *os.File, textGenes []string,
-	seqs []fastareader.Sequence, resultMap map[string][]AlignmentResult) {
-
-	finalResultMap := make(map[string][]AlignmentResult)
-	genesCount := len(textGenes)
-
-	for i := 0; i < genesCount; i++ {
-		textGene := textGenes[i]
-		for _, seq := range seqs {
-			seqResult := resultMap[seq.Name]
-			if seqResult != nil {
-				seqGeneResult := seqResult[i]
-				finalResultMap[textGene] = append(finalResultMap[textGene], seqGeneResult)
-			}
+// The genes a sequence was detected in, and the fraction of each
+// gene's reference covered by the alignment
+func detectedGenes(geneResults []aligner.GeneResult) []detectedGene {
+	detected := make([]detectedGene, 0, 1)
+	for _, geneResult := range geneResults {
+		if geneResult.Report == nil {
+			continue
 		}
+		detected = append(detected, detectedGene{
+			Gene:     string(geneResult.Gene),
+			Coverage: geneResult.Report.Coverage,
+		})
 	}
-	result, err := json.MarshalIndent(finalResultMap, "", "  ")
-	if err != nil {
-		log.Fatal(err)
-	}
-	file.Write(result)
+	return detected
 }
 
-func seqSlice2Chan(s []fastareader.Sequence, bufferSize int) chan fastareader.Sequence {
-	c := make(chan fastareader.Sequence, bufferSize)
-	go func() {
-		for _, item := range s {
-			c <- item
+// The region of the profile named textGene, which is either a gene
+// or the name of a region
+func findRegion(profile ap.AlignmentProfile, textGene string) (ap.Region, bool) {
+	for _, region := range profile.Regions {
+		if region.Name == textGene {
+			return region, true
 		}
-		close(c)
-	}()
-	return c
+	}
+	return ap.Region{}, false
 }
 
 func PerformAlignment(
@@ -134,11 +87,123 @@ func PerformAlignment(
 	textGenes []string,
 	goroutines int,
 	quiet bool,
-	alignmentProfile ap.AlignmentProfile) error {
+	alignmentProfile ap.AlignmentProfile,
+	alignerOptions aligner.Options,
+	msaOptions MSAOptions,
+	vcfOptions VCFOptions,
+	duplicateNames DuplicateNamePolicy) error {
 
 	// Check output format
 	if !validOutputFormat(outputFormat) {
-		err := fmt.Errorf("Unknown output format %v. Options are: tsv, json", outputFormat)
+		err := fmt.Errorf("Unknown output format %v. Options are: tsv, json, ndjson, na-fasta, aa-fasta, corrected-na-fasta, corrected-aa-fasta, vcf", outputFormat)
+		return err
+	}
+
+	genes := make([]ap.Gene, len(textGenes))
+	for i, textGene := range textGenes {
+		genes[i] = ap.Gene(textGene)
+	}
+	aln, err := aligner.NewWithOptions(alignmentProfile, genes, alignerOptions)
+	if err != nil {
+		return err
+	}
+
+	var vcfGenes []vcfGene
+	vcfDir := ""
+	if outputFormat == "vcf" {
+		if vcfGenes, err = newVCFGenes(alignmentProfile, textGenes); err != nil {
+			return err
+		}
+		if vcfOptions.PerSequence {
+			if outputFileName == "-" {
+				return fmt.Errorf("Writing a VCF per sequence needs an output directory")
+			}
+			// the VCFs are written to the directory, nothing to the
+			// output
+			vcfDir, outputFileName = outputFileName, "-"
+		}
+	}
+
+	newWriter := func(output io.Writer) resultWriter {
+		switch outputFormat {
+		case "vcf":
+			return newVCFWriter(output, vcfGenes, vcfOptions, vcfDir)
+		case "na-fasta", "aa-fasta":
+			refLengths := make([]int, len(genes))
+			refOffsets := make([]int, len(genes))
+			for i, gene := range genes {
+				refLengths[i] = len(alignmentProfile.ReferenceSequences[gene])
+				if region, found := findRegion(alignmentProfile, textGenes[i]); found {
+					refLengths[i], refOffsets[i] = region.Length(), region.Start-1
+				}
+			}
+			writer := newMSAWriter(
+				output, textGenes, refLengths, msaOptions,
+				outputFormat == "aa-fasta")
+			writer.refOffsets = refOffsets
+			return writer
+		default:
+			writer := newResultWriter(outputFormat, output, textGenes, alignerOptions)
+			if tsv, ok := writer.(*tsvWriter); ok {
+				for i, gene := range genes {
+					if region, found := findRegion(alignmentProfile, textGenes[i]); found {
+						gene = region.Gene
+					}
+					if _, found := alignmentProfile.NucleotideReferenceSequences[gene]; found {
+						tsv.synonymous = true
+					}
+					if _, found := alignmentProfile.Genome.StartOf(gene); found {
+						tsv.genome = true
+					}
+				}
+			}
+			return writer
+		}
+	}
+	makeResult := func(result aligner.Result) (sequenceResult, bool) {
+		isSimpleAlignment := true
+		alnResults := make([]AlignmentResult, len(result.Genes))
+		for i, geneResult := range result.Genes {
+			alnResults[i] = makeAlignmentResult(result.Name, geneResult)
+			if geneResult.Report != nil {
+				isSimpleAlignment = isSimpleAlignment && geneResult.Report.IsSimpleAlignment
+			}
+		}
+		seqResult := sequenceResult{
+			Index: result.Index, Name: result.Name, Results: alnResults,
+			Sequence: result.Sequence, Diagnostics: result.Diagnostics,
+			Genome: result.Genome,
+		}
+		if alignerOptions.DetectGenes {
+			seqResult.DetectedGenes = detectedGenes(result.Genes)
+		}
+		return seqResult, isSimpleAlignment
+	}
+	return runAlignment(
+		inputFileName, outputFileName, goroutines, quiet, duplicateNames,
+		newWriter, aln.AlignStream, makeResult)
+}
+
+// Read the sequences of the input file, align them with stream and
+// write the results made by makeResult to the output file with the
+// writer returned by newWriter. makeResult also tells whether the
+// alignment was simple, which is shown by the progress indicator.
+func runAlignment(
+	inputFileName string,
+	outputFileName string,
+	goroutines int,
+	quiet bool,
+	duplicateNames DuplicateNamePolicy,
+	newWriter func(output io.Writer) resultWriter,
+	stream func(ctx context.Context, seqs <-chan fastareader.Sequence, goroutines int) <-chan aligner.Result,
+	makeResult func(result aligner.Result) (sequenceResult, bool)) error {
+
+	if !validDuplicateNamePolicy(duplicateNames) {
+		return fmt.Errorf(
+			"Unknown duplicate name policy %v. Options are: error, suffix, allow",
+			duplicateNames)
+	}
+	if err := compression.CheckOutputFileName(outputFileName); err != nil {
 		return err
 	}
 
@@ -155,95 +220,86 @@ func PerformAlignment(
 			numCPU, goroutines)
 	}
 
-	// Prepare input and output files
-	var input, output *os.File
-	var err error
+	// Prepare input and output files. Compressed input is detected
+	// automatically; the output is compressed if the name of the
+	// output file ends with a compression extension.
+	var (
+		inputFile, outputFile *os.File
+		err                   error
+	)
 
 	if inputFileName == "-" {
-		input = os.Stdin
+		inputFile = os.Stdin
 	} else {
-		input, err = os.Open(inputFileName)
+		inputFile, err = os.Open(inputFileName)
 		if err != nil {
 			return err
 		}
+		defer inputFile.Close()
+	}
+	input, err := compression.NewReader(inputFile)
+	if err != nil {
+		return err
 	}
+	defer input.Close()
 	if outputFileName == "-" {
-		output = os.Stdout
+		outputFile = os.Stdout
 	} else {
-		output, err = os.Create(outputFileName)
+		outputFile, err = os.Create(outputFileName)
 		if err != nil {
 			return err
 		}
+		defer outputFile.Close()
 	}
-
-	genesCount := len(textGenes)
-	genes := make([]ap.Gene, genesCount)
-	refs := make([][]a.AminoAcid, genesCount)
-	for i, textGene := range textGenes {
-		genes[i] = ap.Gene(textGene)
-		refs[i] = alignmentProfile.ReferenceSequences[genes[i]]
+	output, err := compression.NewWriter(outputFile, outputFileName)
+	if err != nil {
+		return err
 	}
+	writer := newWriter(output)
 
 	var (
-		wg         = sync.WaitGroup{}
-		seqs       = fastareader.ReadSequences(input)
-		resultChan = make(chan []AlignmentResult)
-		resultMap  = make(map[string][]AlignmentResult)
+		ctx            = context.Background()
+		seqs, readErrs = fastareader.StreamSequences(input, goroutines*4)
+		writeErr       = writer.WriteHeader()
+		seqCount       = 0
 	)
+	seqs, nameErrs := applyDuplicateNamePolicy(seqs, duplicateNames)
+	for result := range stream(ctx, seqs, goroutines) {
+		seqCount++
+		seqResult, isSimpleAlignment := makeResult(result)
+		if writeErr == nil {
+			writeErr = writer.WriteResult(seqResult)
+		}
+		if !quiet {
+			if isSimpleAlignment {
+				fmt.Fprintf(os.Stderr, ":")
+			} else {
+				fmt.Fprintf(os.Stderr, ".")
+			}
+		}
+	}
 	if !quiet {
-		logger.Printf("%d sequences were found from the input file.\n", len(seqs))
+		logger.Printf("\n")
 	}
-
-	var seqChan = seqSlice2Chan(seqs, goroutines*4)
-	for i := 0; i < goroutines; i++ {
-		wg.Add(1)
-		go func(idx int, rChan chan<- []AlignmentResult) {
-			scoreHandlers := make([]*h.GeneralScoreHandler, genesCount)
-			for i, gene := range genes {
-				scoreHandlers[i] = h.New(gene, alignmentProfile)
-			}
-			for seq := range seqChan {
-				isSimpleAlignment := true
-				result := make([]AlignmentResult, genesCount)
-				for i := 0; i < genesCount; i++ {
-					aligned, err := alignment.NewAlignment(seq.Sequence, refs[i], scoreHandlers[i])
-					if err != nil {
-						result[i] = AlignmentResult{seq.Name, nil, err.Error(), err}
-					} else {
-						r := aligned.GetReport()
-						result[i] = AlignmentResult{seq.Name, r, "", nil}
-						isSimpleAlignment = isSimpleAlignment && r.IsSimpleAlignment
-					}
-				}
-				rChan <- result
-				if !quiet {
-					if isSimpleAlignment {
-						fmt.Fprintf(os.Stderr, ":")
-					} else {
-						fmt.Fprintf(os.Stderr, ".")
-					}
-				}
-			}
-			wg.Done()
-		}(i, resultChan)
+	if writeErr == nil {
+		writeErr = writer.Close()
 	}
-	go func(rChan chan<- []AlignmentResult) {
-		wg.Wait()
-		if !quiet {
-			logger.Printf("\n")
-		}
-		close(rChan)
-	}(resultChan)
-	for result := range resultChan {
-		resultMap[result[0].Name] = result
-	}
-	switch outputFormat {
-	case "tsv":
-		writeTSV(output, textGenes, seqs, resultMap)
-		break
-	case "json":
-		writeJSON(output, textGenes, seqs, resultMap)
-		break
+	if closeErr := output.Close(); writeErr == nil {
+		writeErr = closeErr
+	}
+	if writeErr != nil {
+		return writeErr
+	}
+	if nameErr := <-nameErrs; nameErr != nil {
+		return nameErr
+	}
+	if readErr := <-readErrs; readErr != nil {
+		return fmt.Errorf(
+			"Failed to read %v after %d sequences: %v",
+			inputFileName, seqCount, readErr)
+	}
+	if !quiet {
+		logger.Printf("%d sequences were aligned.\n", seqCount)
 	}
 	if !quiet && outputFileName != "-" {
 		logger.Printf("Created alignment result file %s.", outputFileName)
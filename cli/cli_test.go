@@ -1,9 +1,26 @@
 package cli
 
-import "testing"
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/hivdb/nucamino/aligner"
+	"github.com/hivdb/nucamino/alignment"
+	ap "github.com/hivdb/nucamino/alignmentprofile"
+	a "github.com/hivdb/nucamino/types/amino"
+	m "github.com/hivdb/nucamino/types/mutation"
+	n "github.com/hivdb/nucamino/types/nucleic"
+	"github.com/hivdb/nucamino/utils/fastareader"
+	"reflect"
+	"strings"
+	"testing"
+)
 
 func TestValidOutputFormat(t *testing.T) {
-	okCases := []string{"json", "tsv"}
+	okCases := []string{
+		"json", "ndjson", "tsv", "na-fasta", "aa-fasta",
+		"corrected-na-fasta", "corrected-aa-fasta", "vcf",
+	}
 	for _, c := range okCases {
 		if !validOutputFormat(c) {
 			t.Errorf("Expected %v to be a valid output format", c)
@@ -16,3 +33,538 @@ func TestValidOutputFormat(t *testing.T) {
 		}
 	}
 }
+
+var exampleSequenceResults = []sequenceResult{
+	{
+		Name: "seq1",
+		Results: []AlignmentResult{
+			{Name: "seq1", Report: &alignment.AlignmentReport{
+				FirstAA: 1, LastAA: 2, FirstNA: 1, LastNA: 6,
+				Score: 9.5, NormalizedScore: 4.75,
+				PercentIdentity: 50, Coverage: 0.5, AmbiguousCodons: 1,
+				CorrectedNucleicAcids: "ATGNNA", CorrectedAminoAcids: "MX",
+			}},
+		},
+		Diagnostics: fastareader.Diagnostics{
+			InvalidCharacters: []fastareader.InvalidCharacter{{Position: 3, Character: "*"}},
+			StrippedGaps:      2,
+			SoftMaskedRanges:  []fastareader.Range{{First: 1, Last: 2}, {First: 5, Last: 6}},
+		},
+	},
+	{
+		Index: 1, Name: "seq2",
+		Results: []AlignmentResult{
+			{Name: "seq2", Error: "sequence misaligned", Err: errors.New("sequence misaligned")},
+		},
+	},
+}
+
+func TestResultWritersFlushEachRow(t *testing.T) {
+	var cases = []struct {
+		format string
+		lines  int
+	}{
+		{"tsv", 2},
+		{"ndjson", 1},
+		{"corrected-na-fasta", 2},
+	}
+	for _, c := range cases {
+		var output bytes.Buffer
+		writer := newResultWriter(c.format, &output, []string{"A"}, aligner.Options{})
+		writer.WriteHeader()
+		writer.WriteResult(exampleSequenceResults[0])
+		if lines := strings.Count(output.String(), "\n"); lines != c.lines {
+			t.Errorf(
+				"Expected %d lines to be written by the %v writer, got %d",
+				c.lines, c.format, lines)
+		}
+	}
+}
+
+func TestTSVWriter(t *testing.T) {
+	var output bytes.Buffer
+	writer := newResultWriter("tsv", &output, []string{"A"}, aligner.Options{})
+	writer.WriteHeader()
+	for _, result := range exampleSequenceResults {
+		writer.WriteResult(result)
+	}
+	writer.Close()
+	expect := "Sequence Index\tSequence Name\tInvalid Characters\tStripped Gaps\tSoft-masked Ranges" +
+		"\tA FirstAA\tA LastAA\tA FirstNA\tA LastNA\tA Mutations\tA FrameShifts" +
+		"\tA Score\tA NormalizedScore\tA PercentIdentity\tA Coverage" +
+		"\tA AmbiguousCodons\tA StopCodons\tA PartialCodons" +
+		"\tA InsertionCodons\tA DeletionCodons\tA Error\n" +
+		"1\tseq1\t*3\t2\t1-2,5-6\t1\t2\t1\t6\t\t\t9.50\t4.750\t50.0\t0.500\t1\t0\t0\t0\t0\t\n" +
+		"2\tseq2\t\t0\t" + strings.Repeat("\tNA", 15) + "\tsequence misaligned\n"
+	if output.String() != expect {
+		t.Errorf("Expect %#v but received %#v", expect, output.String())
+	}
+}
+
+// Two sequences aligned to a reference of four amino acids: seq1 has
+// a deletion at 2, an insertion after 3 and doesn't cover 4; seq2 has
+// a partial codon at 2.
+var exampleMSAResults = []sequenceResult{
+	{
+		Name: "seq1",
+		Results: []AlignmentResult{
+			{Name: "seq1", Report: &alignment.AlignmentReport{
+				AlignedSites: []alignment.AlignedSite{
+					{PosAA: 1, PosNA: 1, LengthNA: 3},
+					{PosAA: 2, PosNA: 4, LengthNA: 0},
+					{PosAA: 3, PosNA: 4, LengthNA: 6},
+				},
+			}},
+		},
+		Sequence: n.ReadString("ACGTTTGGG"),
+	},
+	{
+		Index: 1, Name: "seq2",
+		Results: []AlignmentResult{
+			{Name: "seq2", Report: &alignment.AlignmentReport{
+				Mutations: []m.Mutation{
+					{Position: 2, CodonText: "A C", IsPartial: true},
+				},
+				AlignedSites: []alignment.AlignedSite{
+					{PosAA: 1, PosNA: 1, LengthNA: 3},
+					{PosAA: 2, PosNA: 4, LengthNA: 2},
+					{PosAA: 3, PosNA: 6, LengthNA: 3},
+					{PosAA: 4, PosNA: 9, LengthNA: 3},
+				},
+			}},
+		},
+		Sequence: n.ReadString("ATGACTGGTAA"),
+	},
+	{
+		Index: 2, Name: "seq3",
+		Results: []AlignmentResult{
+			{Name: "seq3", Error: "sequence misaligned", Err: errors.New("sequence misaligned")},
+		},
+		Sequence: n.ReadString("ACG"),
+	},
+}
+
+func TestMSAWriter(t *testing.T) {
+	var cases = []struct {
+		aminoAcids bool
+		options    MSAOptions
+		expect     string
+	}{
+		{false, MSAOptions{}, ">seq1\nACG---TTT---\n>seq2\nATGA-CTGGTAA\n"},
+		{false, MSAOptions{KeepInsertions: true}, ">seq1\nACG---TTTGGG---\n>seq2\nATGA-CTGG---TAA\n"},
+		{false, MSAOptions{UncoveredAsN: true}, ">seq1\nACG---TTTNNN\n>seq2\nATGA-CTGGTAA\n"},
+		{true, MSAOptions{}, ">seq1\nT-F-\n>seq2\nMXW*\n"},
+		{true, MSAOptions{KeepInsertions: true}, ">seq1\nT-FG-\n>seq2\nMXW-*\n"},
+		{true, MSAOptions{UncoveredAsN: true}, ">seq1\nT-FX\n>seq2\nMXW*\n"},
+	}
+	for _, c := range cases {
+		var output bytes.Buffer
+		writer := newMSAWriter(&output, []string{"A"}, []int{4}, c.options, c.aminoAcids)
+		writer.WriteHeader()
+		for _, result := range exampleMSAResults {
+			writer.WriteResult(result)
+		}
+		writer.Close()
+		if output.String() != c.expect {
+			t.Errorf("Expect %#v but received %#v", c.expect, output.String())
+		}
+	}
+}
+
+func TestMSAWriterSpoolsGenes(t *testing.T) {
+	for _, options := range []MSAOptions{{}, {KeepInsertions: true}} {
+		var output bytes.Buffer
+		writer := newMSAWriter(&output, []string{"A", "B"}, []int{4, 4}, options, true)
+		for _, result := range exampleMSAResults {
+			result.Results = append(result.Results, result.Results[0])
+			writer.WriteResult(result)
+		}
+		// the rows of the first gene are only held back to pad insertions
+		if written := output.Len() > 0; written == options.KeepInsertions {
+			t.Errorf("Expected rows to be written before Close: %v, got %#v", !written, output.String())
+		}
+		writer.Close()
+		expect := ">seq1|A\nT-F-\n>seq2|A\nMXW*\n>seq1|B\nT-F-\n>seq2|B\nMXW*\n"
+		if options.KeepInsertions {
+			expect = ">seq1|A\nT-FG-\n>seq2|A\nMXW-*\n>seq1|B\nT-FG-\n>seq2|B\nMXW-*\n"
+		}
+		if output.String() != expect {
+			t.Errorf("Expect %#v but received %#v", expect, output.String())
+		}
+	}
+}
+
+func TestCorrectedWriter(t *testing.T) {
+	var cases = []struct {
+		format string
+		expect string
+	}{
+		{"corrected-na-fasta", ">seq1\nATGNNA\n"},
+		{"corrected-aa-fasta", ">seq1\nMX\n"},
+	}
+	for _, c := range cases {
+		var output bytes.Buffer
+		writer := newResultWriter(c.format, &output, []string{"A"}, aligner.Options{})
+		writer.WriteHeader()
+		for _, result := range exampleSequenceResults {
+			writer.WriteResult(result)
+		}
+		writer.Close()
+		if output.String() != c.expect {
+			t.Errorf("Expect %#v but received %#v", c.expect, output.String())
+		}
+	}
+}
+
+func TestJSONWritersDiagnostics(t *testing.T) {
+	for _, format := range []string{"json", "ndjson"} {
+		var output bytes.Buffer
+		writer := newResultWriter(format, &output, []string{"A"}, aligner.Options{})
+		writer.WriteHeader()
+		for _, result := range exampleSequenceResults {
+			writer.WriteResult(result)
+		}
+		writer.Close()
+		if count := strings.Count(output.String(), `"StrippedGaps"`); count != 1 {
+			t.Errorf("Expected the diagnostics of one sequence in %v, got %d", format, count)
+		}
+	}
+}
+
+func TestJSONWritersDetectedGenes(t *testing.T) {
+	result := exampleSequenceResults[0]
+	result.DetectedGenes = []detectedGene{{"A", 0.5}}
+	var cases = []struct {
+		format string
+		expect string
+	}{
+		{"json", `"Coverage": 0.5`},
+		{"ndjson", `"Coverage":0.5`},
+	}
+	for _, c := range cases {
+		var output bytes.Buffer
+		writer := newResultWriter(c.format, &output, []string{"A"}, aligner.Options{DetectGenes: true})
+		writer.WriteHeader()
+		writer.WriteResult(result)
+		writer.Close()
+		if !strings.Contains(output.String(), `"DetectedGenes"`) || !strings.Contains(output.String(), c.expect) {
+			t.Errorf("Expected the detected genes in %v output: %v", c.format, output.String())
+		}
+	}
+}
+
+func TestApplyDuplicateNamePolicy(t *testing.T) {
+	names := []string{"a", "b", "a", "a_2", "a", "b"}
+	var cases = []struct {
+		policy DuplicateNamePolicy
+		expect []string
+		err    error
+	}{
+		{DuplicateNamesAllow, names, nil},
+		{DuplicateNamesSuffix, []string{"a", "b", "a_2", "a_2_2", "a_3", "b_2"}, nil},
+		{DuplicateNamesError, []string{"a", "b"}, &DuplicateNameError{"a", 1, 3}},
+	}
+	for _, c := range cases {
+		seqs := make(chan fastareader.Sequence, len(names))
+		for _, name := range names {
+			seqs <- fastareader.NewSequence(name, "ACGT")
+		}
+		close(seqs)
+		out, errc := applyDuplicateNamePolicy(seqs, c.policy)
+		result := make([]string, 0, len(names))
+		for seq := range out {
+			result = append(result, seq.Name)
+		}
+		if !reflect.DeepEqual(result, c.expect) {
+			t.Errorf("Expected names %v with policy %v, got %v", c.expect, c.policy, result)
+		}
+		if err := <-errc; !reflect.DeepEqual(err, c.err) {
+			t.Errorf("Expected error %v with policy %v, got %v", c.err, c.policy, err)
+		}
+	}
+}
+
+func TestJSONWritersIndex(t *testing.T) {
+	var cases = []struct {
+		format string
+		expect []string
+	}{
+		{"json", []string{`"Index": 1,`, `"Index": 2,`}},
+		{"ndjson", []string{`{"Index":1,"Name":"seq1"`, `{"Index":2,"Name":"seq2"`}},
+	}
+	for _, c := range cases {
+		var output bytes.Buffer
+		writer := newResultWriter(c.format, &output, []string{"A"}, aligner.Options{})
+		writer.WriteHeader()
+		for _, result := range exampleSequenceResults {
+			writer.WriteResult(result)
+		}
+		writer.Close()
+		for _, expect := range c.expect {
+			if !strings.Contains(output.String(), expect) {
+				t.Errorf("Expected %v output to contain %v: %v", c.format, expect, output.String())
+			}
+		}
+	}
+}
+
+func TestFormatGenotype(t *testing.T) {
+	best := aligner.GeneResult{Report: &alignment.AlignmentReport{NormalizedScore: 4.5}}
+	second := aligner.GeneResult{Report: &alignment.AlignmentReport{NormalizedScore: 3.25}}
+	failed := aligner.GeneResult{Err: errors.New("rejected")}
+	var cases = []struct {
+		results  []aligner.GeneResult
+		profiles []string
+		expect   string
+	}{
+		{[]aligner.GeneResult{best, second}, []string{"p1", "p2"}, "\tp1\t4.500\tp2\t3.250\t1.250"},
+		{[]aligner.GeneResult{best, failed}, []string{"p1", "p2"}, "\tp1\t4.500\tNA\tNA\tNA"},
+		{[]aligner.GeneResult{failed, failed}, []string{"p1", "p2"}, "\tNA\tNA\tNA\tNA\tNA"},
+	}
+	for _, c := range cases {
+		result := formatGenotype(makeGenotypeCall(c.results, c.profiles, nil))
+		if result != c.expect {
+			t.Errorf("Expected genotype columns %q, got %q", c.expect, result)
+		}
+	}
+}
+
+func TestFormatRecombination(t *testing.T) {
+	best := aligner.GeneResult{Report: &alignment.AlignmentReport{NormalizedScore: 4.5}}
+	recombination := &aligner.Recombination{
+		Segments: []aligner.Segment{
+			{Profile: "p1", FirstNA: 1, LastNA: 300},
+			{Profile: "p2", FirstNA: 301, LastNA: 900},
+		},
+		Breakpoints: []aligner.Breakpoint{{Position: 301, From: "p1", To: "p2"}},
+	}
+	call := makeGenotypeCall([]aligner.GeneResult{best}, []string{"p1"}, recombination)
+	expect := "\tp1:1-300,p2:301-900\t301:p1>p2"
+	if result := formatRecombination(call); result != expect {
+		t.Errorf("Expected recombination columns %q, got %q", expect, result)
+	}
+	if result := formatRecombination(makeGenotypeCall(nil, nil, nil)); result != "\tNA\tNA" {
+		t.Errorf("Expected NA recombination columns, got %q", result)
+	}
+}
+
+func TestTSVWriterRegionNumbering(t *testing.T) {
+	report := &alignment.AlignmentReport{
+		FirstAA: 57, LastAA: 60, FirstNA: 1, LastNA: 12,
+		Mutations: []m.Mutation{
+			{Position: 59, ReferenceText: "L", AminoAcidText: "I", CodonText: "ATA",
+				Region: "PR", RegionPosition: 3},
+		},
+		Regions: []alignment.RegionRange{{Region: "PR", FirstAA: 1, LastAA: 4}},
+	}
+	result := sequenceResult{
+		Name: "seq1",
+		Results: []AlignmentResult{
+			{Name: "seq1", Report: report},
+			{Name: "seq1", Report: report},
+		},
+	}
+	var output bytes.Buffer
+	writer := newResultWriter("tsv", &output, []string{"PR", "POL"}, aligner.Options{})
+	writer.WriteResult(result)
+	writer.Close()
+	columns := strings.Split(output.String(), "\t")
+	// PR is written in region numbering, POL in gene numbering
+	if columns[5] != "1" || columns[9] != "L3I:ATA" ||
+		columns[21] != "57" || columns[25] != "L59I:ATA" {
+		t.Errorf("Unexpected region numbering: %#v", output.String())
+	}
+}
+
+func TestTSVWriterSynonymousChanges(t *testing.T) {
+	report := &alignment.AlignmentReport{
+		FirstAA: 57, LastAA: 60, FirstNA: 1, LastNA: 12,
+		Regions: []alignment.RegionRange{{Region: "PR", FirstAA: 1, LastAA: 4}},
+		SynonymousChanges: []alignment.CodonDifference{{
+			PosAA: 58, PosNA: 4, RefPosNA: 172, ReferenceCodon: "CAA",
+			CodonText: "CAG", Differences: 1, IsSynonymous: true,
+		}},
+	}
+	result := sequenceResult{
+		Name: "seq1",
+		Results: []AlignmentResult{
+			{Name: "seq1", Report: report},
+			{Name: "seq1", Report: report},
+		},
+	}
+	var output bytes.Buffer
+	writer := newResultWriter("tsv", &output, []string{"PR", "POL"}, aligner.Options{})
+	writer.(*tsvWriter).synonymous = true
+	writer.WriteHeader()
+	writer.WriteResult(result)
+	writer.Close()
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	header, columns := strings.Split(lines[0], "\t"), strings.Split(lines[1], "\t")
+	if header[21] != "PR SynonymousChanges" || columns[21] != "2:CAA>CAG" ||
+		header[38] != "POL SynonymousChanges" || columns[38] != "58:CAA>CAG" {
+		t.Errorf("Unexpected synonymous changes: %#v", output.String())
+	}
+}
+
+var vcfTestGene = vcfGene{
+	"A", "G1", 101,
+	n.ReadString("ACAGTATTAGTAGGACCTACACCTGTCAACATAATCGGACGAAATCTGCTGACCCAG"),
+	a.ReadString("TVLVGPTPVNIIGRNLLTQ"),
+}
+
+func TestNormalizeIndel(t *testing.T) {
+	ref := vcfTestGene.nucRef
+	cases := []struct {
+		pos         int
+		seq         string
+		isInsertion bool
+		expectPos   int
+		expectRef   string
+		expectAlt   string
+	}{
+		{30, "AAC", true, 26, "T", "TCAA"},
+		{36, "GGA", false, 36, "CGGA", "C"},
+		{9, "GTA", false, 7, "TTAG", "T"},
+		{0, "ACA", false, 1, "ACAG", "G"},
+		{1, "CA", false, 1, "ACA", "A"},
+		{1, "GA", true, 1, "A", "AGA"},
+		{0, "GT", true, 1, "A", "GTA"},
+	}
+	for _, c := range cases {
+		pos, vcfRef, alt := normalizeIndel(ref, c.pos, n.ReadString(c.seq), c.isInsertion)
+		if pos != c.expectPos || vcfRef != c.expectRef || alt != c.expectAlt {
+			t.Errorf(
+				"Expected %d %s>%s for %v, got %d %s>%s",
+				c.expectPos, c.expectRef, c.expectAlt, c, pos, vcfRef, alt)
+		}
+	}
+}
+
+func TestVCFWriter(t *testing.T) {
+	mixture := &alignment.AlignmentReport{
+		FirstAA: 1, LastAA: 10,
+		CodonDifferences: []alignment.CodonDifference{{
+			PosAA: 2, PosNA: 4, RefPosNA: 4, ReferenceCodon: "GTA",
+			CodonText: "GTR", Differences: 1, IsSynonymous: true,
+		}},
+	}
+	deletion := &alignment.AlignmentReport{
+		FirstAA: 1, LastAA: 19,
+		Mutations: []m.Mutation{
+			{Position: 13, ReferenceText: "G", IsDeletion: true},
+		},
+	}
+	anchored := &alignment.AlignmentReport{
+		FirstAA: 1, LastAA: 10,
+		CodonDifferences: []alignment.CodonDifference{
+			{PosAA: 3, PosNA: 7, RefPosNA: 7, ReferenceCodon: "TTA", CodonText: "TTG", Differences: 1, IsSynonymous: true},
+		},
+		Mutations: []m.Mutation{
+			{
+				Position: 3, ReferenceText: "L", AminoAcidText: "L", CodonText: "TTG",
+				IsInsertion: true, InsertedCodonsText: "CCT", InsertedAminoAcidsText: "P",
+			},
+		},
+	}
+	var output bytes.Buffer
+	writer := newVCFWriter(&output, []vcfGene{vcfTestGene}, VCFOptions{}, "")
+	writer.WriteHeader()
+	for i, report := range []*alignment.AlignmentReport{mixture, deletion, anchored} {
+		name := fmt.Sprintf("seq%d", i+1)
+		writer.WriteResult(sequenceResult{
+			Index: i, Name: name,
+			Results: []AlignmentResult{
+				{Index: i, Name: name, Report: report},
+			},
+		})
+	}
+	writer.Close()
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	expect := []string{
+		"#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO\tFORMAT\tseq1\tseq2\tseq3",
+		"G1\t106\t.\tA\tG\t.\t.\tGENE=A;AA=V2V;SYN\tGT\t0/1\t0\t0",
+		"G1\t109\t.\tA\tG\t.\t.\tGENE=A;AA=L3L_P;SYN\tGT\t0\t0\t1",
+		"G1\t109\t.\tA\tACCT\t.\t.\tGENE=A;AA=L3L_P\tGT\t0\t0\t1",
+		"G1\t136\t.\tCGGA\tC\t.\t.\tGENE=A;AA=G13-\tGT\t.\t1\t.",
+	}
+	if lines[2] != "##contig=<ID=G1>" || !reflect.DeepEqual(lines[len(lines)-5:], expect) {
+		t.Errorf("Unexpected VCF: %s", output.String())
+	}
+}
+
+func TestTSVWriterGenomeColumns(t *testing.T) {
+	report := &alignment.AlignmentReport{
+		FirstAA: 57, LastAA: 60, FirstNA: 1, LastNA: 12,
+		Mutations: []m.Mutation{
+			{Position: 59, ReferenceText: "L", AminoAcidText: "I", CodonText: "ATA", GenomePosition: 2259},
+		},
+		GenomeFirstNA: 2253, GenomeLastNA: 2264,
+	}
+	result := sequenceResult{
+		Name: "seq1",
+		Results: []AlignmentResult{
+			{Name: "seq1", Report: report},
+			{Name: "seq1", Error: "not aligned", Err: errors.New("not aligned")},
+		},
+	}
+	var output bytes.Buffer
+	writer := newResultWriter("tsv", &output, []string{"POL", "GAG"}, aligner.Options{})
+	writer.(*tsvWriter).genome = true
+	writer.WriteResult(result)
+	writer.Close()
+	columns := strings.Split(strings.TrimSpace(output.String()), "\t")
+	expect := []string{"2253", "2264", "2259", "", "NA", "NA", "NA", "NA"}
+	if result := append(columns[21:25], columns[41:]...); !reflect.DeepEqual(result, expect) {
+		t.Errorf("Expected genome columns %#v, got %#v", expect, result)
+	}
+}
+
+func TestTSVWriterPlacementColumns(t *testing.T) {
+	genome := &aligner.GenomePlacement{
+		Placements: []aligner.Placement{
+			{Gene: "GAG", FirstNA: 10, LastNA: 1509},
+			{Gene: "POL", FirstNA: 1305, LastNA: 4313},
+		},
+		InterGenicRegions: []aligner.InterGenicRegion{
+			{Before: "GAG", FirstNA: 1, LastNA: 9},
+			{After: "POL", FirstNA: 4314, LastNA: 4400},
+		},
+		UnplacedGenes: []ap.Gene{"GP41"},
+	}
+	var output bytes.Buffer
+	writer := newResultWriter("tsv", &output, []string{"GAG"}, aligner.Options{GenomeMode: true})
+	writer.WriteHeader()
+	writer.WriteResult(sequenceResult{
+		Name: "seq1",
+		Results: []AlignmentResult{
+			{Name: "seq1", Error: "not aligned", Err: errors.New("not aligned"), Genome: genome},
+		},
+		Genome: genome,
+	})
+	writer.WriteResult(sequenceResult{
+		Index: 1, Name: "seq2",
+		Results: []AlignmentResult{
+			{Index: 1, Name: "seq2", Error: "not aligned", Err: errors.New("not aligned")},
+		},
+	})
+	writer.Close()
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	header := strings.Split(lines[0], "\t")
+	idx := indexOfString(header, "Gene Placements")
+	if idx < 0 || header[idx+2] != "Unplaced Genes" {
+		t.Fatalf("Expected the placement columns in the header, got %#v", header)
+	}
+	var cases = []struct {
+		line   string
+		expect []string
+	}{
+		{lines[1], []string{"GAG:10-1509,POL:1305-4313", "5'>GAG:1-9,POL>3':4314-4400", "GP41"}},
+		{lines[2], []string{"NA", "NA", "NA"}},
+	}
+	for _, c := range cases {
+		columns := strings.Split(c.line, "\t")
+		if result := columns[idx : idx+3]; !reflect.DeepEqual(result, c.expect) {
+			t.Errorf("Expected placement columns %#v, got %#v", c.expect, result)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hivdb/nucamino/aligner"
+	ap "github.com/hivdb/nucamino/alignmentprofile"
+	"github.com/hivdb/nucamino/utils/fastareader"
+	"io"
+	"strings"
+)
+
+// Reported as the alignment error of sequences that couldn't be
+// aligned to any of the candidate profiles
+var errNoProfileAligned = errors.New("sequence couldn't be aligned to any profile")
+
+// The profile a sequence aligned best to (by normalized score), the
+// profile it aligned second best to and the difference between their
+// scores. RunnerUp is empty if the sequence aligned to only one
+// profile; Best is empty if it didn't align to any. Segments and
+// Breakpoints are only set when scanning for recombination.
+type genotypeCall struct {
+	Best          string
+	BestScore     float64
+	RunnerUp      string `json:",omitempty"`
+	RunnerUpScore float64
+	ScoreMargin   float64
+	Segments      []aligner.Segment    `json:",omitempty"`
+	Breakpoints   []aligner.Breakpoint `json:",omitempty"`
+}
+
+func makeGenotypeCall(
+	geneResults []aligner.GeneResult, profiles []string,
+	recombination *aligner.Recombination) *genotypeCall {
+	var call genotypeCall
+	if recombination != nil {
+		call.Segments = recombination.Segments
+		call.Breakpoints = recombination.Breakpoints
+	}
+	if len(geneResults) > 0 && geneResults[0].Report != nil {
+		call.Best = profiles[0]
+		call.BestScore = geneResults[0].Report.NormalizedScore
+	}
+	if len(geneResults) > 1 && geneResults[1].Report != nil {
+		call.RunnerUp = profiles[1]
+		call.RunnerUpScore = geneResults[1].Report.NormalizedScore
+		call.ScoreMargin = call.BestScore - call.RunnerUpScore
+	}
+	return &call
+}
+
+// The columns describing the genotype call of a sequence
+var tsvGenotypeColumns = []string{
+	"Best Profile", "Best NormalizedScore",
+	"Runner-up Profile", "Runner-up NormalizedScore", "Score Margin",
+}
+
+func formatGenotype(call *genotypeCall) string {
+	switch {
+	case call == nil || call.Best == "":
+		return strings.Repeat("\tNA", len(tsvGenotypeColumns))
+	case call.RunnerUp == "":
+		return fmt.Sprintf("\t%s\t%.3f\tNA\tNA\tNA", call.Best, call.BestScore)
+	}
+	return fmt.Sprintf(
+		"\t%s\t%.3f\t%s\t%.3f\t%.3f",
+		call.Best, call.BestScore,
+		call.RunnerUp, call.RunnerUpScore, call.ScoreMargin)
+}
+
+// The columns describing the segments of a sequence that match
+// different profiles, written when scanning for recombination
+var tsvRecombinationColumns = []string{"Profile Segments", "Breakpoints"}
+
+func formatRecombination(call *genotypeCall) string {
+	if call == nil || call.Best == "" {
+		return strings.Repeat("\tNA", len(tsvRecombinationColumns))
+	}
+	segments := make([]string, len(call.Segments))
+	for i, segment := range call.Segments {
+		segments[i] = segment.ToString()
+	}
+	breakpoints := make([]string, len(call.Breakpoints))
+	for i, breakpoint := range call.Breakpoints {
+		breakpoints[i] = breakpoint.ToString()
+	}
+	return fmt.Sprintf(
+		"\t%s\t%s", strings.Join(segments, ","), strings.Join(breakpoints, ","))
+}
+
+func validGenotypeOutputFormat(format string) bool {
+	return format == "tsv" || format == "json" || format == "ndjson"
+}
+
+// Align the sequences of the input file against the gene textGene of
+// each candidate profile, and write which profile each sequence
+// aligned best to, followed by the alignment against that profile.
+// profileNames are the names the profiles are reported by. Unless
+// scan is nil, each sequence is also scanned for segments that match
+// different profiles.
+func PerformGenotyping(
+	inputFileName string,
+	outputFileName string,
+	outputFormat string,
+	textGene string,
+	goroutines int,
+	quiet bool,
+	profileNames []string,
+	profiles []ap.AlignmentProfile,
+	alignerOptions aligner.Options,
+	scan *aligner.ScanOptions,
+	duplicateNames DuplicateNamePolicy) error {
+
+	if !validGenotypeOutputFormat(outputFormat) {
+		return fmt.Errorf("Unknown output format %v. Options are: tsv, json, ndjson", outputFormat)
+	}
+	genotyper, err := aligner.NewGenotyper(
+		profileNames, profiles, ap.Gene(textGene), alignerOptions)
+	if err != nil {
+		return err
+	}
+
+	textGenes := []string{textGene}
+	newWriter := func(output io.Writer) resultWriter {
+		writer := newResultWriter(outputFormat, output, textGenes, alignerOptions)
+		if tsv, ok := writer.(*tsvWriter); ok {
+			tsv.genotype = true
+			tsv.recombination = scan != nil
+		}
+		return writer
+	}
+	makeResult := func(result aligner.Result) (sequenceResult, bool) {
+		var alnResult AlignmentResult
+		if len(result.Genes) > 0 && result.Genes[0].Report != nil {
+			alnResult = makeAlignmentResult(result.Name, result.Genes[0])
+		} else {
+			alnResult = AlignmentResult{
+				Name: result.Name, Error: errNoProfileAligned.Error(), Err: errNoProfileAligned,
+			}
+		}
+		seqResult := sequenceResult{
+			Index: result.Index, Name: result.Name,
+			Results:  []AlignmentResult{alnResult},
+			Sequence: result.Sequence, Diagnostics: result.Diagnostics,
+			Genotype: makeGenotypeCall(result.Genes, result.Profiles, result.Recombination),
+		}
+		return seqResult, alnResult.Report != nil && alnResult.Report.IsSimpleAlignment
+	}
+	stream := genotyper.GenotypeStream
+	if scan != nil {
+		stream = func(
+			ctx context.Context, seqs <-chan fastareader.Sequence,
+			goroutines int) <-chan aligner.Result {
+			return genotyper.ScanStream(ctx, seqs, goroutines, *scan)
+		}
+	}
+	return runAlignment(
+		inputFileName, outputFileName, goroutines, quiet, duplicateNames,
+		newWriter, stream, makeResult)
+}
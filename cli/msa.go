@@ -0,0 +1,331 @@
+package cli
+
+import (
+	"bufio"
+	"github.com/hivdb/nucamino/alignment"
+	c "github.com/hivdb/nucamino/types/codon"
+	n "github.com/hivdb/nucamino/types/nucleic"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Options of the codon-aligned FASTA formats (na-fasta and aa-fasta)
+type MSAOptions struct {
+	// Keep the insertions as extra columns, padded with gaps in the
+	// sequences without an insertion at the same position. By default
+	// insertions are stripped so that every column is a reference
+	// position.
+	KeepInsertions bool
+	// Fill the reference positions not covered by a sequence with N (X
+	// for amino acids) instead of gaps.
+	UncoveredAsN bool
+}
+
+// One sequence of the alignment of a gene. sites[i] is the text of
+// reference position i+1; insertions[i] is the text inserted after
+// reference position i.
+type msaRow struct {
+	name       string
+	sites      []string
+	insertions map[int]string
+}
+
+// Writes the sequences aligned to each gene as a multiple sequence
+// alignment in FASTA format: one codon (or amino acid) per reference
+// position, so that every sequence of a gene has the same length.
+// Sequences that failed to align are left out. When more than one
+// gene was requested, the genes are written one after another and the
+// name of the gene is appended to each sequence name ("name|gene").
+//
+// The rows of the first gene are written as soon as they're aligned,
+// unless insertions are kept. The other rows are spooled to a
+// temporary file per gene and written by Close, once the width of the
+// insertion columns is known, so that memory use doesn't grow with
+// the number of sequences.
+type msaWriter struct {
+	file       *bufio.Writer
+	textGenes  []string
+	refLengths []int
+	// The number of gene positions before the start of each region,
+	// or nil if no region was requested
+	refOffsets []int
+	options    MSAOptions
+	aminoAcids bool
+	spools     []*msaSpool
+	// insertionWidths[g][i] is the length of the longest insertion
+	// after reference position i of gene g
+	insertionWidths [][]int
+}
+
+// A temporary file holding the rows of a gene until they're written
+type msaSpool struct {
+	file   *os.File
+	writer *bufio.Writer
+}
+
+// refLengths holds the length of the reference sequence of each
+// gene. If aminoAcids is set the translated sequences are written
+// instead of the nucleotides.
+func newMSAWriter(
+	output io.Writer, textGenes []string, refLengths []int,
+	options MSAOptions, aminoAcids bool) *msaWriter {
+	insertionWidths := make([][]int, len(textGenes))
+	for i, refLength := range refLengths {
+		insertionWidths[i] = make([]int, refLength+1)
+	}
+	return &msaWriter{
+		file:            bufio.NewWriter(output),
+		textGenes:       textGenes,
+		refLengths:      refLengths,
+		options:         options,
+		aminoAcids:      aminoAcids,
+		spools:          make([]*msaSpool, len(textGenes)),
+		insertionWidths: insertionWidths,
+	}
+}
+
+func (w *msaWriter) WriteHeader() error {
+	return nil
+}
+
+// Whether the rows of the gene can be written right away
+func (w *msaWriter) isStreamed(gene int) bool {
+	return gene == 0 && !w.options.KeepInsertions
+}
+
+func (w *msaWriter) WriteResult(result sequenceResult) error {
+	for i := range w.textGenes {
+		r := result.Results[i]
+		if r.Err != nil {
+			continue
+		}
+		nas := result.Sequence
+		if r.Report.IsReverseComplement {
+			nas = n.ReverseComplement(nas)
+		}
+		offset := 0
+		if w.refOffsets != nil {
+			offset = w.refOffsets[i]
+		}
+		row := w.makeRow(result.Name, r.Report, nas, offset, w.refLengths[i])
+		if w.isStreamed(i) {
+			w.writeRow(row, i)
+			if err := w.file.Flush(); err != nil {
+				return err
+			}
+			continue
+		}
+		for pos, insertion := range row.insertions {
+			if len(insertion) > w.insertionWidths[i][pos] {
+				w.insertionWidths[i][pos] = len(insertion)
+			}
+		}
+		if err := w.spool(row, i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Append a row to the spool of the gene. A row takes three lines:
+// the name, the sites, and the insertions as "pos=text" pairs.
+func (w *msaWriter) spool(row msaRow, gene int) error {
+	if w.spools[gene] == nil {
+		file, err := ioutil.TempFile("", "nucamino-msa-")
+		if err != nil {
+			return err
+		}
+		w.spools[gene] = &msaSpool{file, bufio.NewWriter(file)}
+	}
+	writer := w.spools[gene].writer
+	writer.WriteString(row.name + "\n" + strings.Join(row.sites, "") + "\n")
+	insertions := make([]string, 0, len(row.insertions))
+	for pos, insertion := range row.insertions {
+		insertions = append(insertions, strconv.Itoa(pos)+"="+insertion)
+	}
+	_, err := writer.WriteString(strings.Join(insertions, " ") + "\n")
+	return err
+}
+
+// Read back the next row of a spool, or return io.EOF
+func (w *msaWriter) readSpooledRow(reader *bufio.Reader) (msaRow, error) {
+	var lines [3]string
+	for i := range lines {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return msaRow{}, err
+		}
+		lines[i] = strings.TrimSuffix(line, "\n")
+	}
+	width := 3
+	if w.aminoAcids {
+		width = 1
+	}
+	row := msaRow{
+		name:       lines[0],
+		sites:      make([]string, len(lines[1])/width),
+		insertions: make(map[int]string),
+	}
+	for i := range row.sites {
+		row.sites[i] = lines[1][i*width : (i+1)*width]
+	}
+	for _, insertion := range strings.Fields(lines[2]) {
+		parts := strings.SplitN(insertion, "=", 2)
+		pos, _ := strconv.Atoi(parts[0])
+		row.insertions[pos] = parts[1]
+	}
+	return row, nil
+}
+
+// Write the spooled rows of a gene and remove its spool
+func (w *msaWriter) writeSpool(gene int) error {
+	spool := w.spools[gene]
+	defer os.Remove(spool.file.Name())
+	defer spool.file.Close()
+	if err := spool.writer.Flush(); err != nil {
+		return err
+	}
+	if _, err := spool.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	reader := bufio.NewReader(spool.file)
+	for {
+		row, err := w.readSpooledRow(reader)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		w.writeRow(row, gene)
+	}
+}
+
+func (w *msaWriter) Close() error {
+	var err error
+	for i := range w.textGenes {
+		if w.spools[i] == nil {
+			continue
+		}
+		if spoolErr := w.writeSpool(i); spoolErr != nil && err == nil {
+			err = spoolErr
+		}
+	}
+	if flushErr := w.file.Flush(); err == nil {
+		err = flushErr
+	}
+	return err
+}
+
+func (w *msaWriter) writeRow(row msaRow, gene int) {
+	file := w.file
+	file.WriteString(">" + row.name)
+	if len(w.textGenes) > 1 {
+		file.WriteString("|" + w.textGenes[gene])
+	}
+	file.WriteString("\n")
+	w.writeInsertion(row, gene, 0)
+	for pos, site := range row.sites {
+		file.WriteString(site)
+		w.writeInsertion(row, gene, pos+1)
+	}
+	file.WriteString("\n")
+}
+
+func (w *msaWriter) writeInsertion(row msaRow, gene int, pos int) {
+	if !w.options.KeepInsertions {
+		return
+	}
+	width := w.insertionWidths[gene][pos]
+	if width == 0 {
+		return
+	}
+	insertion := row.insertions[pos]
+	w.file.WriteString(insertion + strings.Repeat("-", width-len(insertion)))
+}
+
+// The reference positions of the row are the positions of the report
+// minus offset.
+func (w *msaWriter) makeRow(
+	name string, report *alignment.AlignmentReport,
+	nas []n.NucleicAcid, offset int, refLength int) msaRow {
+	uncovered := "---"
+	if w.options.UncoveredAsN {
+		uncovered = "NNN"
+	}
+	partialCodons := make(map[int]string)
+	for _, mut := range report.Mutations {
+		if mut.IsPartial {
+			partialCodons[mut.Position-offset] = strings.Replace(mut.CodonText, " ", "-", -1)
+		}
+	}
+
+	row := msaRow{
+		name:       name,
+		sites:      make([]string, refLength),
+		insertions: make(map[int]string),
+	}
+	for i := range row.sites {
+		row.sites[i] = uncovered
+	}
+	for _, site := range report.AlignedSites {
+		site.PosAA -= offset
+		if site.PosAA < 1 || site.PosAA > refLength {
+			continue
+		}
+		start := site.PosNA - 1
+		switch {
+		case site.LengthNA == 0:
+			row.sites[site.PosAA-1] = "---"
+		case site.LengthNA < 3:
+			codon, found := partialCodons[site.PosAA]
+			if !found {
+				codon = n.WriteString(nas[start:start+site.LengthNA]) +
+					strings.Repeat("-", 3-site.LengthNA)
+			}
+			row.sites[site.PosAA-1] = codon
+		default:
+			row.sites[site.PosAA-1] = n.WriteString(nas[start : start+3])
+			if site.LengthNA > 3 {
+				row.insertions[site.PosAA] = n.WriteString(nas[start+3 : start+site.LengthNA])
+			}
+		}
+	}
+	if w.aminoAcids {
+		for i, codon := range row.sites {
+			row.sites[i] = translateMSACodon(codon)
+		}
+		for pos, insertion := range row.insertions {
+			var aas string
+			for i := 0; i+3 <= len(insertion); i += 3 {
+				aas += translateMSACodon(insertion[i : i+3])
+			}
+			if aas == "" {
+				delete(row.insertions, pos)
+			} else {
+				row.insertions[pos] = aas
+			}
+		}
+	}
+	return row
+}
+
+// Translate a codon of the nucleotide alignment. Deletions (and
+// uncovered positions) are written as "-", partial codons and codons
+// that may encode more than one amino acid (including NNN) as "X".
+func translateMSACodon(codon string) string {
+	switch {
+	case codon == "---":
+		return "-"
+	case strings.Contains(codon, "-"):
+		return "X"
+	}
+	nas := n.ReadString(codon)
+	aas := (&c.Codon{nas[0], nas[1], nas[2]}).ToAminoAcidsText()
+	if len(aas) != 1 {
+		return "X"
+	}
+	return aas
+}
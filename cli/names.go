@@ -0,0 +1,95 @@
+package cli
+
+import (
+	"fmt"
+	"github.com/hivdb/nucamino/utils/fastareader"
+)
+
+// What to do with input sequences whose name was already used by an
+// earlier sequence
+type DuplicateNamePolicy string
+
+const (
+	// Stop with a DuplicateNameError
+	DuplicateNamesError DuplicateNamePolicy = "error"
+	// Rename the sequence by appending "_2", "_3", ... to its name
+	DuplicateNamesSuffix DuplicateNamePolicy = "suffix"
+	// Keep the name; the sequences can still be told apart by their
+	// index
+	DuplicateNamesAllow DuplicateNamePolicy = "allow"
+)
+
+func validDuplicateNamePolicy(policy DuplicateNamePolicy) bool {
+	switch policy {
+	case DuplicateNamesError, DuplicateNamesSuffix, DuplicateNamesAllow:
+		return true
+	}
+	return false
+}
+
+// Returned when a sequence name is used twice and the policy is
+// DuplicateNamesError. The indices count from 1.
+type DuplicateNameError struct {
+	Name      string
+	First     int
+	Duplicate int
+}
+
+func (e *DuplicateNameError) Error() string {
+	return fmt.Sprintf(
+		"Duplicate sequence name %v (sequences %d and %d)",
+		e.Name, e.First, e.Duplicate)
+}
+
+// Forward the sequences of seqs, renaming or rejecting the sequences
+// with a duplicate name according to policy. With DuplicateNamesError
+// the returned channel is closed at the first duplicate; the rest of
+// seqs is discarded. After the returned channel was closed, the error
+// channel receives the DuplicateNameError, or nil.
+func applyDuplicateNamePolicy(
+	seqs <-chan fastareader.Sequence,
+	policy DuplicateNamePolicy) (<-chan fastareader.Sequence, <-chan error) {
+	out := make(chan fastareader.Sequence, cap(seqs))
+	errc := make(chan error, 1)
+	go func() {
+		var (
+			// the index of the sequence that first used each name
+			names = make(map[string]int)
+			// the next suffix to try for each duplicated name
+			suffixes = make(map[string]int)
+			index    = 0
+		)
+		for seq := range seqs {
+			index++
+			if policy == DuplicateNamesAllow {
+				out <- seq
+				continue
+			}
+			if first, found := names[seq.Name]; found {
+				if policy == DuplicateNamesError {
+					close(out)
+					errc <- &DuplicateNameError{seq.Name, first, index}
+					for range seqs {
+					}
+					return
+				}
+				suffix := suffixes[seq.Name]
+				if suffix == 0 {
+					suffix = 2
+				}
+				name := fmt.Sprintf("%s_%d", seq.Name, suffix)
+				for _, taken := names[name]; taken; _, taken = names[name] {
+					suffix++
+					name = fmt.Sprintf("%s_%d", seq.Name, suffix)
+				}
+				suffixes[seq.Name] = suffix + 1
+				seq.Name = name
+			}
+			names[seq.Name] = index
+			out <- seq
+		}
+		close(out)
+		errc <- nil
+	}()
+	return out, errc
+}
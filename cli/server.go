@@ -0,0 +1,343 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hivdb/nucamino/aligner"
+	ap "github.com/hivdb/nucamino/alignmentprofile"
+	"github.com/hivdb/nucamino/alignmentprofile/builtin"
+	"github.com/hivdb/nucamino/utils/fastareader"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type ServerOptions struct {
+	// Requests with a larger body are rejected.
+	MaxRequestBytes int64
+	// Alignments that take longer are cancelled. Zero means no
+	// timeout.
+	Timeout time.Duration
+	// Number of goroutines used to align the sequences of a single
+	// request.
+	Goroutines int
+	// Number of requests aligned at the same time; the others wait
+	// for their turn, which counts against their timeout. Zero means
+	// no limit.
+	MaxConcurrentAlignments int
+	// Limits on reading the headers and the whole of a request, and
+	// on keeping an idle connection open. Zero means no limit.
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	IdleTimeout       time.Duration
+}
+
+// The body of a JSON alignment request. Exactly one of Profile and
+// ProfileYAML must be given; sequences can be passed either as a
+// list or as FASTA text (or both).
+type alignRequest struct {
+	Profile     string
+	ProfileYAML string
+	Genes       []string
+	Sequences   []struct {
+		Name     string
+		Sequence string
+	}
+	FASTA string
+}
+
+// Number of aligners the server keeps; once reached, an arbitrary
+// one is dropped to make room for the next.
+const maxCachedAligners = 64
+
+type server struct {
+	options ServerOptions
+	mux     *http.ServeMux
+	// Aligners for built-in profiles are kept across requests so
+	// that their score handlers don't have to be rebuilt each time.
+	// At most maxCachedAligners of them are kept.
+	alignersLock sync.Mutex
+	aligners     map[string]*aligner.Aligner
+	// Holds a token for each request being aligned, if the number
+	// of concurrent alignments is limited
+	alignments chan struct{}
+}
+
+func newServer(options ServerOptions) *server {
+	s := &server{
+		options:  options,
+		mux:      http.NewServeMux(),
+		aligners: make(map[string]*aligner.Aligner),
+	}
+	if options.MaxConcurrentAlignments > 0 {
+		s.alignments = make(chan struct{}, options.MaxConcurrentAlignments)
+	}
+	s.mux.HandleFunc("/align", s.handleAlign)
+	s.mux.HandleFunc("/profiles", s.handleProfiles)
+	return s
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Listen on addr and serve alignment requests until the server
+// fails. The server exposes two endpoints:
+//
+//	POST /align     align the posted sequences
+//	GET  /profiles  list the built-in profiles and their genes
+//
+// Sequences can be posted either as JSON or as FASTA. FASTA bodies
+// take the profile and genes from the query string, e.g.
+// "/align?profile=hiv1b&genes=PR,RT". The response has the same
+// layout as the "json" output format of the align command.
+func Serve(addr string, options ServerOptions) error {
+	logger := log.New(os.Stderr, "", 0)
+	logger.Printf("Listening on %s", addr)
+	return newHTTPServer(addr, options).ListenAndServe()
+}
+
+func newHTTPServer(addr string, options ServerOptions) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           newServer(options),
+		ReadHeaderTimeout: options.ReadHeaderTimeout,
+		ReadTimeout:       options.ReadTimeout,
+		IdleTimeout:       options.IdleTimeout,
+	}
+}
+
+func writeJSONError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(struct{ Error string }{err.Error()})
+}
+
+func (s *server) handleProfiles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, fmt.Errorf("Method %v not allowed", r.Method))
+		return
+	}
+	profiles := make(map[string][]string)
+	for _, name := range builtin.List() {
+		profile, _ := builtin.Get(name)
+		genes := make([]string, 0)
+		for _, gene := range profile.Genes() {
+			genes = append(genes, string(gene))
+		}
+		sort.Strings(genes)
+		profiles[name] = genes
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profiles)
+}
+
+// Resolve the genes of a request to the gene and region names of the
+// profile. The genes are sorted and deduplicated, so that requests
+// for the same genes share an aligner whatever order they list them
+// in.
+func findProfileGenes(profile *ap.AlignmentProfile, textGenes []string) ([]ap.Gene, error) {
+	profileGenes := profile.Genes()
+	genes := make([]ap.Gene, 0, len(textGenes))
+	for _, textGene := range textGenes {
+		found := false
+		for _, g := range profileGenes {
+			if g.Matches(textGene) {
+				genes = append(genes, g)
+				found = true
+				break
+			}
+		}
+		if region, isRegion := profile.FindRegion(textGene); !found && isRegion {
+			genes = append(genes, ap.Gene(region.Name))
+			found = true
+		}
+		if !found {
+			return nil, &aligner.UnknownGeneError{Gene: ap.Gene(textGene), Available: profileGenes}
+		}
+	}
+	sort.Slice(genes, func(i, j int) bool { return genes[i] < genes[j] })
+	unique := genes[:0]
+	for i, gene := range genes {
+		if i == 0 || gene != genes[i-1] {
+			unique = append(unique, gene)
+		}
+	}
+	return unique, nil
+}
+
+func (s *server) getAligner(profileName, profileYAML string, textGenes []string) (*aligner.Aligner, error) {
+	var (
+		profile *ap.AlignmentProfile
+		found   bool
+		err     error
+	)
+	if profileYAML != "" {
+		if profileName != "" {
+			return nil, fmt.Errorf("Only one of Profile and ProfileYAML can be given")
+		}
+		profile, err = ap.Parse(profileYAML)
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing alignment profile: %v", err)
+		}
+	} else {
+		profile, found = builtin.Get(profileName)
+		if !found {
+			return nil, fmt.Errorf("Unknown profile name: '%v'", profileName)
+		}
+	}
+	genes, err := findProfileGenes(profile, textGenes)
+	if err != nil {
+		return nil, err
+	}
+	if profileYAML != "" {
+		return aligner.New(*profile, genes)
+	}
+
+	key := profileName
+	for _, gene := range genes {
+		key += "\x00" + string(gene)
+	}
+	s.alignersLock.Lock()
+	defer s.alignersLock.Unlock()
+	aln, found := s.aligners[key]
+	if !found {
+		aln, err = aligner.New(*profile, genes)
+		if err != nil {
+			return nil, err
+		}
+		if len(s.aligners) >= maxCachedAligners {
+			for other := range s.aligners {
+				delete(s.aligners, other)
+				break
+			}
+		}
+		s.aligners[key] = aln
+	}
+	return aln, nil
+}
+
+// Read the profile, genes and sequences of an alignment request
+func (s *server) parseAlignRequest(w http.ResponseWriter, r *http.Request) (*alignRequest, []fastareader.Sequence, error) {
+	var req alignRequest
+	body := http.MaxBytesReader(w, r.Body, s.options.MaxRequestBytes)
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(body).Decode(&req); err != nil {
+			return nil, nil, fmt.Errorf("Invalid request: %w", err)
+		}
+	} else {
+		query := r.URL.Query()
+		req.Profile = query.Get("profile")
+		if genes := query.Get("genes"); genes != "" {
+			req.Genes = strings.Split(genes, ",")
+		}
+		fasta, err := ioutil.ReadAll(body)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Invalid request: %w", err)
+		}
+		req.FASTA = string(fasta)
+	}
+	if len(req.Genes) == 0 {
+		return nil, nil, fmt.Errorf("Invalid request: no genes were given")
+	}
+
+	seqs := make([]fastareader.Sequence, 0, len(req.Sequences))
+	for idx, seq := range req.Sequences {
+		name := seq.Name
+		if name == "" {
+			name = fmt.Sprintf("unnamed sequence %d", idx+1)
+		}
+		seqs = append(seqs, fastareader.NewSequence(name, seq.Sequence))
+	}
+	if req.FASTA != "" {
+		fastaSeqs, err := fastareader.ReadSequences(strings.NewReader(req.FASTA))
+		if err != nil {
+			return nil, nil, fmt.Errorf("Invalid request: %v", err)
+		}
+		seqs = append(seqs, fastaSeqs...)
+	}
+	return &req, seqs, nil
+}
+
+func (s *server) handleAlign(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, fmt.Errorf("Method %v not allowed", r.Method))
+		return
+	}
+	req, seqs, err := s.parseAlignRequest(w, r)
+	if err != nil {
+		status := http.StatusBadRequest
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		writeJSONError(w, status, err)
+		return
+	}
+	aln, err := s.getAligner(req.Profile, req.ProfileYAML, req.Genes)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	if s.options.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(r.Context(), s.options.Timeout)
+	}
+	defer cancel()
+	if s.alignments != nil {
+		select {
+		case s.alignments <- struct{}{}:
+			defer func() { <-s.alignments }()
+		case <-ctx.Done():
+			writeJSONError(w, http.StatusServiceUnavailable, fmt.Errorf("Too many alignments in progress: %v", ctx.Err()))
+			return
+		}
+	}
+	seqChan := make(chan fastareader.Sequence, len(seqs))
+	for _, seq := range seqs {
+		seqChan <- seq
+	}
+	close(seqChan)
+
+	textGenes := make([]string, 0, len(req.Genes))
+	for _, gene := range aln.Genes() {
+		textGenes = append(textGenes, string(gene))
+	}
+	results := make([]sequenceResult, 0, len(seqs))
+	for result := range aln.AlignStream(ctx, seqChan, s.options.Goroutines) {
+		if result.Err != nil {
+			err = result.Err
+			continue
+		}
+		alnResults := make([]AlignmentResult, len(result.Genes))
+		for i, geneResult := range result.Genes {
+			alnResults[i] = makeAlignmentResult(result.Name, geneResult)
+		}
+		results = append(results, sequenceResult{
+			Index: result.Index, Name: result.Name, Results: alnResults,
+			Diagnostics: result.Diagnostics,
+		})
+	}
+	if err == nil && len(results) < len(seqs) {
+		err = ctx.Err()
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusGatewayTimeout, fmt.Errorf("Alignment did not finish: %v", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writer := newResultWriter("json", w, textGenes, aligner.Options{})
+	for _, result := range results {
+		writer.WriteResult(result)
+	}
+	writer.Close()
+}
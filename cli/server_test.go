@@ -0,0 +1,189 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var exampleServerOptions = ServerOptions{
+	MaxRequestBytes: 1024,
+	Timeout:         10 * time.Second,
+	Goroutines:      2,
+}
+
+const exampleFASTA = `>seq1
+CCTCAGATCACTCTTTGGCAACGACCCCTCGTCACAATAAAGATAGGGGGGCAACTAAAGGAAGCTCTATTAGATACAGGAGCAGATGATACAGTA
+>seq2
+AAAAAAAAAAAAAAAAAAAAAAAAAAAA
+`
+
+func TestServerProfiles(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest("GET", "/profiles", nil)
+	newServer(exampleServerOptions).ServeHTTP(recorder, request)
+	var profiles map[string][]string
+	if err := json.Unmarshal(recorder.Body.Bytes(), &profiles); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.Join(profiles["hiv1b"], ",") != "GAG,GP41,POL" {
+		t.Errorf("Expect %#v but received %#v", []string{"GAG", "GP41", "POL"}, profiles["hiv1b"])
+	}
+}
+
+func TestServerAlign(t *testing.T) {
+	jsonBody, _ := json.Marshal(alignRequest{
+		Profile: "hiv1b",
+		Genes:   []string{"POL"},
+		FASTA:   exampleFASTA,
+	})
+	var requests = []*http.Request{
+		httptest.NewRequest(
+			"POST", "/align?profile=hiv1b&genes=pol",
+			strings.NewReader(exampleFASTA)),
+		httptest.NewRequest("POST", "/align", bytes.NewReader(jsonBody)),
+	}
+	requests[1].Header.Set("Content-Type", "application/json")
+	s := newServer(exampleServerOptions)
+	for _, request := range requests {
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, request)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Unexpected status %d: %s", recorder.Code, recorder.Body.String())
+		}
+		var results map[string][]AlignmentResult
+		if err := json.Unmarshal(recorder.Body.Bytes(), &results); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		pol := results["POL"]
+		if len(pol) != 2 || pol[0].Name != "seq1" || pol[1].Name != "seq2" ||
+			pol[0].Report == nil || pol[0].Report.FirstAA != 57 {
+			t.Errorf("Unexpected POL results: %#v", pol)
+		}
+	}
+}
+
+func TestServerAlignErrors(t *testing.T) {
+	var cases = []struct {
+		request *http.Request
+		status  int
+	}{
+		{httptest.NewRequest("GET", "/align", nil), http.StatusMethodNotAllowed},
+		{httptest.NewRequest("POST", "/align?profile=hiv1b", strings.NewReader(exampleFASTA)), http.StatusBadRequest},
+		{httptest.NewRequest("POST", "/align?profile=nope&genes=POL", strings.NewReader(exampleFASTA)), http.StatusBadRequest},
+		{httptest.NewRequest("POST", "/align?profile=hiv1b&genes=NS3", strings.NewReader(exampleFASTA)), http.StatusBadRequest},
+		{httptest.NewRequest("POST", "/align?profile=hiv1b&genes=POL", strings.NewReader(strings.Repeat(exampleFASTA, 10))), http.StatusRequestEntityTooLarge},
+		{jsonRequest(`{"Profile": "hiv1b", "Genes": ["POL"], "FASTA": "` + strings.Repeat("A", 2048) + `"}`), http.StatusRequestEntityTooLarge},
+	}
+	s := newServer(exampleServerOptions)
+	for _, c := range cases {
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, c.request)
+		if recorder.Code != c.status {
+			t.Errorf("Expected status %d for %v, got %d", c.status, c.request.URL, recorder.Code)
+		}
+	}
+}
+
+func jsonRequest(body string) *http.Request {
+	request := httptest.NewRequest("POST", "/align", strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	return request
+}
+
+func TestServerLimitsConcurrentAlignments(t *testing.T) {
+	options := exampleServerOptions
+	options.MaxConcurrentAlignments = 1
+	options.Timeout = 200 * time.Millisecond
+	s := newServer(options)
+	request := func() int {
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, httptest.NewRequest(
+			"POST", "/align?profile=hiv1b&genes=pol", strings.NewReader(exampleFASTA)))
+		return recorder.Code
+	}
+	// an alignment in progress makes the next request wait
+	s.alignments <- struct{}{}
+	if status := request(); status != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d while busy, got %d", http.StatusServiceUnavailable, status)
+	}
+	<-s.alignments
+	if status := request(); status != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, status)
+	}
+}
+
+func TestServerTimeouts(t *testing.T) {
+	options := exampleServerOptions
+	options.ReadHeaderTimeout = time.Second
+	options.ReadTimeout = 2 * time.Second
+	options.IdleTimeout = 3 * time.Second
+	server := newHTTPServer(":0", options)
+	if server.ReadHeaderTimeout != time.Second || server.ReadTimeout != 2*time.Second ||
+		server.IdleTimeout != 3*time.Second {
+		t.Errorf("Expected the timeouts of %#v, got %v, %v and %v", options,
+			server.ReadHeaderTimeout, server.ReadTimeout, server.IdleTimeout)
+	}
+}
+
+func TestServerReadHeaderTimeout(t *testing.T) {
+	options := exampleServerOptions
+	options.ReadHeaderTimeout = 100 * time.Millisecond
+	server := newHTTPServer("127.0.0.1:0", options)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	go server.Serve(listener)
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer conn.Close()
+	// send a partial request and wait for the server to hang up
+	conn.Write([]byte("GET /profiles HTTP/1.1\r\n"))
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := ioutil.ReadAll(conn); err != nil {
+		t.Errorf("Expected the server to close a slow connection, got %v", err)
+	}
+}
+
+func TestServerAlignerCache(t *testing.T) {
+	s := newServer(exampleServerOptions)
+	first, err := s.getAligner("hiv1b", "", []string{"pol", "gag"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	second, err := s.getAligner("hiv1b", "", []string{"GAG", "POL", "gag"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if first != second {
+		t.Errorf("Expected the same genes in another order to share an aligner")
+	}
+	if genes := first.Genes(); len(genes) != 2 || genes[0] != "GAG" || genes[1] != "POL" {
+		t.Errorf("Expect %#v but received %#v", []string{"GAG", "POL"}, genes)
+	}
+	if len(s.aligners) != 1 {
+		t.Errorf("Expected one cached aligner, got %d", len(s.aligners))
+	}
+
+	for len(s.aligners) < maxCachedAligners {
+		s.aligners[fmt.Sprintf("profile %d", len(s.aligners))] = first
+	}
+	if _, err := s.getAligner("hiv1b", "", []string{"GP41"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(s.aligners) > maxCachedAligners {
+		t.Errorf("Expected at most %d cached aligners, got %d", maxCachedAligners, len(s.aligners))
+	}
+}
@@ -0,0 +1,490 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/hivdb/nucamino/alignment"
+	ap "github.com/hivdb/nucamino/alignmentprofile"
+	a "github.com/hivdb/nucamino/types/amino"
+	m "github.com/hivdb/nucamino/types/mutation"
+	n "github.com/hivdb/nucamino/types/nucleic"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Options of the vcf format
+type VCFOptions struct {
+	// Write one VCF per sequence into the directory given as the
+	// output file, instead of one VCF with a sample per sequence.
+	PerSequence bool
+}
+
+// Where the variants of a requested gene are placed: the contig, the
+// contig position of the first base of the nucleotide reference of
+// the gene, and the references of the gene. The contig is the genome
+// of the profile if it places the gene, otherwise the gene itself.
+type vcfGene struct {
+	gene   ap.Gene
+	chrom  string
+	start  int
+	nucRef []n.NucleicAcid
+	aaRef  []a.AminoAcid
+}
+
+// The placement of each requested gene; every gene (or the gene of
+// every region) needs a nucleotide reference.
+func newVCFGenes(profile ap.AlignmentProfile, textGenes []string) ([]vcfGene, error) {
+	genes := make([]vcfGene, len(textGenes))
+	for i, textGene := range textGenes {
+		gene := ap.Gene(textGene)
+		if region, found := findRegion(profile, textGene); found {
+			gene = region.Gene
+		}
+		nucRef, found := profile.NucleotideReferenceSequences[gene]
+		if !found {
+			return nil, fmt.Errorf(
+				"The vcf format needs a nucleotide reference sequence of gene %v in the profile", gene)
+		}
+		genes[i] = vcfGene{gene, string(gene), 1, nucRef, profile.ReferenceSequences[gene]}
+		if start, found := profile.Genome.StartOf(gene); found {
+			genes[i].chrom, genes[i].start = profile.Genome.Name, start
+		}
+	}
+	return genes, nil
+}
+
+// A variant of a sequence. pos is the position of the first base of
+// ref in the nucleotide reference of the gene. alleles are the
+// alleles of the sequence, which include ref if the sequence has a
+// mixture of the reference and another base.
+type vcfVariant struct {
+	pos          int
+	ref          string
+	alleles      []string
+	aminoAcids   []string
+	isSynonymous bool
+	isIndel      bool
+	isFrameShift bool
+}
+
+// Write nucleotides for a REF or ALT column; ambiguous reference
+// bases are written as N.
+func vcfBases(nas []n.NucleicAcid) string {
+	bases := []byte(n.WriteString(nas))
+	for i, na := range nas {
+		if na.IsAmbiguous() {
+			bases[i] = 'N'
+		}
+	}
+	return string(bases)
+}
+
+// The amino acid change of a mutation, without its codons
+func aminoAcidChange(mut *m.Mutation) string {
+	return strings.SplitN(mut.ToString(), ":", 2)[0]
+}
+
+// Normalize an indel of the bases seq after position pos of the
+// reference: shift it to the leftmost position where it has the same
+// effect, up to the beginning of the reference, and anchor it to the
+// base before it, or to the base after it if it starts at the
+// beginning of the reference. Positions count from 1. Returns the
+// position, the REF and the ALT of the indel.
+func normalizeIndel(
+	ref []n.NucleicAcid, pos int, seq []n.NucleicAcid,
+	isInsertion bool) (int, string, string) {
+	if isInsertion {
+		seq = append([]n.NucleicAcid(nil), seq...)
+		for pos > 0 && ref[pos-1] == seq[len(seq)-1] {
+			seq = append([]n.NucleicAcid{ref[pos-1]}, seq[:len(seq)-1]...)
+			pos--
+		}
+		if pos == 0 {
+			return 1, vcfBases(ref[:1]), vcfBases(seq) + vcfBases(ref[:1])
+		}
+		return pos, vcfBases(ref[pos-1 : pos]), vcfBases(ref[pos-1:pos]) + vcfBases(seq)
+	}
+	length := len(seq)
+	for pos > 0 && ref[pos-1] == ref[pos+length-1] {
+		pos--
+	}
+	if pos == 0 {
+		return 1, vcfBases(ref[:length+1]), vcfBases(ref[length : length+1])
+	}
+	return pos, vcfBases(ref[pos-1 : pos+length]), vcfBases(ref[pos-1 : pos])
+}
+
+// The variants of a report against the nucleotide reference of its
+// gene: the bases that differ from the reference, the codon
+// insertions and deletions, and the frameshifts. Consecutive deleted
+// codons are a single deletion. The bases a frameshift deletion
+// removes aren't known, so they are taken to be the last bases of
+// the codon.
+func geneVariants(g vcfGene, report *alignment.AlignmentReport) []vcfVariant {
+	variants := make([]vcfVariant, 0)
+	mutations := make(map[int]*m.Mutation)
+	for i := range report.Mutations {
+		mutations[report.Mutations[i].Position] = &report.Mutations[i]
+	}
+	for _, diff := range report.CodonDifferences {
+		if diff.Differences == 0 {
+			continue
+		}
+		refAA := a.ToString(g.aaRef[diff.PosAA-1])
+		change := fmt.Sprintf("%s%d%s", refAA, diff.PosAA, refAA)
+		if mut := mutations[diff.PosAA]; mut != nil {
+			change = aminoAcidChange(mut)
+		}
+		for k := 0; k < 3; k++ {
+			refNA := g.nucRef[diff.RefPosNA+k-1]
+			na, _ := n.LookupNucleicAcid(rune(diff.CodonText[k]))
+			if na == refNA || na == n.N {
+				continue
+			}
+			alleles := make([]string, 0, 1)
+			for _, allele := range n.GetUnambiguousNucleicAcids(na) {
+				alleles = append(alleles, allele.ToString())
+			}
+			variants = append(variants, vcfVariant{
+				pos:          diff.RefPosNA + k,
+				ref:          vcfBases([]n.NucleicAcid{refNA}),
+				alleles:      alleles,
+				aminoAcids:   []string{change},
+				isSynonymous: diff.IsSynonymous,
+			})
+		}
+	}
+
+	addIndel := func(pos int, seq []n.NucleicAcid, isInsertion bool, changes []string, isFrameShift bool) {
+		pos, ref, alt := normalizeIndel(g.nucRef, pos, seq, isInsertion)
+		variants = append(variants, vcfVariant{
+			pos:          pos,
+			ref:          ref,
+			alleles:      []string{alt},
+			aminoAcids:   changes,
+			isIndel:      true,
+			isFrameShift: isFrameShift,
+		})
+	}
+	frameshifts := make(map[int]bool)
+	for _, fs := range report.FrameShifts {
+		frameshifts[fs.Position] = true
+	}
+	for i := 0; i < len(report.Mutations); i++ {
+		mut := &report.Mutations[i]
+		if mut.IsDeletion {
+			changes := []string{aminoAcidChange(mut)}
+			last := mut.Position
+			for i+1 < len(report.Mutations) && report.Mutations[i+1].IsDeletion &&
+				report.Mutations[i+1].Position == last+1 {
+				i++
+				last++
+				changes = append(changes, aminoAcidChange(&report.Mutations[i]))
+			}
+			first := (mut.Position-1)*3 + 1
+			addIndel(first-1, g.nucRef[first-1:last*3], false, changes, false)
+		} else if mut.IsInsertion && !frameshifts[mut.Position] {
+			addIndel(
+				mut.Position*3, n.ReadString(mut.InsertedCodonsText), true,
+				[]string{aminoAcidChange(mut)}, false)
+		}
+	}
+	for _, fs := range report.FrameShifts {
+		var changes []string
+		mut := mutations[fs.Position]
+		if mut != nil {
+			changes = []string{aminoAcidChange(mut)}
+		}
+		if fs.IsInsertion {
+			// the bases inserted by the frameshift follow the
+			// inserted codons
+			inserted := fs.NucleicAcidsText
+			if mut != nil && mut.IsInsertion {
+				inserted = mut.InsertedCodonsText + inserted
+			}
+			addIndel(fs.Position*3, n.ReadString(inserted), true, changes, true)
+		} else {
+			last := fs.Position * 3
+			addIndel(last-fs.GapLength, g.nucRef[last-fs.GapLength:last], false, changes, true)
+		}
+	}
+	return variants
+}
+
+// Indels are kept apart from the substitutions that share their
+// anchor base, so that a sample with both doesn't read as a mixture.
+type vcfKey struct {
+	chrom   string
+	pos     int
+	ref     string
+	isIndel bool
+}
+
+// A record of a VCF. samples holds the alleles of each sample that
+// has the variant.
+type vcfRecord struct {
+	vcfKey
+	chromOrder   int
+	genes        []string
+	aminoAcids   []string
+	isSynonymous bool
+	isFrameShift bool
+	samples      map[int][]string
+}
+
+// A part of a contig covered by the alignment of a sample
+type vcfCoverage struct {
+	chrom string
+	first int
+	last  int
+}
+
+// The variants of a set of samples, merged into records
+type vcfTable struct {
+	genes    []vcfGene
+	chroms   []string
+	samples  []string
+	coverage [][]vcfCoverage
+	records  map[vcfKey]*vcfRecord
+}
+
+func newVCFTable(genes []vcfGene) *vcfTable {
+	table := &vcfTable{genes: genes, records: make(map[vcfKey]*vcfRecord)}
+	for _, g := range genes {
+		if !containsString(table.chroms, g.chrom) {
+			table.chroms = append(table.chroms, g.chrom)
+		}
+	}
+	return table
+}
+
+func indexOfString(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
+
+func containsString(values []string, value string) bool {
+	return indexOfString(values, value) >= 0
+}
+
+// Add the variants of a sequence as a new sample
+func (t *vcfTable) add(result sequenceResult) {
+	sample := len(t.samples)
+	t.samples = append(t.samples, result.Name)
+	coverage := make([]vcfCoverage, 0, len(t.genes))
+	for i, g := range t.genes {
+		report := result.Results[i].Report
+		if result.Results[i].Err != nil || report == nil {
+			continue
+		}
+		offset := g.start - 1
+		coverage = append(coverage, vcfCoverage{
+			g.chrom, offset + (report.FirstAA-1)*3 + 1, offset + report.LastAA*3,
+		})
+		for _, variant := range geneVariants(g, report) {
+			key := vcfKey{g.chrom, offset + variant.pos, variant.ref, variant.isIndel}
+			record, found := t.records[key]
+			if !found {
+				record = &vcfRecord{
+					vcfKey:       key,
+					chromOrder:   indexOfString(t.chroms, g.chrom),
+					isSynonymous: true,
+					samples:      make(map[int][]string),
+				}
+				t.records[key] = record
+			}
+			if !containsString(record.genes, string(g.gene)) {
+				record.genes = append(record.genes, string(g.gene))
+			}
+			for _, change := range variant.aminoAcids {
+				if !containsString(record.aminoAcids, change) {
+					record.aminoAcids = append(record.aminoAcids, change)
+				}
+			}
+			record.isSynonymous = record.isSynonymous && variant.isSynonymous
+			record.isFrameShift = record.isFrameShift || variant.isFrameShift
+			for _, allele := range variant.alleles {
+				if !containsString(record.samples[sample], allele) {
+					record.samples[sample] = append(record.samples[sample], allele)
+				}
+			}
+		}
+	}
+	t.coverage = append(t.coverage, coverage)
+}
+
+// The genotype of a sample: the indexes of its alleles, 0 if the
+// sample matches the reference, or . if it doesn't cover the record
+func (t *vcfTable) genotype(record *vcfRecord, alts []string, sample int) string {
+	alleles, found := record.samples[sample]
+	if !found {
+		for _, c := range t.coverage[sample] {
+			if c.chrom == record.chrom && record.pos >= c.first && record.pos <= c.last {
+				return "0"
+			}
+		}
+		return "."
+	}
+	indexes := make([]int, 0, len(alleles))
+	for _, allele := range alleles {
+		if allele == record.ref {
+			indexes = append(indexes, 0)
+			continue
+		}
+		for i, alt := range alts {
+			if alt == allele {
+				indexes = append(indexes, i+1)
+			}
+		}
+	}
+	sort.Ints(indexes)
+	texts := make([]string, len(indexes))
+	for i, index := range indexes {
+		texts[i] = fmt.Sprint(index)
+	}
+	return strings.Join(texts, "/")
+}
+
+// The meta-information lines that follow the contigs
+var vcfMetaInformation = `##INFO=<ID=GENE,Number=.,Type=String,Description="Genes whose alignment has the variant">
+##INFO=<ID=AA,Number=.,Type=String,Description="Amino acid changes of the codons with the variant">
+##INFO=<ID=SYN,Number=0,Type=Flag,Description="The variant doesn't change the amino acid">
+##INFO=<ID=FS,Number=0,Type=Flag,Description="The variant is a frameshift">
+##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype">
+`
+
+// Write the records of the table, ordered by contig and position
+func (t *vcfTable) write(file io.Writer) error {
+	header := "##fileformat=VCFv4.2\n##source=nucamino\n"
+	for _, chrom := range t.chroms {
+		header += "##contig=<ID=" + chrom + ">\n"
+	}
+	header += vcfMetaInformation
+	header += "#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO\tFORMAT"
+	for _, sample := range t.samples {
+		header += "\t" + sample
+	}
+	if _, err := io.WriteString(file, header+"\n"); err != nil {
+		return err
+	}
+	records := make([]*vcfRecord, 0, len(t.records))
+	for _, record := range t.records {
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		ri, rj := records[i], records[j]
+		if ri.chromOrder != rj.chromOrder {
+			return ri.chromOrder < rj.chromOrder
+		}
+		if ri.pos != rj.pos {
+			return ri.pos < rj.pos
+		}
+		if ri.ref != rj.ref {
+			return ri.ref < rj.ref
+		}
+		return !ri.isIndel && rj.isIndel
+	})
+	for _, record := range records {
+		alts := make([]string, 0)
+		for _, alleles := range record.samples {
+			for _, allele := range alleles {
+				if allele != record.ref && !containsString(alts, allele) {
+					alts = append(alts, allele)
+				}
+			}
+		}
+		sort.Strings(alts)
+		info := "GENE=" + strings.Join(record.genes, ",")
+		if len(record.aminoAcids) > 0 {
+			info += ";AA=" + strings.Join(record.aminoAcids, ",")
+		}
+		if record.isSynonymous {
+			info += ";SYN"
+		}
+		if record.isFrameShift {
+			info += ";FS"
+		}
+		line := fmt.Sprintf(
+			"%s\t%d\t.\t%s\t%s\t.\t.\t%s\tGT",
+			record.chrom, record.pos, record.ref, strings.Join(alts, ","), info)
+		for sample := range t.samples {
+			line += "\t" + t.genotype(record, alts, sample)
+		}
+		if _, err := io.WriteString(file, line+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Writes a VCF with a sample per sequence once all sequences were
+// aligned, or, if dir is set, a VCF per sequence into dir as soon as
+// the sequence is aligned.
+type vcfWriter struct {
+	file  *bufio.Writer
+	dir   string
+	table *vcfTable
+}
+
+func newVCFWriter(output io.Writer, genes []vcfGene, options VCFOptions, dir string) *vcfWriter {
+	writer := &vcfWriter{bufio.NewWriter(output), "", newVCFTable(genes)}
+	if options.PerSequence {
+		writer.dir = dir
+	}
+	return writer
+}
+
+func (w *vcfWriter) WriteHeader() error {
+	if w.dir != "" {
+		return os.MkdirAll(w.dir, 0755)
+	}
+	return nil
+}
+
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// The file a sequence is written to; the index keeps the names of
+// sequences with the same name apart.
+func vcfFileName(dir string, result sequenceResult) string {
+	name := unsafeFileNameChars.ReplaceAllString(result.Name, "_")
+	return filepath.Join(dir, fmt.Sprintf("%d_%s.vcf", result.Index+1, name))
+}
+
+func (w *vcfWriter) WriteResult(result sequenceResult) error {
+	if w.dir == "" {
+		w.table.add(result)
+		return nil
+	}
+	table := newVCFTable(w.table.genes)
+	table.add(result)
+	file, err := os.Create(vcfFileName(w.dir, result))
+	if err != nil {
+		return err
+	}
+	buffered := bufio.NewWriter(file)
+	if err = table.write(buffered); err == nil {
+		err = buffered.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (w *vcfWriter) Close() error {
+	if w.dir != "" {
+		return nil
+	}
+	if err := w.table.write(w.file); err != nil {
+		return err
+	}
+	return w.file.Flush()
+}
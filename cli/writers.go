@@ -0,0 +1,472 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/hivdb/nucamino/aligner"
+	"github.com/hivdb/nucamino/alignment"
+	n "github.com/hivdb/nucamino/types/nucleic"
+	"github.com/hivdb/nucamino/utils/fastareader"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// The alignment results of one input sequence, in the same order as
+// the genes that were requested. Index is the position of the
+// sequence in the input, starting from 0; the output formats count
+// from 1. DetectedGenes is only set when the
+// genes were detected automatically. Sequence is only needed by the
+// codon-aligned FASTA formats. Diagnostics tell what was changed
+// while reading the sequence. Genotype is only set when genotyping,
+// and Genome only in genome mode.
+type sequenceResult struct {
+	Index         int
+	Name          string
+	Results       []AlignmentResult
+	DetectedGenes []detectedGene
+	Sequence      []n.NucleicAcid
+	Diagnostics   fastareader.Diagnostics
+	Genotype      *genotypeCall
+	Genome        *aligner.GenomePlacement
+}
+
+type detectedGene struct {
+	Gene     string
+	Coverage float64
+}
+
+// A resultWriter serializes alignment results into one of the
+// supported output formats. WriteResult is called once per input
+// sequence, in input order; Close is called after the last result.
+type resultWriter interface {
+	WriteHeader() error
+	WriteResult(result sequenceResult) error
+	Close() error
+}
+
+// The aligner options determine the optional columns of the TSV
+// format.
+func newResultWriter(
+	format string, output io.Writer, textGenes []string,
+	options aligner.Options) resultWriter {
+	buffered := bufio.NewWriter(output)
+	switch format {
+	case "tsv":
+		return &tsvWriter{buffered, textGenes, options, false, false, false, false}
+	case "ndjson":
+		return &ndjsonWriter{buffered, json.NewEncoder(buffered), textGenes}
+	case "corrected-na-fasta", "corrected-aa-fasta":
+		return &correctedWriter{buffered, textGenes, format == "corrected-aa-fasta"}
+	default:
+		return &jsonWriter{
+			buffered, textGenes,
+			make([][]AlignmentResult, len(textGenes)),
+		}
+	}
+}
+
+// The covered part of the region a result is for, if the gene that
+// was requested is the name of a region
+func regionRangeOf(r *AlignmentResult, textGene string) (alignment.RegionRange, bool) {
+	for _, regionRange := range r.Report.Regions {
+		if regionRange.Region == textGene {
+			return regionRange, true
+		}
+	}
+	return alignment.RegionRange{}, false
+}
+
+// Mutations and frameshifts are written with their position in the
+// region if inRegion is set.
+func joinMutations(r *AlignmentResult, inRegion bool) string {
+	var muts bytes.Buffer
+	for _, mut := range r.Report.Mutations {
+		if inRegion {
+			mut.Position = mut.RegionPosition
+		}
+		muts.WriteString(mut.ToString())
+		muts.WriteString(",")
+	}
+	if muts.Len() > 0 {
+		muts.Truncate(muts.Len() - 1)
+	}
+	return muts.String()
+}
+
+// The synonymous changes of a result; the positions are shifted by
+// offset to write them in region numbering.
+func joinSynonymousChanges(r *AlignmentResult, offset int) string {
+	changes := make([]string, len(r.Report.SynonymousChanges))
+	for i, change := range r.Report.SynonymousChanges {
+		change.PosAA -= offset
+		changes[i] = change.ToString()
+	}
+	return strings.Join(changes, ",")
+}
+
+func joinFrameShifts(r *AlignmentResult, inRegion bool) string {
+	var fss bytes.Buffer
+	for _, fs := range r.Report.FrameShifts {
+		if inRegion {
+			fs.Position = fs.RegionPosition
+		}
+		fss.WriteString(fs.ToString())
+		fss.WriteString(",")
+	}
+	if fss.Len() > 0 {
+		fss.Truncate(fss.Len() - 1)
+	}
+	return fss.String()
+}
+
+// The columns describing what was changed while reading a sequence
+var tsvDiagnosticsColumns = []string{
+	"Invalid Characters", "Stripped Gaps", "Soft-masked Ranges",
+}
+
+func formatDiagnostics(d *fastareader.Diagnostics) string {
+	invalid := make([]string, len(d.InvalidCharacters))
+	for i, c := range d.InvalidCharacters {
+		invalid[i] = c.ToString()
+	}
+	softMasked := make([]string, len(d.SoftMaskedRanges))
+	for i, r := range d.SoftMaskedRanges {
+		softMasked[i] = r.ToString()
+	}
+	return fmt.Sprintf(
+		"\t%s\t%d\t%s",
+		strings.Join(invalid, ","), d.StrippedGaps, strings.Join(softMasked, ","))
+}
+
+// The columns describing the placement of the genes in genome mode
+var tsvPlacementColumns = []string{
+	"Gene Placements", "Inter-genic Regions", "Unplaced Genes",
+}
+
+func formatPlacement(genome *aligner.GenomePlacement) string {
+	if genome == nil {
+		return strings.Repeat("\tNA", len(tsvPlacementColumns))
+	}
+	placements := make([]string, len(genome.Placements))
+	for i, placement := range genome.Placements {
+		placements[i] = placement.ToString()
+	}
+	regions := make([]string, len(genome.InterGenicRegions))
+	for i, region := range genome.InterGenicRegions {
+		regions[i] = region.ToString()
+	}
+	unplaced := make([]string, len(genome.UnplacedGenes))
+	for i, gene := range genome.UnplacedGenes {
+		unplaced[i] = string(gene)
+	}
+	return fmt.Sprintf(
+		"\t%s\t%s\t%s", strings.Join(placements, ","),
+		strings.Join(regions, ","), strings.Join(unplaced, ","))
+}
+
+// The columns written for each gene that was aligned; for the other
+// genes they are NA, and the reason is written to the Error column
+// that follows them.
+var tsvGeneColumns = []string{
+	"FirstAA", "LastAA", "FirstNA", "LastNA", "Mutations", "FrameShifts",
+	"Score", "NormalizedScore", "PercentIdentity", "Coverage",
+	"AmbiguousCodons", "StopCodons", "PartialCodons",
+	"InsertionCodons", "DeletionCodons",
+}
+
+// The columns with the genome positions of a gene; the positions of
+// the mutations and frameshifts follow the order of their columns.
+var tsvGenomeColumns = []string{
+	"GenomeFirstNA", "GenomeLastNA",
+	"MutationGenomePositions", "FrameShiftGenomePositions",
+}
+
+func formatGenomePositions(r *alignment.AlignmentReport) string {
+	if r.GenomeFirstNA == 0 {
+		return strings.Repeat("\tNA", len(tsvGenomeColumns))
+	}
+	mutations := make([]string, len(r.Mutations))
+	for i, mut := range r.Mutations {
+		mutations[i] = strconv.Itoa(mut.GenomePosition)
+	}
+	frameshifts := make([]string, len(r.FrameShifts))
+	for i, fs := range r.FrameShifts {
+		frameshifts[i] = strconv.Itoa(fs.GenomePosition)
+	}
+	return fmt.Sprintf(
+		"\t%d\t%d\t%s\t%s", r.GenomeFirstNA, r.GenomeLastNA,
+		strings.Join(mutations, ","), strings.Join(frameshifts, ","))
+}
+
+// Writes one row per sequence; each row is flushed as soon as it's
+// written so that partial results are visible during long runs. If
+// genotype is set, the genotype columns are written before the
+// columns of the gene, followed by the recombination columns if
+// recombination is set. If synonymous is set, the synonymous changes
+// of each gene are written after its other columns, followed by the
+// genome columns if genome is set. The genome placement columns
+// follow the diagnostics columns in genome mode.
+type tsvWriter struct {
+	file          *bufio.Writer
+	textGenes     []string
+	options       aligner.Options
+	genotype      bool
+	recombination bool
+	synonymous    bool
+	genome        bool
+}
+
+func (w *tsvWriter) WriteHeader() error {
+	file := w.file
+	file.WriteString("Sequence Index\tSequence Name")
+	if w.options.DetectGenes {
+		file.WriteString("\tDetected Genes")
+	}
+	for _, column := range tsvDiagnosticsColumns {
+		file.WriteString("\t" + column)
+	}
+	if w.options.GenomeMode {
+		for _, column := range tsvPlacementColumns {
+			file.WriteString("\t" + column)
+		}
+	}
+	if w.genotype {
+		for _, column := range tsvGenotypeColumns {
+			file.WriteString("\t" + column)
+		}
+	}
+	if w.recombination {
+		for _, column := range tsvRecombinationColumns {
+			file.WriteString("\t" + column)
+		}
+	}
+	for _, textGene := range w.textGenes {
+		for _, column := range tsvGeneColumns {
+			file.WriteString("\t" + textGene + " " + column)
+		}
+		file.WriteString("\t" + textGene + " Error")
+		if w.options.BothStrands {
+			file.WriteString("\t" + textGene + " Strand")
+		}
+		if w.synonymous {
+			file.WriteString("\t" + textGene + " SynonymousChanges")
+		}
+		if w.genome {
+			for _, column := range tsvGenomeColumns {
+				file.WriteString("\t" + textGene + " " + column)
+			}
+		}
+	}
+	file.WriteString("\n")
+	return file.Flush()
+}
+
+func (w *tsvWriter) WriteResult(result sequenceResult) error {
+	file := w.file
+	file.WriteString(fmt.Sprintf("%d\t%s", result.Index+1, result.Name))
+	if w.options.DetectGenes {
+		detected := make([]string, len(result.DetectedGenes))
+		for i, d := range result.DetectedGenes {
+			detected[i] = fmt.Sprintf("%s:%.1f%%", d.Gene, d.Coverage*100)
+		}
+		file.WriteString("\t" + strings.Join(detected, ","))
+	}
+	file.WriteString(formatDiagnostics(&result.Diagnostics))
+	if w.options.GenomeMode {
+		file.WriteString(formatPlacement(result.Genome))
+	}
+	if w.genotype {
+		file.WriteString(formatGenotype(result.Genotype))
+	}
+	if w.recombination {
+		file.WriteString(formatRecombination(result.Genotype))
+	}
+	for i, textGene := range w.textGenes {
+		if result.Results[i].Err != nil {
+			file.WriteString(strings.Repeat("\tNA", len(tsvGeneColumns)))
+			file.WriteString("\t" + result.Results[i].Error)
+			if w.options.BothStrands {
+				file.WriteString("\tNA")
+			}
+			if w.synonymous {
+				file.WriteString("\tNA")
+			}
+			if w.genome {
+				file.WriteString(strings.Repeat("\tNA", len(tsvGenomeColumns)))
+			}
+			continue
+		}
+		r := result.Results[i].Report
+		// the positions of regions are written in region numbering
+		firstAA, lastAA := r.FirstAA, r.LastAA
+		regionRange, inRegion := regionRangeOf(&result.Results[i], textGene)
+		if inRegion {
+			firstAA, lastAA = regionRange.FirstAA, regionRange.LastAA
+		}
+		file.WriteString(fmt.Sprintf(
+			"\t%d\t%d\t%d\t%d\t%s\t%s\t%.2f\t%.3f\t%.1f\t%.3f\t%d\t%d\t%d\t%d\t%d",
+			firstAA, lastAA,
+			r.FirstNA, r.LastNA,
+			joinMutations(&result.Results[i], inRegion),
+			joinFrameShifts(&result.Results[i], inRegion),
+			r.Score, r.NormalizedScore,
+			r.PercentIdentity, r.Coverage,
+			r.AmbiguousCodons, r.StopCodons, r.PartialCodons,
+			r.InsertionCodons, r.DeletionCodons,
+		))
+		// the Error column is empty
+		file.WriteString("\t")
+		if w.options.BothStrands {
+			if r.IsReverseComplement {
+				file.WriteString("\t-")
+			} else {
+				file.WriteString("\t+")
+			}
+		}
+		if w.synonymous {
+			file.WriteString("\t" + joinSynonymousChanges(&result.Results[i], r.FirstAA-firstAA))
+		}
+		if w.genome {
+			file.WriteString(formatGenomePositions(r))
+		}
+	}
+	file.WriteString("\n")
+	return file.Flush()
+}
+
+func (w *tsvWriter) Close() error {
+	return w.file.Flush()
+}
+
+// The JSON format groups the results by gene, so nothing can be
+// written until every sequence was aligned. Only the reports are
+// kept in memory; the sequences themselves are released as soon as
+// they were aligned.
+type jsonWriter struct {
+	file      *bufio.Writer
+	textGenes []string
+	results   [][]AlignmentResult
+}
+
+func (w *jsonWriter) WriteHeader() error {
+	return nil
+}
+
+func (w *jsonWriter) WriteResult(result sequenceResult) error {
+	for i := range w.textGenes {
+		r := result.Results[i]
+		r.Index = result.Index + 1
+		r.DetectedGenes = result.DetectedGenes
+		if !result.Diagnostics.IsEmpty() {
+			r.Diagnostics = &result.Diagnostics
+		}
+		r.Genotype = result.Genotype
+		r.Genome = result.Genome
+		w.results[i] = append(w.results[i], r)
+	}
+	return nil
+}
+
+func (w *jsonWriter) Close() error {
+	finalResultMap := make(map[string][]AlignmentResult)
+	for i, textGene := range w.textGenes {
+		finalResultMap[textGene] = w.results[i]
+	}
+	result, err := json.MarshalIndent(finalResultMap, "", "  ")
+	if err != nil {
+		return err
+	}
+	w.file.Write(result)
+	return w.file.Flush()
+}
+
+// Writes one JSON object per line per sequence. Like the TSV format,
+// each line is flushed as soon as the sequence was aligned.
+type ndjsonWriter struct {
+	file      *bufio.Writer
+	encoder   *json.Encoder
+	textGenes []string
+}
+
+type ndjsonRecord struct {
+	Index         int
+	Name          string
+	DetectedGenes []detectedGene           `json:",omitempty"`
+	Diagnostics   *fastareader.Diagnostics `json:",omitempty"`
+	Genotype      *genotypeCall            `json:",omitempty"`
+	Genome        *aligner.GenomePlacement `json:",omitempty"`
+	Results       map[string]AlignmentResult
+}
+
+func (w *ndjsonWriter) WriteHeader() error {
+	return nil
+}
+
+func (w *ndjsonWriter) WriteResult(result sequenceResult) error {
+	record := ndjsonRecord{
+		Index:         result.Index + 1,
+		Name:          result.Name,
+		DetectedGenes: result.DetectedGenes,
+		Genotype:      result.Genotype,
+		Genome:        result.Genome,
+		Results:       make(map[string]AlignmentResult, len(w.textGenes)),
+	}
+	if !result.Diagnostics.IsEmpty() {
+		record.Diagnostics = &result.Diagnostics
+	}
+	for i, textGene := range w.textGenes {
+		record.Results[textGene] = result.Results[i]
+	}
+	if err := w.encoder.Encode(record); err != nil {
+		return err
+	}
+	return w.file.Flush()
+}
+
+func (w *ndjsonWriter) Close() error {
+	return w.file.Flush()
+}
+
+// Writes the frameshift-corrected sequence (or its translation) of
+// every aligned gene in FASTA format. Like the codon-aligned formats,
+// the name of the gene is appended to the sequence names when more
+// than one gene was requested. Each sequence is flushed as soon as it
+// was aligned.
+type correctedWriter struct {
+	file       *bufio.Writer
+	textGenes  []string
+	aminoAcids bool
+}
+
+func (w *correctedWriter) WriteHeader() error {
+	return nil
+}
+
+func (w *correctedWriter) WriteResult(result sequenceResult) error {
+	file := w.file
+	for i, textGene := range w.textGenes {
+		r := result.Results[i]
+		if r.Err != nil {
+			continue
+		}
+		file.WriteString(">" + result.Name)
+		if len(w.textGenes) > 1 {
+			file.WriteString("|" + textGene)
+		}
+		file.WriteString("\n")
+		if w.aminoAcids {
+			file.WriteString(r.Report.CorrectedAminoAcids)
+		} else {
+			file.WriteString(r.Report.CorrectedNucleicAcids)
+		}
+		file.WriteString("\n")
+	}
+	return file.Flush()
+}
+
+func (w *correctedWriter) Close() error {
+	return w.file.Flush()
+}
@@ -2,19 +2,27 @@ package cmd
 
 import (
 	"fmt"
+	"github.com/hivdb/nucamino/aligner"
 	ap "github.com/hivdb/nucamino/alignmentprofile"
 	"github.com/hivdb/nucamino/alignmentprofile/builtin"
 	"github.com/hivdb/nucamino/cli"
 	"github.com/pkg/profile"
 	"github.com/spf13/cobra"
+	"sort"
 	"strings"
 )
 
 // The cobra cli library will populate these variables with values
 // provided as command line flags.
 var alignInputFilename, alignOutputFilename, alignOutputFormat string
-var alignQuiet, alignPprof bool
-var alignGoroutines int
+var alignAlignmentMode, alignDuplicateNames string
+var alignQuiet, alignPprof, alignBothStrands bool
+var alignMSAKeepInsertions, alignMSAUncoveredN, alignVCFPerSequence bool
+var alignGoroutines, alignMinQuality int
+var alignQualityWeighting bool
+var alignMinDetectionScore float64
+var alignMinScorePerCodon, alignMinCoverage float64
+var alignMaxFrameShifts, alignMaxStopCodons int
 
 func init() {
 	rootCmd.AddCommand(alignCmd)
@@ -24,21 +32,21 @@ func init() {
 		"input-file",
 		"i",
 		"-",
-		"input file",
+		"input file (FASTA or FASTQ, optionally compressed with gzip, bzip2 or zstd)",
 	)
 	alignCmd.Flags().StringVarP(
 		&alignOutputFilename,
 		"output-file",
 		"o",
 		"-",
-		"output File",
+		"output File (compressed if the name ends with .gz or .zst)",
 	)
 	alignCmd.Flags().StringVarP(
 		&alignOutputFormat,
 		"output-format",
 		"f",
 		"tsv",
-		"output format. (options: \"tsv\", \"json\")",
+		"output format. (options: \"tsv\", \"json\", \"ndjson\", \"na-fasta\", \"aa-fasta\", \"corrected-na-fasta\", \"corrected-aa-fasta\", \"vcf\")",
 	)
 	alignCmd.Flags().BoolVarP(
 		&alignQuiet,
@@ -60,16 +68,176 @@ func init() {
 		0,
 		"number of goroutines the aligner will use. (default: number of CPUs)",
 	)
+	alignCmd.Flags().Float64Var(
+		&alignMinDetectionScore,
+		"min-detection-score",
+		aligner.DefaultMinDetectionScore,
+		"minimum screening score for a gene to be detected when genes are \"auto\"",
+	)
+	alignCmd.Flags().BoolVar(
+		&alignBothStrands,
+		"both-strands",
+		false,
+		"also align the reverse complement of each sequence and keep the better strand",
+	)
+	alignCmd.Flags().StringVar(
+		&alignAlignmentMode,
+		"alignment-mode",
+		"",
+		"alignment mode. (options: \"semiglobal\", \"global\", \"local\"; default: the mode of the profile)",
+	)
+	alignCmd.Flags().IntVar(
+		&alignMinQuality,
+		"min-quality",
+		0,
+		"replace bases of FASTQ input whose Phred quality is below this value by N",
+	)
+	alignCmd.Flags().BoolVar(
+		&alignQualityWeighting,
+		"quality-weighting",
+		false,
+		"weight codon scores of FASTQ input by base quality and report the codon quality of mutations",
+	)
+	alignCmd.Flags().BoolVar(
+		&alignMSAKeepInsertions,
+		"msa-keep-insertions",
+		false,
+		"keep insertions as gap-padded columns in the \"na-fasta\" and \"aa-fasta\" formats instead of stripping them",
+	)
+	alignCmd.Flags().BoolVar(
+		&alignMSAUncoveredN,
+		"msa-uncovered-n",
+		false,
+		"fill positions not covered by a sequence with N (X for amino acids) instead of gaps in the \"na-fasta\" and \"aa-fasta\" formats",
+	)
+	alignCmd.Flags().BoolVar(
+		&alignVCFPerSequence,
+		"vcf-per-sequence",
+		false,
+		"write one VCF per sequence into the directory given by --output-file instead of a VCF with a sample per sequence",
+	)
+	addThresholdFlags(
+		alignCmd, &alignMinScorePerCodon, &alignMinCoverage,
+		&alignMaxFrameShifts, &alignMaxStopCodons)
+	alignCmd.Flags().StringVar(
+		&alignDuplicateNames,
+		"duplicate-names",
+		"suffix",
+		"what to do with sequences whose name was already used. (options: \"error\", \"suffix\" to append _2, _3, ..., \"allow\")",
+	)
 }
 
 // Check that a gene-name is in a list of GEnes
 func geneInGenes(geneArg string, genes []ap.Gene) bool {
+	_, found := findGene(geneArg, genes)
+	return found
+}
+
+// Check whether the genes argument asks for automatic gene detection
+func isAutoGenes(genesArg string) bool {
+	return strings.ToLower(strings.TrimSpace(genesArg)) == "auto"
+}
+
+// Check whether the genes argument asks for genome mode
+func isGenomeGenes(genesArg string) bool {
+	return strings.ToLower(strings.TrimSpace(genesArg)) == "genome"
+}
+
+// The genes of the genome order of a profile, which genome mode aligns
+func genomeGenes(profile *ap.AlignmentProfile, profileName string) ([]string, error) {
+	if profile.Genome == nil || len(profile.Genome.Order) == 0 {
+		return nil, fmt.Errorf("The profile %v doesn't declare a genome order", profileName)
+	}
+	genes := make([]string, len(profile.Genome.Order))
+	for i, gene := range profile.Genome.Order {
+		genes[i] = string(gene)
+	}
+	return genes, nil
+}
+
+// All genes of a profile, in alphabetical order
+func sortedGenes(profile *ap.AlignmentProfile) []string {
+	genes := make([]string, 0)
+	for _, gene := range profile.Genes() {
+		genes = append(genes, string(gene))
+	}
+	sort.Strings(genes)
+	return genes
+}
+
+// Add the flags that override the thresholds of the profile
+func addThresholdFlags(
+	cmd *cobra.Command, minScorePerCodon, minCoverage *float64,
+	maxFrameShifts, maxStopCodons *int) {
+	cmd.Flags().Float64Var(
+		minScorePerCodon,
+		"min-score-per-codon",
+		0,
+		"reject alignments whose score per aligned codon is lower. (default: the threshold of the profile, if any)",
+	)
+	cmd.Flags().Float64Var(
+		minCoverage,
+		"min-coverage",
+		0,
+		"reject alignments covering a smaller fraction (0 to 1) of the reference. (default: the threshold of the profile, if any)",
+	)
+	cmd.Flags().IntVar(
+		maxFrameShifts,
+		"max-frameshifts",
+		0,
+		"reject alignments with more frameshifts. (default: the threshold of the profile, if any)",
+	)
+	cmd.Flags().IntVar(
+		maxStopCodons,
+		"max-stop-codons",
+		0,
+		"reject alignments with more stop codons. (default: the threshold of the profile, if any)",
+	)
+}
+
+// The thresholds given on the command line. The thresholds whose
+// flag wasn't given are left unset, so that those of the profile
+// apply.
+func getThresholds(
+	cmd *cobra.Command, minScorePerCodon, minCoverage float64,
+	maxFrameShifts, maxStopCodons int) (ap.Thresholds, error) {
+	var thresholds ap.Thresholds
+	if cmd.Flags().Changed("min-score-per-codon") {
+		thresholds.MinScorePerCodon = &minScorePerCodon
+	}
+	if cmd.Flags().Changed("min-coverage") {
+		thresholds.MinCoverage = &minCoverage
+	}
+	if cmd.Flags().Changed("max-frameshifts") {
+		thresholds.MaxFrameShifts = &maxFrameShifts
+	}
+	if cmd.Flags().Changed("max-stop-codons") {
+		thresholds.MaxStopCodons = &maxStopCodons
+	}
+	return thresholds, thresholds.Validate()
+}
+
+// Override the alignment mode of a profile, unless modeArg is empty
+func setAlignmentMode(profile *ap.AlignmentProfile, modeArg string) error {
+	if modeArg == "" {
+		return nil
+	}
+	mode, err := ap.ParseAlignmentMode(modeArg)
+	if err != nil {
+		return err
+	}
+	profile.AlignmentMode = mode
+	return nil
+}
+
+// Find the gene in a list of Genes that a gene-name refers to
+func findGene(geneArg string, genes []ap.Gene) (ap.Gene, bool) {
 	for _, g := range genes {
 		if g.Matches(geneArg) {
-			return true
+			return g, true
 		}
 	}
-	return false
+	return "", false
 }
 
 func alignGetParameters(args []string) (*ap.AlignmentProfile, []string, error) {
@@ -84,15 +252,23 @@ See 'nucamino profile list' for a list of available profiles`
 		return nil, nil, err
 	}
 
-	genes := strings.Split(args[1], ",")
-	for idx, gene := range genes {
-		genes[idx] = strings.ToUpper(gene)
+	if isAutoGenes(args[1]) {
+		return profile, sortedGenes(profile), nil
+	}
+	if isGenomeGenes(args[1]) {
+		genes, err := genomeGenes(profile, profileName)
+		return profile, genes, err
 	}
+	genes := strings.Split(args[1], ",")
 	profileGenes := profile.Genes()
-	for _, gene := range genes {
-		if !geneInGenes(gene, profileGenes) {
+	for idx, gene := range genes {
+		if profileGene, found := findGene(gene, profileGenes); found {
+			genes[idx] = string(profileGene)
+		} else if region, found := profile.FindRegion(gene); found {
+			genes[idx] = region.Name
+		} else {
 			tmpl := "%v is not an available gene in the profile %v (available genes: %v)"
-			err := fmt.Errorf(tmpl, gene, profileName, profileGenes)
+			err := fmt.Errorf(tmpl, strings.ToUpper(gene), profileName, profileGenes)
 			return nil, nil, err
 		}
 	}
@@ -108,6 +284,15 @@ func alignRun(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	if err := setAlignmentMode(profile, alignAlignmentMode); err != nil {
+		return err
+	}
+	thresholds, err := getThresholds(
+		cmd, alignMinScorePerCodon, alignMinCoverage,
+		alignMaxFrameShifts, alignMaxStopCodons)
+	if err != nil {
+		return err
+	}
 	return cli.PerformAlignment(
 		alignInputFilename,
 		alignOutputFilename,
@@ -116,21 +301,51 @@ func alignRun(cmd *cobra.Command, args []string) error {
 		alignGoroutines,
 		alignQuiet,
 		*profile,
+		aligner.Options{
+			DetectGenes:       isAutoGenes(args[1]),
+			GenomeMode:        isGenomeGenes(args[1]),
+			MinDetectionScore: alignMinDetectionScore,
+			BothStrands:       alignBothStrands,
+			MinQuality:        alignMinQuality,
+			QualityWeighting:  alignQualityWeighting,
+			Thresholds:        thresholds,
+		},
+		cli.MSAOptions{
+			KeepInsertions: alignMSAKeepInsertions,
+			UncoveredAsN:   alignMSAUncoveredN,
+		},
+		cli.VCFOptions{
+			PerSequence: alignVCFPerSequence,
+		},
+		cli.DuplicateNamePolicy(alignDuplicateNames),
 	)
 }
 
 var alignLongMsg = `
-Loads nucleotide sequences from a FASTA file and aligns them using a
-built-in profile. The first argument is the name of the built-in
-profile to use for the alignment. The second argument is a comma
-separated list of genes to align against. (This list should either be
-surrounded by quote marks or contain no spaces).
+Loads nucleotide sequences from a FASTA (or FASTQ) file and aligns
+them using a built-in profile. The first argument is the name of the
+built-in profile to use for the alignment. The second argument is a
+comma separated list of genes to align against. (This list should
+either be surrounded by quote marks or contain no spaces). It may also
+name regions of a gene, such as the proteins of a polyprotein; their
+positions are reported in the numbering of the region. If the second
+argument is "auto", each sequence is screened against every gene of
+the profile and only aligned against the genes it was detected in.
+If it is "genome", each sequence is aligned against the genes of the
+genome order of the profile, and only the set of genes whose
+placements agree with that order and the overlaps it allows is kept.
+The other genes are aligned again between their placed neighbours;
+the placements, the inter-genic regions between them and the genes
+that couldn't be placed are reported.
 
 Examples:
 
 	nucamino align hiv1b pol
 	nucamino align hcv1a NS3,NS5B
 	nucamino align hiv1b 'gag, pol'
+	nucamino align hiv1b auto
+	nucamino align hiv1b genome
+	nucamino align hiv1b PR,RT
 
 See 'nucamino profile list' for the available alignment profiles.
 
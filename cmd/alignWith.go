@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"github.com/hivdb/nucamino/aligner"
 	ap "github.com/hivdb/nucamino/alignmentprofile"
 	"github.com/hivdb/nucamino/cli"
 	"github.com/pkg/profile"
@@ -13,8 +14,14 @@ import (
 // The cobra cli library will populate these variables with values
 // provided as command line flags.
 var alignWithInputFilename, alignWithOutputFilename, alignWithOutputFormat string
-var alignWithQuiet, alignWithPprof bool
-var alignWithGoroutines int
+var alignWithAlignmentMode, alignWithDuplicateNames string
+var alignWithQuiet, alignWithPprof, alignWithBothStrands bool
+var alignWithMSAKeepInsertions, alignWithMSAUncoveredN, alignWithVCFPerSequence bool
+var alignWithGoroutines, alignWithMinQuality int
+var alignWithQualityWeighting bool
+var alignWithMinDetectionScore float64
+var alignWithMinScorePerCodon, alignWithMinCoverage float64
+var alignWithMaxFrameShifts, alignWithMaxStopCodons int
 
 func init() {
 	rootCmd.AddCommand(alignWithCmd)
@@ -23,21 +30,21 @@ func init() {
 		"input-file",
 		"i",
 		"-",
-		"input file",
+		"input file (FASTA or FASTQ, optionally compressed with gzip, bzip2 or zstd)",
 	)
 	alignWithCmd.Flags().StringVarP(
 		&alignWithOutputFilename,
 		"output-file",
 		"o",
 		"-",
-		"output File",
+		"output File (compressed if the name ends with .gz or .zst)",
 	)
 	alignWithCmd.Flags().StringVarP(
 		&alignWithOutputFormat,
 		"output-format",
 		"f",
 		"tsv",
-		"output format. (options: \"tsv\", \"json\")",
+		"output format. (options: \"tsv\", \"json\", \"ndjson\", \"na-fasta\", \"aa-fasta\", \"corrected-na-fasta\", \"corrected-aa-fasta\", \"vcf\")",
 	)
 	alignWithCmd.Flags().BoolVarP(
 		&alignWithQuiet,
@@ -59,6 +66,63 @@ func init() {
 		0,
 		"number of goroutines the aligner will use. (default: number of CPUs)",
 	)
+	alignWithCmd.Flags().Float64Var(
+		&alignWithMinDetectionScore,
+		"min-detection-score",
+		aligner.DefaultMinDetectionScore,
+		"minimum screening score for a gene to be detected when genes are \"auto\"",
+	)
+	alignWithCmd.Flags().BoolVar(
+		&alignWithBothStrands,
+		"both-strands",
+		false,
+		"also align the reverse complement of each sequence and keep the better strand",
+	)
+	alignWithCmd.Flags().StringVar(
+		&alignWithAlignmentMode,
+		"alignment-mode",
+		"",
+		"alignment mode. (options: \"semiglobal\", \"global\", \"local\"; default: the mode of the profile)",
+	)
+	alignWithCmd.Flags().IntVar(
+		&alignWithMinQuality,
+		"min-quality",
+		0,
+		"replace bases of FASTQ input whose Phred quality is below this value by N",
+	)
+	alignWithCmd.Flags().BoolVar(
+		&alignWithQualityWeighting,
+		"quality-weighting",
+		false,
+		"weight codon scores of FASTQ input by base quality and report the codon quality of mutations",
+	)
+	alignWithCmd.Flags().BoolVar(
+		&alignWithMSAKeepInsertions,
+		"msa-keep-insertions",
+		false,
+		"keep insertions as gap-padded columns in the \"na-fasta\" and \"aa-fasta\" formats instead of stripping them",
+	)
+	alignWithCmd.Flags().BoolVar(
+		&alignWithMSAUncoveredN,
+		"msa-uncovered-n",
+		false,
+		"fill positions not covered by a sequence with N (X for amino acids) instead of gaps in the \"na-fasta\" and \"aa-fasta\" formats",
+	)
+	alignWithCmd.Flags().BoolVar(
+		&alignWithVCFPerSequence,
+		"vcf-per-sequence",
+		false,
+		"write one VCF per sequence into the directory given by --output-file instead of a VCF with a sample per sequence",
+	)
+	addThresholdFlags(
+		alignWithCmd, &alignWithMinScorePerCodon, &alignWithMinCoverage,
+		&alignWithMaxFrameShifts, &alignWithMaxStopCodons)
+	alignWithCmd.Flags().StringVar(
+		&alignWithDuplicateNames,
+		"duplicate-names",
+		"suffix",
+		"what to do with sequences whose name was already used. (options: \"error\", \"suffix\" to append _2, _3, ..., \"allow\")",
+	)
 }
 
 func alignWithGetParameters(args []string) (*ap.AlignmentProfile, []string, error) {
@@ -73,10 +137,21 @@ func alignWithGetParameters(args []string) (*ap.AlignmentProfile, []string, erro
 		return nil, nil, err
 	}
 
+	if isAutoGenes(args[1]) {
+		return profile, sortedGenes(profile), nil
+	}
+	if isGenomeGenes(args[1]) {
+		genes, err := genomeGenes(profile, profileFileName)
+		return profile, genes, err
+	}
 	genes := strings.Split(args[1], ",")
 	profileGenes := profile.Genes()
-	for _, gene := range genes {
-		if !geneInGenes(gene, profileGenes) {
+	for idx, gene := range genes {
+		if profileGene, found := findGene(gene, profileGenes); found {
+			genes[idx] = string(profileGene)
+		} else if region, found := profile.FindRegion(gene); found {
+			genes[idx] = region.Name
+		} else {
 			tmpl := "%v is not an available gene in the profile %v (available genes: %v)"
 			err := fmt.Errorf(tmpl, gene, profileFileName, profileGenes)
 			return nil, nil, err
@@ -94,6 +169,15 @@ func alignWithRun(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	if err := setAlignmentMode(profile, alignWithAlignmentMode); err != nil {
+		return err
+	}
+	thresholds, err := getThresholds(
+		cmd, alignWithMinScorePerCodon, alignWithMinCoverage,
+		alignWithMaxFrameShifts, alignWithMaxStopCodons)
+	if err != nil {
+		return err
+	}
 	return cli.PerformAlignment(
 		alignWithInputFilename,
 		alignWithOutputFilename,
@@ -102,15 +186,35 @@ func alignWithRun(cmd *cobra.Command, args []string) error {
 		alignWithGoroutines,
 		alignWithQuiet,
 		*profile,
+		aligner.Options{
+			DetectGenes:       isAutoGenes(args[1]),
+			GenomeMode:        isGenomeGenes(args[1]),
+			MinDetectionScore: alignWithMinDetectionScore,
+			BothStrands:       alignWithBothStrands,
+			MinQuality:        alignWithMinQuality,
+			QualityWeighting:  alignWithQualityWeighting,
+			Thresholds:        thresholds,
+		},
+		cli.MSAOptions{
+			KeepInsertions: alignWithMSAKeepInsertions,
+			UncoveredAsN:   alignWithMSAUncoveredN,
+		},
+		cli.VCFOptions{
+			PerSequence: alignWithVCFPerSequence,
+		},
+		cli.DuplicateNamePolicy(alignWithDuplicateNames),
 	)
 }
 
 var alignWithLongMsg = `
-Loads nucleotide sequences from a FASTA file and aligns them using a
-custom profile loaded from a YAML file. The first argument is the path
-to the YAML file containing the profile. The second argument is a
-comma separated list of genes to align against. (This list should
-either be surrounded by quote marks or contain no spaces).
+Loads nucleotide sequences from a FASTA (or FASTQ) file and aligns
+them using a custom profile loaded from a YAML file. The first
+argument is the path to the YAML file containing the profile. The
+second argument is a comma separated list of genes to align against.
+(This list should either be surrounded by quote marks or contain no
+spaces), or "auto" to detect the genes each sequence covers, or
+"genome" to place the genes of the genome order of the profile. The
+list may also name the regions defined by the profile.
 
 Examples:
 
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	ap "github.com/hivdb/nucamino/alignmentprofile"
+	"strings"
 	"testing"
 )
 
@@ -30,6 +31,11 @@ func TestAlignGetParameters(t *testing.T) {
 		[]string{"hcv1a", "ns3, ns5b"},
 		[]string{"hiv1b", "gag"},
 		[]string{"hiv1b", "GAG,POL"},
+		[]string{"hiv1b", "auto"},
+		[]string{"hiv1b", "genome"},
+		[]string{"hcv1a", " Genome"},
+		[]string{"hiv1b", "PR,RT"},
+		[]string{"hiv1b", "pol, integrase"},
 	}
 	for _, c := range okCases {
 		_, _, err := alignGetParameters(c)
@@ -42,6 +48,8 @@ func TestAlignGetParameters(t *testing.T) {
 		[]string{"asdf", "gag"},
 		[]string{"hiv1b", "ns3"},
 		[]string{"hcv1a", "n5a ns5b"},
+		// profiles without a genome order have no genome mode
+		[]string{"hiv2a", "genome"},
 	}
 	for _, c := range errCases {
 		_, _, err := alignGetParameters(c)
@@ -50,3 +58,26 @@ func TestAlignGetParameters(t *testing.T) {
 		}
 	}
 }
+
+func TestMatchProfileNames(t *testing.T) {
+	names, err := matchProfileNames("hiv2b, hcv1*,hiv2*")
+	expected := []string{"hiv2b", "hcv1a", "hcv1b", "hiv2a"}
+	if err != nil || strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %v, got %v (%v)", expected, names, err)
+	}
+	for _, arg := range []string{"hcv7", "hiv[", ","} {
+		if _, err := matchProfileNames(arg); err == nil {
+			t.Errorf("Expected matchProfileNames(%v) to be an error", arg)
+		}
+	}
+}
+
+func TestGenotypeGetParameters(t *testing.T) {
+	names, profiles, gene, err := genotypeGetParameters([]string{"hiv2*", "pol"})
+	if err != nil || len(names) != 2 || len(profiles) != 2 || gene != "POL" {
+		t.Errorf("Expected two hiv2 profiles with gene POL, got %v %v (%v)", names, gene, err)
+	}
+	if _, _, _, err := genotypeGetParameters([]string{"hiv1b,hcv1a", "ns3"}); err == nil {
+		t.Errorf("Expected a profile without the gene to be an error")
+	}
+}
@@ -17,8 +17,11 @@ var checkCmd = &cobra.Command{
 Loads a YAML document and parses an alignment profile from it. Checks
 that the required 'ReferenceSequences' value is present, that amino
 acid sequences are valid, and that algorithm parameters have the
-appropriate types. The argument, if given, is the filename to load the
-profile from; reads from standard input if no argument is given.
+appropriate types. If the profile has a 'SubstitutionMatrix', checks
+that it names a built-in matrix (BLOSUM45, BLOSUM62, BLOSUM80 or
+PAM250) or that the inline matrix is complete and symmetric. The
+argument, if given, is the filename to load the profile from; reads
+from standard input if no argument is given.
 
 Example:
 
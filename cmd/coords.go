@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"fmt"
+	ap "github.com/hivdb/nucamino/alignmentprofile"
+	"github.com/hivdb/nucamino/alignmentprofile/builtin"
+	"github.com/spf13/cobra"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	rootCmd.AddCommand(coordsCmd)
+}
+
+var coordsColumns = []string{
+	"Query", "Gene", "Position", "Region", "RegionPosition",
+	"Genome", "GenomeFirstNA", "GenomeLastNA", "CodonBase",
+}
+
+// A row of the output: the codon at position pos of gene, which
+// spans the genome positions first to last. codonBase is only given
+// for queries of a genome position.
+func formatCoordsRow(
+	profile *ap.AlignmentProfile, query string, gene ap.Gene,
+	pos, first, last int, codonBase string) string {
+	region, regionPos := "", ""
+	for _, r := range profile.RegionsOf(gene) {
+		if r.Contains(pos) {
+			region, regionPos = r.Name, strconv.Itoa(r.RelativePosition(pos))
+		}
+	}
+	return strings.Join([]string{
+		query, string(gene), strconv.Itoa(pos), region, regionPos,
+		profile.Genome.Name, strconv.Itoa(first), strconv.Itoa(last), codonBase,
+	}, "\t")
+}
+
+// Convert a query to output rows. A query is either a gene or region
+// and an amino acid position, such as 'RT:103', or a genome position,
+// optionally preceded by the name of the genome, such as 'HXB2:2550'.
+// A genome position has a row for each gene that contains it.
+func convertCoords(profile *ap.AlignmentProfile, query string) ([]string, error) {
+	query = strings.TrimSpace(query)
+	name, posText := "", query
+	if idx := strings.LastIndex(query, ":"); idx >= 0 {
+		name, posText = strings.TrimSpace(query[:idx]), strings.TrimSpace(query[idx+1:])
+	}
+	pos, err := strconv.Atoi(posText)
+	if err != nil || pos < 1 {
+		return nil, fmt.Errorf("Invalid position '%v'", query)
+	}
+
+	if name == "" || strings.EqualFold(name, profile.Genome.Name) {
+		rows := make([]string, 0)
+		for _, genePos := range profile.GenePositionsAt(pos) {
+			first, last, _ := profile.GenomePositionsOf(genePos.Gene, genePos.Position)
+			rows = append(rows, formatCoordsRow(
+				profile, query, genePos.Gene, genePos.Position,
+				first, last, strconv.Itoa(genePos.CodonBase)))
+		}
+		if len(rows) == 0 {
+			return nil, fmt.Errorf("%v isn't part of any gene placed in %v", query, profile.Genome.Name)
+		}
+		return rows, nil
+	}
+
+	gene, found := findGene(name, profile.Genes())
+	if !found {
+		region, isRegion := profile.FindRegion(name)
+		if !isRegion {
+			return nil, fmt.Errorf("%v is neither a gene nor a region of the profile", name)
+		}
+		if pos > region.Length() {
+			return nil, fmt.Errorf("Region %v has only %d positions", region.Name, region.Length())
+		}
+		gene, pos = region.Gene, region.Start+pos-1
+	}
+	first, last, found := profile.GenomePositionsOf(gene, pos)
+	if !found {
+		return nil, fmt.Errorf("Position %d of gene %v isn't placed in %v", pos, gene, profile.Genome.Name)
+	}
+	return []string{formatCoordsRow(profile, query, gene, pos, first, last, "")}, nil
+}
+
+func coordsRun(cmd *cobra.Command, args []string) error {
+	profileName := args[0]
+	profile, found := builtin.Get(profileName)
+	if !found {
+		tmpl := `Unknown profile name: '%v'
+
+See 'nucamino profile list' for a list of available profiles`
+		return fmt.Errorf(tmpl, profileName)
+	}
+	if profile.Genome == nil {
+		return fmt.Errorf("The profile %v doesn't place its genes in a reference genome", profileName)
+	}
+	rows := []string{strings.Join(coordsColumns, "\t")}
+	for _, query := range args[1:] {
+		queryRows, err := convertCoords(profile, query)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, queryRows...)
+	}
+	fmt.Println(strings.Join(rows, "\n"))
+	return nil
+}
+
+var coordsLongMsg = `
+Converts positions between the genes of a built-in profile and the
+reference genome the profile places them in, such as HXB2 for HIV-1
+or H77 for HCV. The first argument is the name of the profile; each
+following argument is a position to convert:
+
+	<gene or region>:<amino acid position>   the genome positions of the codon
+	[<genome>:]<genome position>             the codon of each gene containing the base
+
+The output is a table with a row per converted position. Genome
+positions in overlapping genes have a row for each gene.
+
+Examples:
+
+	nucamino coords hiv1b RT:103 POL:100
+	nucamino coords hiv1b HXB2:2550 3000
+	nucamino coords hcv1a NS5A:93`
+
+var coordsCmd = &cobra.Command{
+	Use:   "coords <profile name> <positions>...",
+	Short: "convert between gene positions and reference genome positions",
+	Long:  coordsLongMsg,
+	Args:  cobra.MinimumNArgs(2),
+	RunE:  coordsRun,
+}
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"github.com/hivdb/nucamino/alignmentprofile/builtin"
+	"reflect"
+	"testing"
+)
+
+func TestConvertCoords(t *testing.T) {
+	profile, _ := builtin.Get("hiv1b")
+	okCases := []struct {
+		query  string
+		expect []string
+	}{
+		{"RT:103", []string{"RT:103\tPOL\t258\tRT\t103\tHXB2\t2856\t2858\t"}},
+		{"pol:1", []string{"pol:1\tPOL\t1\t\t\tHXB2\t2085\t2087\t"}},
+		{"HXB2:2550", []string{"HXB2:2550\tPOL\t156\tRT\t1\tHXB2\t2550\t2552\t1"}},
+		{"2088", []string{
+			"2088\tGAG\t433\t\t\tHXB2\t2086\t2088\t3",
+			"2088\tPOL\t2\t\t\tHXB2\t2088\t2090\t1",
+		}},
+	}
+	for _, c := range okCases {
+		rows, err := convertCoords(profile, c.query)
+		if err != nil || !reflect.DeepEqual(rows, c.expect) {
+			t.Errorf("Expected %#v for %v, got %#v (%v)", c.expect, c.query, rows, err)
+		}
+	}
+	errCases := []string{"RT:0", "RT:561", "POL:1004", "NEF:1", "100", "H77:2550", "PR:x"}
+	for _, query := range errCases {
+		if rows, err := convertCoords(profile, query); err == nil {
+			t.Errorf("Expected an error for %v, got %#v", query, rows)
+		}
+	}
+}
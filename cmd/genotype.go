@@ -0,0 +1,285 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/hivdb/nucamino/aligner"
+	ap "github.com/hivdb/nucamino/alignmentprofile"
+	"github.com/hivdb/nucamino/alignmentprofile/builtin"
+	"github.com/hivdb/nucamino/cli"
+	"github.com/pkg/profile"
+	"github.com/spf13/cobra"
+	"path"
+	"strings"
+)
+
+// The cobra cli library will populate these variables with values
+// provided as command line flags.
+var genotypeInputFilename, genotypeOutputFilename, genotypeOutputFormat string
+var genotypeAlignmentMode, genotypeDuplicateNames string
+var genotypeQuiet, genotypePprof, genotypeBothStrands, genotypeRecombination bool
+var genotypeGoroutines, genotypeMinQuality int
+var genotypeQualityWeighting bool
+var genotypeMinScorePerCodon, genotypeMinCoverage float64
+var genotypeMaxFrameShifts, genotypeMaxStopCodons int
+var genotypeWindowSize, genotypeWindowStep int
+var genotypeMinWindowMargin, genotypeMinDetectionScore float64
+
+func init() {
+	rootCmd.AddCommand(genotypeCmd)
+
+	genotypeCmd.Flags().StringVarP(
+		&genotypeInputFilename,
+		"input-file",
+		"i",
+		"-",
+		"input file (FASTA or FASTQ, optionally compressed with gzip, bzip2 or zstd)",
+	)
+	genotypeCmd.Flags().StringVarP(
+		&genotypeOutputFilename,
+		"output-file",
+		"o",
+		"-",
+		"output File (compressed if the name ends with .gz or .zst)",
+	)
+	genotypeCmd.Flags().StringVarP(
+		&genotypeOutputFormat,
+		"output-format",
+		"f",
+		"tsv",
+		"output format. (options: \"tsv\", \"json\", \"ndjson\")",
+	)
+	genotypeCmd.Flags().BoolVarP(
+		&genotypeQuiet,
+		"quiet",
+		"q",
+		false,
+		"hide non-error output message",
+	)
+	genotypeCmd.Flags().BoolVarP(
+		&genotypePprof,
+		"pprof",
+		"p",
+		false,
+		"save profiling information",
+	)
+	genotypeCmd.Flags().IntVar(
+		&genotypeGoroutines,
+		"goroutines",
+		0,
+		"number of goroutines the aligner will use. (default: number of CPUs)",
+	)
+	genotypeCmd.Flags().Float64Var(
+		&genotypeMinDetectionScore,
+		"min-detection-score",
+		aligner.DefaultMinDetectionScore,
+		"minimum alignment score for a profile to be ranked; sequences scoring less on every profile get no best profile",
+	)
+	genotypeCmd.Flags().BoolVar(
+		&genotypeBothStrands,
+		"both-strands",
+		false,
+		"also align the reverse complement of each sequence and keep the better strand",
+	)
+	genotypeCmd.Flags().StringVar(
+		&genotypeAlignmentMode,
+		"alignment-mode",
+		"",
+		"alignment mode. (options: \"semiglobal\", \"global\", \"local\"; default: the mode of each profile)",
+	)
+	genotypeCmd.Flags().IntVar(
+		&genotypeMinQuality,
+		"min-quality",
+		0,
+		"replace bases of FASTQ input whose Phred quality is below this value by N",
+	)
+	genotypeCmd.Flags().BoolVar(
+		&genotypeQualityWeighting,
+		"quality-weighting",
+		false,
+		"weight codon scores of FASTQ input by base quality and report the codon quality of mutations",
+	)
+	addThresholdFlags(
+		genotypeCmd, &genotypeMinScorePerCodon, &genotypeMinCoverage,
+		&genotypeMaxFrameShifts, &genotypeMaxStopCodons)
+	genotypeCmd.Flags().BoolVar(
+		&genotypeRecombination,
+		"recombination",
+		false,
+		"scan windows of each sequence for segments matching different profiles, such as recombinants",
+	)
+	genotypeCmd.Flags().IntVar(
+		&genotypeWindowSize,
+		"window-size",
+		aligner.DefaultWindowSize,
+		"number of bases of each window scanned for recombination",
+	)
+	genotypeCmd.Flags().IntVar(
+		&genotypeWindowStep,
+		"window-step",
+		aligner.DefaultWindowStep,
+		"number of bases between the starts of consecutive windows scanned for recombination",
+	)
+	genotypeCmd.Flags().Float64Var(
+		&genotypeMinWindowMargin,
+		"min-window-margin",
+		aligner.DefaultMinWindowMargin,
+		"minimum difference in score per codon between the best and second best profile of a window",
+	)
+	genotypeCmd.Flags().StringVar(
+		&genotypeDuplicateNames,
+		"duplicate-names",
+		"suffix",
+		"what to do with sequences whose name was already used. (options: \"error\", \"suffix\" to append _2, _3, ..., \"allow\")",
+	)
+}
+
+// The names of the built-in profiles a comma separated list of names
+// and glob patterns refers to, in the order they were first listed
+func matchProfileNames(profilesArg string) ([]string, error) {
+	available := builtin.List()
+	names := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, pattern := range strings.Split(profilesArg, ",") {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == "" {
+			continue
+		}
+		matched := false
+		for _, name := range available {
+			ok, err := path.Match(pattern, name)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid profile pattern '%v': %v", pattern, err)
+			}
+			if !ok {
+				continue
+			}
+			matched = true
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+		if !matched {
+			tmpl := `No profile matches '%v'
+
+See 'nucamino profile list' for a list of available profiles`
+			return nil, fmt.Errorf(tmpl, pattern)
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("No profiles given")
+	}
+	return names, nil
+}
+
+func genotypeGetParameters(args []string) ([]string, []ap.AlignmentProfile, string, error) {
+	names, err := matchProfileNames(args[0])
+	if err != nil {
+		return nil, nil, "", err
+	}
+	profiles := make([]ap.AlignmentProfile, len(names))
+	var gene ap.Gene
+	for idx, name := range names {
+		profile, _ := builtin.Get(name)
+		profileGenes := profile.Genes()
+		profileGene, found := findGene(args[1], profileGenes)
+		if !found {
+			tmpl := "%v is not an available gene in the profile %v (available genes: %v)"
+			err := fmt.Errorf(tmpl, strings.ToUpper(strings.TrimSpace(args[1])), name, profileGenes)
+			return nil, nil, "", err
+		}
+		if err := setAlignmentMode(profile, genotypeAlignmentMode); err != nil {
+			return nil, nil, "", err
+		}
+		gene = profileGene
+		profiles[idx] = *profile
+	}
+	return names, profiles, string(gene), nil
+}
+
+func genotypeRun(cmd *cobra.Command, args []string) error {
+	if genotypePprof {
+		defer profile.Start(profile.CPUProfile).Stop()
+	}
+	names, profiles, gene, err := genotypeGetParameters(args)
+	if err != nil {
+		return err
+	}
+	thresholds, err := getThresholds(
+		cmd, genotypeMinScorePerCodon, genotypeMinCoverage,
+		genotypeMaxFrameShifts, genotypeMaxStopCodons)
+	if err != nil {
+		return err
+	}
+	var scan *aligner.ScanOptions
+	if genotypeRecombination {
+		if genotypeWindowSize < 3 || genotypeWindowStep < 1 {
+			return fmt.Errorf("The window size must be at least 3 and the window step at least 1")
+		}
+		scan = &aligner.ScanOptions{
+			WindowSize: genotypeWindowSize,
+			WindowStep: genotypeWindowStep,
+		}
+		if cmd.Flags().Changed("min-window-margin") {
+			scan.MinWindowMargin = &genotypeMinWindowMargin
+		}
+	}
+	return cli.PerformGenotyping(
+		genotypeInputFilename,
+		genotypeOutputFilename,
+		genotypeOutputFormat,
+		gene,
+		genotypeGoroutines,
+		genotypeQuiet,
+		names,
+		profiles,
+		aligner.Options{
+			BothStrands:       genotypeBothStrands,
+			MinQuality:        genotypeMinQuality,
+			QualityWeighting:  genotypeQualityWeighting,
+			MinDetectionScore: genotypeMinDetectionScore,
+			Thresholds:        thresholds,
+		},
+		scan,
+		cli.DuplicateNamePolicy(genotypeDuplicateNames),
+	)
+}
+
+var genotypeLongMsg = `
+Loads nucleotide sequences from a FASTA (or FASTQ) file and aligns
+each of them against the same gene of several built-in profiles, such
+as the profiles of the genotypes of a virus. The first argument is a
+comma separated list of profile names, which may contain glob patterns
+like 'hcv*'. The second argument is the gene to align against; every
+profile must have it.
+
+The profiles are ranked by the normalized score of the alignment of
+each sequence. The output reports the best profile, the runner-up and
+the difference between their scores, followed by the alignment against
+the best profile. Profiles a sequence scores less than
+--min-detection-score on aren't ranked, so that short or off-target
+sequences aren't assigned a best profile.
+
+With --recombination, overlapping windows of each sequence are scored
+against every profile as well. The output then reports the segments
+of the sequence that match different profiles and the breakpoints
+between them, which reveal recombinants such as HIV CRFs. The windows
+are only scored against the given gene, so breakpoints outside of it
+are never found. For example, the breakpoint of HCV 2k/1b lies in NS2,
+which the built-in HCV profiles don't define.
+
+Examples:
+
+	nucamino genotype 'hcv*' NS3
+	nucamino genotype hiv2a,hiv2b pol
+	nucamino genotype 'hcv*' NS5B --recombination
+
+See 'nucamino profile list' for the available alignment profiles.`
+
+var genotypeCmd = &cobra.Command{
+	Use:   "genotype <profile names> <gene> [flags]",
+	Short: "find the built-in alignment profile each sequence in a FASTA file matches best",
+	Long:  genotypeLongMsg,
+	Args:  cobra.ExactArgs(2),
+	RunE:  genotypeRun,
+}
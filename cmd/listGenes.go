@@ -37,6 +37,9 @@ see 'nucamino profile list' for a list of available alignment profiles`
 	}
 	for _, gene := range(genes) {
 		fmt.Println(gene)
+		for _, region := range profile.RegionsOf(gene) {
+			fmt.Printf("  %s (%d-%d)\n", region.Name, region.Start, region.End)
+		}
 	}
 	return nil
 }
@@ -46,7 +49,8 @@ var listGenesCmd = &cobra.Command{
 	Use:   "list-genes profile [pattern]",
 	Short: "List the available genes in a built-in alignment profile",
 	Long: `This command lists the genes available  in a built-in alignment
-profile. These names could be used to construct an align command, or just
+profile, each followed by the regions of the gene and their positions
+in it. These names could be used to construct an align command, or just
 to learn about the available options without printing out the whole profile.
 
 The pattern argument is used to filter the list. It's interpreted as a
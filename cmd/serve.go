@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"github.com/hivdb/nucamino/cli"
+	"github.com/spf13/cobra"
+	"runtime"
+	"time"
+)
+
+// The cobra cli library will populate these variables with values
+// provided as command line flags.
+var serveAddress string
+var serveMaxRequestBytes int64
+var serveTimeout time.Duration
+var serveReadHeaderTimeout, serveReadTimeout, serveIdleTimeout time.Duration
+var serveGoroutines, serveMaxConcurrent int
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	serveCmd.Flags().StringVarP(
+		&serveAddress,
+		"listen",
+		"l",
+		":8080",
+		"address the server listens on",
+	)
+	serveCmd.Flags().Int64Var(
+		&serveMaxRequestBytes,
+		"max-request-size",
+		10*1024*1024,
+		"maximum size of a request body in bytes",
+	)
+	serveCmd.Flags().DurationVar(
+		&serveTimeout,
+		"timeout",
+		60*time.Second,
+		"maximum time spent on aligning the sequences of a request",
+	)
+	serveCmd.Flags().DurationVar(
+		&serveReadHeaderTimeout,
+		"read-header-timeout",
+		10*time.Second,
+		"maximum time spent on reading the headers of a request",
+	)
+	serveCmd.Flags().DurationVar(
+		&serveReadTimeout,
+		"read-timeout",
+		60*time.Second,
+		"maximum time spent on reading a whole request, including the body",
+	)
+	serveCmd.Flags().DurationVar(
+		&serveIdleTimeout,
+		"idle-timeout",
+		120*time.Second,
+		"maximum time an idle keep-alive connection is kept open",
+	)
+	serveCmd.Flags().IntVar(
+		&serveGoroutines,
+		"goroutines",
+		0,
+		"number of goroutines used per request. (default: number of CPUs)",
+	)
+	serveCmd.Flags().IntVar(
+		&serveMaxConcurrent,
+		"max-concurrent",
+		0,
+		"number of requests aligned at the same time; the others wait. (default: number of CPUs)",
+	)
+}
+
+func serveRun(cmd *cobra.Command, args []string) error {
+	goroutines := serveGoroutines
+	if goroutines == 0 {
+		goroutines = runtime.NumCPU()
+	}
+	maxConcurrent := serveMaxConcurrent
+	if maxConcurrent == 0 {
+		maxConcurrent = runtime.NumCPU()
+	}
+	return cli.Serve(serveAddress, cli.ServerOptions{
+		MaxRequestBytes:         serveMaxRequestBytes,
+		Timeout:                 serveTimeout,
+		Goroutines:              goroutines,
+		MaxConcurrentAlignments: maxConcurrent,
+		ReadHeaderTimeout:       serveReadHeaderTimeout,
+		ReadTimeout:             serveReadTimeout,
+		IdleTimeout:             serveIdleTimeout,
+	})
+}
+
+var serveLongMsg = `
+Runs a long-lived HTTP server which aligns sequences posted to it,
+so that the profiles and score handlers are only loaded once.
+
+Endpoints:
+
+	POST /align     align sequences
+	GET  /profiles  list the built-in profiles and their genes
+
+Sequences can be posted as FASTA, with the profile and genes given
+in the query string:
+
+	curl --data-binary @seqs.fasta 'localhost:8080/align?profile=hiv1b&genes=POL'
+
+or as JSON, which also accepts a custom profile in YAML format:
+
+	curl -H 'Content-Type: application/json' localhost:8080/align -d '{
+	  "Profile": "hiv1b",
+	  "Genes": ["POL"],
+	  "Sequences": [{"Name": "seq1", "Sequence": "CCTCAGATCACTCTTTGG..."}]
+	}'
+
+The response has the same layout as the output of
+'nucamino align -f json'.`
+
+var serveCmd = &cobra.Command{
+	Use:   "serve [flags]",
+	Short: "run an HTTP server that aligns sequences posted as FASTA or JSON",
+	Long:  serveLongMsg,
+	Args:  cobra.NoArgs,
+	RunE:  serveRun,
+}
@@ -0,0 +1,48 @@
+package data
+
+import . "github.com/hivdb/nucamino/types/amino"
+
+// The rows of the built-in substitution matrices for the amino acid
+// codes other than the 20 standard amino acids: B (N or D), Z (Q or
+// E), X (unknown) and Stop. The scores are those of the NCBI versions
+// of the matrices; the matching columns are filled in by init.
+var extendedRows = map[string]map[AminoAcid]map[AminoAcid]int8{
+	"BLOSUM45": {
+		B:    {A: -1, R: -1, N: 4, D: 5, C: -2, Q: 0, E: 1, G: -1, H: 0, I: -3, L: -3, K: 0, M: -2, F: -3, P: -2, S: 0, T: 0, W: -4, Y: -2, V: -3, B: 5, Z: 2, X: -1, Stop: -5},
+		Z:    {A: -1, R: 0, N: 0, D: 1, C: -3, Q: 4, E: 4, G: -2, H: 0, I: -3, L: -2, K: 1, M: -1, F: -3, P: -1, S: 0, T: -1, W: -2, Y: -2, V: -3, B: 2, Z: 5, X: -1, Stop: -5},
+		X:    {A: -1, R: -1, N: -1, D: -1, C: -2, Q: -1, E: -1, G: -1, H: -1, I: -1, L: -1, K: -1, M: -1, F: -1, P: -1, S: -1, T: 0, W: -2, Y: -1, V: -1, B: -1, Z: -1, X: -1, Stop: -5},
+		Stop: {A: -5, R: -5, N: -5, D: -5, C: -5, Q: -5, E: -5, G: -5, H: -5, I: -5, L: -5, K: -5, M: -5, F: -5, P: -5, S: -5, T: -5, W: -5, Y: -5, V: -5, B: -5, Z: -5, X: -5, Stop: 1},
+	},
+	"BLOSUM62": {
+		B:    {A: -2, R: -1, N: 3, D: 4, C: -3, Q: 0, E: 1, G: -1, H: 0, I: -3, L: -4, K: 0, M: -3, F: -3, P: -2, S: 0, T: -1, W: -4, Y: -3, V: -3, B: 4, Z: 1, X: -1, Stop: -4},
+		Z:    {A: -1, R: 0, N: 0, D: 1, C: -3, Q: 3, E: 4, G: -2, H: 0, I: -3, L: -3, K: 1, M: -1, F: -3, P: -1, S: 0, T: -1, W: -3, Y: -2, V: -2, B: 1, Z: 4, X: -1, Stop: -4},
+		X:    {A: 0, R: -1, N: -1, D: -1, C: -2, Q: -1, E: -1, G: -1, H: -1, I: -1, L: -1, K: -1, M: -1, F: -1, P: -2, S: 0, T: 0, W: -2, Y: -1, V: -1, B: -1, Z: -1, X: -1, Stop: -4},
+		Stop: {A: -4, R: -4, N: -4, D: -4, C: -4, Q: -4, E: -4, G: -4, H: -4, I: -4, L: -4, K: -4, M: -4, F: -4, P: -4, S: -4, T: -4, W: -4, Y: -4, V: -4, B: -4, Z: -4, X: -4, Stop: 1},
+	},
+	"BLOSUM80": {
+		B:    {A: -2, R: -2, N: 4, D: 5, C: -4, Q: -1, E: 1, G: -1, H: -1, I: -4, L: -4, K: -1, M: -3, F: -4, P: -2, S: 0, T: -1, W: -5, Y: -3, V: -4, B: 4, Z: 0, X: -2, Stop: -6},
+		Z:    {A: -1, R: 0, N: 0, D: 1, C: -4, Q: 3, E: 4, G: -3, H: 0, I: -4, L: -3, K: 1, M: -2, F: -4, P: -2, S: 0, T: -1, W: -4, Y: -3, V: -3, B: 0, Z: 4, X: -1, Stop: -6},
+		X:    {A: -1, R: -1, N: -1, D: -2, C: -3, Q: -1, E: -1, G: -2, H: -2, I: -2, L: -2, K: -1, M: -1, F: -2, P: -2, S: -1, T: -1, W: -3, Y: -2, V: -1, B: -2, Z: -1, X: -1, Stop: -6},
+		Stop: {A: -6, R: -6, N: -6, D: -6, C: -6, Q: -6, E: -6, G: -6, H: -6, I: -6, L: -6, K: -6, M: -6, F: -6, P: -6, S: -6, T: -6, W: -6, Y: -6, V: -6, B: -6, Z: -6, X: -6, Stop: 1},
+	},
+	"PAM250": {
+		B:    {A: 0, R: -1, N: 2, D: 3, C: -4, Q: 1, E: 3, G: 0, H: 1, I: -2, L: -3, K: 1, M: -2, F: -4, P: -1, S: 0, T: 0, W: -5, Y: -3, V: -2, B: 3, Z: 2, X: -1, Stop: -8},
+		Z:    {A: 0, R: 0, N: 1, D: 3, C: -5, Q: 3, E: 3, G: 0, H: 2, I: -2, L: -3, K: 0, M: -2, F: -5, P: 0, S: 0, T: -1, W: -6, Y: -4, V: -2, B: 2, Z: 3, X: -1, Stop: -8},
+		X:    {A: -1, R: -1, N: 0, D: -1, C: -3, Q: -1, E: -1, G: -1, H: -1, I: -1, L: -1, K: -1, M: -1, F: -2, P: -1, S: 0, T: 0, W: -4, Y: -2, V: -1, B: -1, Z: -1, X: -1, Stop: -8},
+		Stop: {A: -8, R: -8, N: -8, D: -8, C: -8, Q: -8, E: -8, G: -8, H: -8, I: -8, L: -8, K: -8, M: -8, F: -8, P: -8, S: -8, T: -8, W: -8, Y: -8, V: -8, B: -8, Z: -8, X: -8, Stop: 1},
+	},
+}
+
+func init() {
+	for name, rows := range extendedRows {
+		matrix := substitutionMatrices[name]
+		for aa, row := range rows {
+			matrix[aa] = row
+		}
+		for aa1, row := range rows {
+			for aa2, score := range row {
+				matrix[aa2][aa1] = score
+			}
+		}
+	}
+}
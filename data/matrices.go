@@ -0,0 +1,39 @@
+package data
+
+import (
+	. "github.com/hivdb/nucamino/types/amino"
+	"sort"
+)
+
+// The name of the substitution matrix used by profiles that don't
+// specify one.
+const DefaultSubstitutionMatrix = "BLOSUM62"
+
+var substitutionMatrices = map[string]map[AminoAcid]map[AminoAcid]int8{
+	"BLOSUM45": blosum45,
+	"BLOSUM62": blosum62,
+	"BLOSUM80": blosum80,
+	"PAM250":   pam250,
+}
+
+// Look up a built-in substitution matrix by its (upper case) name.
+// The returned function gives the score of substituting aa1 by aa2.
+func LookupSubstitutionMatrix(name string) (func(aa1 AminoAcid, aa2 AminoAcid) int8, bool) {
+	matrix, found := substitutionMatrices[name]
+	if !found {
+		return nil, false
+	}
+	return func(aa1 AminoAcid, aa2 AminoAcid) int8 {
+		return matrix[aa1][aa2]
+	}, true
+}
+
+// The names of all built-in substitution matrices, sorted.
+func SubstitutionMatrixNames() []string {
+	names := make([]string, 0, len(substitutionMatrices))
+	for name := range substitutionMatrices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -7,7 +7,7 @@ if [ -z $GOPATH ]; then
 fi
 
 cd ${GOPATH}/src/github.com/hivdb/nucamino
-go get ./...
+hack/deps.sh
 mkdir -p $BUILDFOLDER
 cd $BUILDFOLDER
 echo "`go build -gcflags="-l=4" -compiler="gc" -v github.com/hivdb/nucamino 2>&1` => ./$BUILDFOLDER/nucamino"
@@ -7,8 +7,12 @@ mkdir -p build
 cd build
 for GOOS in darwin linux windows; do
     for GOARCH in 386 amd64; do
+        # Go no longer supports darwin/386
+        if [ ${GOOS} = darwin ] && [ ${GOARCH} = 386 ]; then
+            continue
+        fi
         # Fetch for each architecture to handle platform-specific dependencies
-        GOOS=${GOOS} GOARCH=${GOARCH} go get ../...
+        GOOS=${GOOS} GOARCH=${GOARCH} ../hack/deps.sh
         echo "`GOOS=${GOOS} GOARCH=${GOARCH} go build -gcflags="-l=4" -compiler="gc" -v -o nucamino-${GOOS}-${GOARCH} github.com/hivdb/nucamino 2>&1` => ./build/nucamino-${GOOS}-${GOARCH}"
     done
 done
@@ -0,0 +1,14 @@
+#! /bin/sh
+# Fetch dependencies and check out the revisions pinned in Godeps/Godeps.json
+
+set -e
+cd `dirname $0`/..
+if [ -z $GOPATH ]; then
+    GOPATH=${HOME}/go
+fi
+
+go get -d -t ./...
+sed -n 's/.*"\(ImportPath\|Rev\)": "\(.*\)".*/\2/p' Godeps/Godeps.json | tail -n +2 | \
+    while read pkg && read rev; do
+        git -C ${GOPATH%%:*}/src/${pkg} checkout -q ${rev}
+    done
@@ -18,6 +18,13 @@ type ScoreHandler interface {
 		/* base2 */ n.NucleicAcid,
 		/* base3 */ n.NucleicAcid,
 		/* ref */ a.AminoAcid) int
+	GetQualityWeightedSubstitutionScore(
+		/* refPosition */ int,
+		/* base1 */ n.NucleicAcid,
+		/* base2 */ n.NucleicAcid,
+		/* base3 */ n.NucleicAcid,
+		/* ref */ a.AminoAcid,
+		/* codonQuality */ int) int
 	GetGapExtensionScore() int
 	GetGapOpeningScore() int
 	GetConstantIndelCodonScore() (
@@ -2,10 +2,10 @@ package general
 
 import (
 	ap "github.com/hivdb/nucamino/alignmentprofile"
-	d "github.com/hivdb/nucamino/data"
 	a "github.com/hivdb/nucamino/types/amino"
 	c "github.com/hivdb/nucamino/types/codon"
 	n "github.com/hivdb/nucamino/types/nucleic"
+	"math"
 )
 
 const negInf = -int((^uint(0))>>1) - 1
@@ -14,6 +14,20 @@ const negInf = -int((^uint(0))>>1) - 1
 const FNVPrime int64 = 16777619
 const FNVOffsetBasis int64 = 2166136261
 
+// The highest Phred quality that can be encoded in a FASTQ file
+const maxQuality = 93
+
+// qualityWeights[q] is the probability, in thousandths, that a codon
+// whose lowest base quality is q was called correctly.
+var qualityWeights [maxQuality + 1]int
+
+func init() {
+	for quality := range qualityWeights {
+		qualityWeights[quality] = int(math.Floor(
+			1000*(1-math.Pow(10, -float64(quality)/10)) + 0.5))
+	}
+}
+
 func simpleFNV1a(key int) int64 {
 	// http://www.isthe.com/chongo/tech/comp/fnv/index.html
 	// The possible values of "key" are between -50000 and 50000
@@ -34,7 +48,9 @@ type GeneralScoreHandler struct {
 	positionalIndelScores            map[int][2]int
 	positionalIndelScoresBloomFilter int64
 	isPositionalIndelScoreSupported  bool
-	scoreMatrix                      *[a.NumAminoAcids][n.NumNucleicAcids][n.NumNucleicAcids][n.NumNucleicAcids]int
+	scoreMatrix                      *[a.NumAminoAcidCodes][n.NumNucleicAcids][n.NumNucleicAcids][n.NumNucleicAcids]int
+	substitutionMatrix               *ap.SubstitutionMatrix
+	alignmentMode                    ap.AlignmentMode
 }
 
 func (self *GeneralScoreHandler) GetCachedSubstitutionScore(
@@ -50,6 +66,15 @@ func (self *GeneralScoreHandler) GetCachedSubstitutionScore(
 	return score, present
 }
 
+// A stop codon is penalized unless the reference has a stop codon
+// ("*") at the same position.
+func (self *GeneralScoreHandler) getStopCodonScore(ref a.AminoAcid) int {
+	if ref == a.Stop {
+		return self.substitutionMatrix.Score(a.Stop, a.Stop) * self.scoreScale
+	}
+	return -self.stopCodonPenalty
+}
+
 func (self *GeneralScoreHandler) GetSubstitutionScoreNoCache(
 	position int,
 	base1 n.NucleicAcid,
@@ -64,9 +89,9 @@ func (self *GeneralScoreHandler) GetSubstitutionScoreNoCache(
 		numAAs := 0
 		for _, ucodon := range codon.GetUnambiguousCodons() {
 			if ucodon.IsStopCodon() {
-				scores -= self.stopCodonPenalty
+				scores += self.getStopCodonScore(ref)
 			}
-			scores += int(d.LookupBlosum62(ucodon.ToAminoAcidUnsafe(), ref)) * self.scoreScale
+			scores += self.substitutionMatrix.Score(ucodon.ToAminoAcidUnsafe(), ref) * self.scoreScale
 			numAAs++
 		}
 		score = scores / numAAs
@@ -74,11 +99,11 @@ func (self *GeneralScoreHandler) GetSubstitutionScoreNoCache(
 		// 	print(codon.ToString(), " ", score, "\n")
 		// }
 	} else if codon.IsStopCodon() {
-		score = -self.stopCodonPenalty
+		score = self.getStopCodonScore(ref)
 	} else {
 		// unambiguous codon, can use unsafe function safely
 		aa := codon.ToAminoAcidUnsafe()
-		score = int(d.LookupBlosum62(aa, ref)) * self.scoreScale
+		score = self.substitutionMatrix.Score(aa, ref) * self.scoreScale
 	}
 	self.scoreMatrix[ref][base1][base2][base3] = score
 	return
@@ -98,6 +123,36 @@ func (self *GeneralScoreHandler) GetSubstitutionScore(
 	return self.GetSubstitutionScoreNoCache(position, base1, base2, base3, ref)
 }
 
+// The substitution score of a codon whose lowest base quality is the
+// Phred score quality. The score is weighted by the probability that
+// the codon was called correctly, so that low-quality codons
+// contribute less to the alignment score.
+func (self *GeneralScoreHandler) GetQualityWeightedSubstitutionScore(
+	position int,
+	base1 n.NucleicAcid,
+	base2 n.NucleicAcid,
+	base3 n.NucleicAcid,
+	ref a.AminoAcid,
+	quality int) int {
+	if quality < 0 {
+		quality = 0
+	} else if quality > maxQuality {
+		quality = maxQuality
+	}
+	score := self.GetSubstitutionScore(position, base1, base2, base3, ref)
+	return score * qualityWeights[quality] / 1000
+}
+
+// Scores returned by the handler are the profile's scores multiplied
+// by this factor.
+func (self *GeneralScoreHandler) GetScoreScale() int {
+	return self.scoreScale
+}
+
+func (self *GeneralScoreHandler) GetAlignmentMode() ap.AlignmentMode {
+	return self.alignmentMode
+}
+
 func (self *GeneralScoreHandler) GetGapOpeningScore() int {
 	return -self.gapOpenPenalty
 }
@@ -147,8 +202,9 @@ type GeneralScoreHandlerParams struct {
 }
 
 func New(gene ap.Gene, profile ap.AlignmentProfile) *GeneralScoreHandler {
+	profile = profile.ForGene(gene)
 	scoreScale := 100
-	scoreMatrix := [a.NumAminoAcids][n.NumNucleicAcids][n.NumNucleicAcids][n.NumNucleicAcids]int{}
+	scoreMatrix := [a.NumAminoAcidCodes][n.NumNucleicAcids][n.NumNucleicAcids][n.NumNucleicAcids]int{}
 	for i, matrix3d := range scoreMatrix {
 		for x, matrix2d := range matrix3d {
 			for y, matrix1d := range matrix2d {
@@ -177,5 +233,7 @@ func New(gene ap.Gene, profile ap.AlignmentProfile) *GeneralScoreHandler {
 		positionalIndelScoresBloomFilter: positionalIndelScoresBloomFilter,
 		isPositionalIndelScoreSupported:  supported,
 		scoreMatrix:                      &scoreMatrix,
+		substitutionMatrix:               profile.SubstitutionMatrixOrDefault(),
+		alignmentMode:                    profile.AlignmentMode,
 	}
 }
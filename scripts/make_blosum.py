@@ -32,10 +32,32 @@ def text2matrix(fname):
     return matrix
 
 
+def render_file(matrix, fname, varname):
+    return '\n'.join([
+        '// Code generated by scripts/make_blosum.py from {}; '
+        'DO NOT EDIT.'.format(fname),
+        '',
+        'package data',
+        '',
+        'import . "github.com/hivdb/nucamino/types/amino"',
+        '',
+        'var {} = map[AminoAcid]map[AminoAcid]int8{}'.format(
+            varname, render_golang(matrix)),
+    ])
+
+
 def main():
+    """Print the matrix of the given file as a Go map literal, or as a
+    complete file of the data package if a variable name is given:
+
+        make_blosum.py scripts/matrices/BLOSUM45 blosum45 > data/blosum45.go
+    """
     fname = sys.argv[1]
     matrix = text2matrix(fname)
-    print(render_golang(matrix))
+    if len(sys.argv) > 2:
+        print(render_file(matrix, fname, sys.argv[2]))
+    else:
+        print(render_golang(matrix))
 
 
 if __name__ == '__main__':
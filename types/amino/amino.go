@@ -1,8 +1,10 @@
 package amino
 
 import (
+	"fmt"
 	"github.com/hivdb/nucamino/utils"
 	"strings"
+	"unicode"
 )
 
 type AminoAcid int
@@ -28,15 +30,41 @@ const (
 	V
 	W
 	Y
+	// unknown amino acid
+	X
+	// stop codon
+	Stop
+	// N or D
+	B
+	// Q or E
+	Z
 )
 
+// The number of standard amino acids (A to Y)
 const NumAminoAcids = 20
 
+// The number of amino acid codes, including X, Stop, B and Z
+const NumAminoAcidCodes = 24
+
+// The standard amino acids
 var AminoAcids = [NumAminoAcids]AminoAcid{
 	A, C, D, E, F, G, H, I, K, L, M, N, P, Q, R, S, T, V, W, Y,
 }
 
-var aminoAcidLookup = [NumAminoAcids]string{
+// All amino acid codes: the standard amino acids followed by X, Stop,
+// B and Z
+var AminoAcidCodes = [NumAminoAcidCodes]AminoAcid{
+	A, C, D, E, F, G, H, I, K, L, M, N, P, Q, R, S, T, V, W, Y, X, Stop, B, Z,
+}
+
+// The standard amino acids each ambiguous amino acid code stands for
+var AmbiguousCodeMembers = map[AminoAcid][]AminoAcid{
+	B: {N, D},
+	Z: {Q, E},
+	X: AminoAcids[:],
+}
+
+var aminoAcidLookup = [NumAminoAcidCodes]string{
 	"A",
 	"C",
 	"D",
@@ -57,6 +85,10 @@ var aminoAcidLookup = [NumAminoAcids]string{
 	"V",
 	"W",
 	"Y",
+	"X",
+	"*",
+	"B",
+	"Z",
 }
 
 var aminoAcidLookupR = map[rune]AminoAcid{
@@ -80,12 +112,33 @@ var aminoAcidLookupR = map[rune]AminoAcid{
 	'V': V,
 	'W': W,
 	'Y': Y,
+	'X': X,
+	'*': Stop,
+	'B': B,
+	'Z': Z,
 }
 
 func ToString(aa AminoAcid) string {
 	return aminoAcidLookup[aa]
 }
 
+// Whether other is aa itself or, if aa is an ambiguous code, one of
+// the amino acids it stands for
+func (aa AminoAcid) Includes(other AminoAcid) bool {
+	if aa == other {
+		return true
+	}
+	for _, member := range AmbiguousCodeMembers[aa] {
+		if member == other {
+			return true
+		}
+	}
+	return false
+}
+
+// Read an amino acid sequence, skipping whitespace and any character
+// that isn't an amino acid code. Use Parse to reject such characters
+// instead.
 func ReadString(aminoAcidSequence string) []AminoAcid {
 	aminoAcidSequence = strings.ToUpper(
 		utils.StripWhiteSpace(aminoAcidSequence))
@@ -102,6 +155,35 @@ func ReadString(aminoAcidSequence string) []AminoAcid {
 	return result[:idx]
 }
 
+// An error returned by Parse. Position is the 1-based position of
+// the invalid character in the sequence, not counting whitespace.
+type InvalidAminoAcidError struct {
+	Position  int
+	Character rune
+}
+
+func (e *InvalidAminoAcidError) Error() string {
+	return fmt.Sprintf("Invalid amino acid '%c' at position %d", e.Character, e.Position)
+}
+
+// Read an amino acid sequence like ReadString, but return an
+// *InvalidAminoAcidError for the first character which isn't an
+// amino acid code or whitespace.
+func Parse(aminoAcidSequence string) ([]AminoAcid, error) {
+	result := make([]AminoAcid, 0, len(aminoAcidSequence))
+	for _, runeVal := range aminoAcidSequence {
+		if unicode.IsSpace(runeVal) {
+			continue
+		}
+		aa, present := aminoAcidLookupR[unicode.ToUpper(runeVal)]
+		if !present {
+			return nil, &InvalidAminoAcidError{len(result) + 1, runeVal}
+		}
+		result = append(result, aa)
+	}
+	return result, nil
+}
+
 func WriteString(aas []AminoAcid) string {
 	var result string
 	for _, aa := range aas {
@@ -25,9 +25,29 @@ func TestToString(t *testing.T) {
 	}
 }
 
+func TestIncludes(t *testing.T) {
+	includes := [][2]AminoAcid{
+		{A, A}, {Stop, Stop}, {B, B}, {B, N}, {B, D}, {Z, Q}, {Z, E},
+		{X, X}, {X, W},
+	}
+	for _, pair := range includes {
+		if !pair[0].Includes(pair[1]) {
+			t.Errorf("Expect %s to include %s", ToString(pair[0]), ToString(pair[1]))
+		}
+	}
+	excludes := [][2]AminoAcid{
+		{A, C}, {N, B}, {B, E}, {Z, D}, {X, Stop}, {Stop, X},
+	}
+	for _, pair := range excludes {
+		if pair[0].Includes(pair[1]) {
+			t.Errorf("Expect %s not to include %s", ToString(pair[0]), ToString(pair[1]))
+		}
+	}
+}
+
 func TestReadString(t *testing.T) {
-	result := ReadString("ABCDEFG")
-	expect := []AminoAcid{A, C, D, E, F, G} // ignore "B"
+	result := ReadString("ABCDEFG-1")
+	expect := []AminoAcid{A, B, C, D, E, F, G} // ignore "-" and "1"
 	if !reflect.DeepEqual(result, expect) {
 		t.Errorf(MSG_NOT_EQUAL, expect, result)
 	}
@@ -40,3 +60,16 @@ func TestWriteString(t *testing.T) {
 		t.Errorf(MSG_NOT_EQUAL, expect, result)
 	}
 }
+
+func TestParse(t *testing.T) {
+	result, err := Parse("acx*\n BZ")
+	expect := []AminoAcid{A, C, X, Stop, B, Z}
+	if err != nil || !reflect.DeepEqual(result, expect) {
+		t.Errorf(MSG_NOT_EQUAL, expect, result)
+	}
+	_, err = Parse("AC D-E")
+	expectErr := &InvalidAminoAcidError{4, '-'}
+	if !reflect.DeepEqual(err, expectErr) {
+		t.Errorf(MSG_NOT_EQUAL, expectErr, err)
+	}
+}
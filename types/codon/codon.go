@@ -98,17 +98,33 @@ var CodonToAminoAcidTable = map[Codon]a.AminoAcid{
 	Codon{G, G, G}: a.G,
 }
 
-var aminoAcidSearchMatrix = [a.NumAminoAcids][3][NumNucleicAcids][]Codon{}
+var aminoAcidSearchMatrix = [a.NumAminoAcidCodes][3][NumNucleicAcids][]Codon{}
+
+func addSearchCodon(aa a.AminoAcid, codon Codon) {
+	for idx, na := range codon.GetNucleicAcids() {
+		codons := aminoAcidSearchMatrix[aa][idx][na]
+		if codons == nil {
+			codons = make([]Codon, 0, 4)
+		}
+		codons = append(codons, codon)
+		aminoAcidSearchMatrix[aa][idx][na] = codons
+	}
+}
 
 func init() {
 	for codon, aa := range CodonToAminoAcidTable {
-		for idx, na := range codon.GetNucleicAcids() {
-			codons := aminoAcidSearchMatrix[aa][idx][na]
-			if codons == nil {
-				codons = make([]Codon, 0, 4)
+		addSearchCodon(aa, codon)
+		for ambiguous, members := range a.AmbiguousCodeMembers {
+			for _, member := range members {
+				if member == aa {
+					addSearchCodon(ambiguous, codon)
+				}
 			}
-			codons = append(codons, codon)
-			aminoAcidSearchMatrix[aa][idx][na] = codons
+		}
+	}
+	for codon := range stopCodons {
+		if !codon.IsAmbiguous() {
+			addSearchCodon(a.Stop, codon)
 		}
 	}
 }
@@ -21,6 +21,14 @@ type FrameShift struct {
 	IsInsertion      bool
 	IsDeletion       bool
 	GapLength        int
+	// The region of the gene the frameshift is in and its position in
+	// that region; only set if the profile defines regions.
+	Region         string `json:",omitempty"`
+	RegionPosition int    `json:",omitempty"`
+	// The position in the reference genome of the first base of the
+	// reference codon; only set if the profile places the gene in a
+	// genome.
+	GenomePosition int `json:",omitempty"`
 }
 
 func New(
@@ -21,6 +21,9 @@ func TestNew(t *testing.T) {
 		false,
 		true,
 		2,
+		"",
+		0,
+		0,
 	}
 	if !reflect.DeepEqual(result, expect) {
 		t.Errorf(MSG_NOT_EQUAL, expect, result)
@@ -35,6 +38,9 @@ func TestNew(t *testing.T) {
 		true,
 		false,
 		2,
+		"",
+		0,
+		0,
 	}
 	if !reflect.DeepEqual(result, expect) {
 		t.Errorf(MSG_NOT_EQUAL, expect, result)
@@ -56,6 +62,9 @@ func TestMakeFrameShift(t *testing.T) {
 		true,
 		false,
 		1,
+		"",
+		0,
+		0,
 	}
 	if !reflect.DeepEqual(result, expect) {
 		t.Errorf(MSG_NOT_EQUAL, expect, result)
@@ -70,6 +79,9 @@ func TestMakeFrameShift(t *testing.T) {
 		false,
 		true,
 		1,
+		"",
+		0,
+		0,
 	}
 	if !reflect.DeepEqual(result, expect) {
 		t.Errorf(MSG_NOT_EQUAL, expect, result)
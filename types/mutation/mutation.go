@@ -23,6 +23,17 @@ type Mutation struct {
 	InsertedCodonsText     string
 	InsertedAminoAcidsText string
 	insertedCodons         []c.Codon
+	// The lowest Phred quality of the bases of the codon; only set
+	// if the base qualities of the sequence are known.
+	CodonQuality *int
+	// The region of the gene the mutation is in and its position in
+	// that region; only set if the profile defines regions.
+	Region         string `json:",omitempty"`
+	RegionPosition int    `json:",omitempty"`
+	// The position in the reference genome of the first base of the
+	// reference codon; only set if the profile places the gene in a
+	// genome.
+	GenomePosition int `json:",omitempty"`
 }
 
 func New(
@@ -105,11 +116,11 @@ func MakeMutation(
 		codon := c.Codon{nas[0], nas[1], nas[2]}
 		allMatched := true
 		for _, ucodon := range codon.GetUnambiguousCodons() {
-			if ucodon.IsStopCodon() {
-				allMatched = false
-			} else {
-				allMatched = allMatched && ucodon.ToAminoAcidUnsafe() == ref
+			aa := a.Stop
+			if !ucodon.IsStopCodon() {
+				aa = ucodon.ToAminoAcidUnsafe()
 			}
+			allMatched = allMatched && ref.Includes(aa)
 		}
 		if allMatched {
 			control = ":::"
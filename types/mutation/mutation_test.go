@@ -16,7 +16,7 @@ func TestMakeMutation(t *testing.T) {
 	result := MakeMutation(155, 797, []n.NucleicAcid{n.A, n.C, n.T}, a.S)
 	expect := &Mutation{
 		155, 797, "ACT", "T", &c.Codon{n.A, n.C, n.T}, "S", a.S,
-		false, false, false, "...", "", "", nil,
+		false, false, false, "...", "", "", nil, nil, "", 0, 0,
 	}
 	if !reflect.DeepEqual(result, expect) {
 		t.Errorf(MSG_NOT_EQUAL, expect, result)
@@ -28,7 +28,7 @@ func TestMakeMutation(t *testing.T) {
 	result = MakeMutation(155, 797, []n.NucleicAcid{n.A, n.T}, a.S)
 	expect = &Mutation{
 		155, 797, "A T", "NTSI", &c.Codon{n.A, n.N, n.T}, "S", a.S,
-		false, false, true, ".-.", "", "", nil,
+		false, false, true, ".-.", "", "", nil, nil, "", 0, 0,
 	}
 	if !reflect.DeepEqual(result, expect) {
 		t.Errorf(MSG_NOT_EQUAL, expect, result)
@@ -36,7 +36,7 @@ func TestMakeMutation(t *testing.T) {
 	result = MakeMutation(155, 797, []n.NucleicAcid{}, a.S)
 	expect = &Mutation{
 		155, 797, "", "", nil, "S", a.S,
-		false, true, false, "---", "", "", nil,
+		false, true, false, "---", "", "", nil, nil, "", 0, 0,
 	}
 	if !reflect.DeepEqual(result, expect) {
 		t.Errorf(MSG_NOT_EQUAL, expect, result)
@@ -44,7 +44,7 @@ func TestMakeMutation(t *testing.T) {
 	result = MakeMutation(155, 797, []n.NucleicAcid{n.A, n.C, n.T, n.A, n.C, n.T, n.R, n.C, n.T}, a.T)
 	expect = &Mutation{
 		155, 797, "ACT", "T", &c.Codon{n.A, n.C, n.T}, "T", a.T,
-		true, false, false, ":::++++++", "ACTRCT", "T[TA]", []c.Codon{c.Codon{n.A, n.C, n.T}, c.Codon{n.R, n.C, n.T}},
+		true, false, false, ":::++++++", "ACTRCT", "T[TA]", []c.Codon{c.Codon{n.A, n.C, n.T}, c.Codon{n.R, n.C, n.T}}, nil, "", 0, 0,
 	}
 	if !reflect.DeepEqual(result, expect) {
 		t.Errorf(MSG_NOT_EQUAL, expect, result)
@@ -52,7 +52,45 @@ func TestMakeMutation(t *testing.T) {
 	result = MakeMutation(155, 797, []n.NucleicAcid{n.T, n.A, n.R}, a.L)
 	expect = &Mutation{
 		155, 797, "TAR", "*", &c.Codon{n.T, n.A, n.R}, "L", a.L,
-		false, false, false, "...", "", "", nil,
+		false, false, false, "...", "", "", nil, nil, "", 0, 0,
+	}
+	if !reflect.DeepEqual(result, expect) {
+		t.Errorf(MSG_NOT_EQUAL, expect, result)
+	}
+}
+
+func TestMakeMutationExtendedReference(t *testing.T) {
+	matches := []struct {
+		nas []n.NucleicAcid
+		ref a.AminoAcid
+	}{
+		{[]n.NucleicAcid{n.T, n.A, n.A}, a.Stop},
+		{[]n.NucleicAcid{n.T, n.A, n.R}, a.Stop},
+		{[]n.NucleicAcid{n.G, n.A, n.T}, a.B},
+		{[]n.NucleicAcid{n.R, n.A, n.T}, a.B},
+		{[]n.NucleicAcid{n.S, n.A, n.A}, a.Z},
+		{[]n.NucleicAcid{n.G, n.C, n.T}, a.X},
+	}
+	for _, m := range matches {
+		result := MakeMutation(1, 1, m.nas, m.ref)
+		if result != nil {
+			t.Errorf(MSG_NOT_EQUAL, nil, result)
+		}
+	}
+	result := MakeMutation(1, 1, []n.NucleicAcid{n.G, n.C, n.T}, a.B)
+	expect := &Mutation{
+		Position: 1, NAPosition: 1, CodonText: "GCT",
+		AminoAcidText: "A", codon: &c.Codon{n.G, n.C, n.T},
+		ReferenceText: "B", reference: a.B, Control: "...",
+	}
+	if !reflect.DeepEqual(result, expect) {
+		t.Errorf(MSG_NOT_EQUAL, expect, result)
+	}
+	result = MakeMutation(1, 1, []n.NucleicAcid{n.T, n.A, n.A}, a.X)
+	expect = &Mutation{
+		Position: 1, NAPosition: 1, CodonText: "TAA",
+		AminoAcidText: "*", codon: &c.Codon{n.T, n.A, n.A},
+		ReferenceText: "X", reference: a.X, Control: "...",
 	}
 	if !reflect.DeepEqual(result, expect) {
 		t.Errorf(MSG_NOT_EQUAL, expect, result)
@@ -3,6 +3,7 @@ package nucleic
 import (
 	"github.com/hivdb/nucamino/utils"
 	"strings"
+	"unicode"
 )
 
 type NucleicAcid int
@@ -85,6 +86,27 @@ var ambiguousNucleicAcids = [NumNucleicAcids][]NucleicAcid{
 	{A, C, G, T},
 }
 
+// The complement of each nucleic acid; ambiguous codes are
+// complemented to the code representing the complementary set
+// (e.g. R = A/G becomes Y = C/T)
+var complementNucleicAcids = [NumNucleicAcids]NucleicAcid{
+	T, // A
+	G, // C
+	C, // G
+	A, // T
+	W, // W
+	S, // S
+	K, // M
+	M, // K
+	Y, // R
+	R, // Y
+	V, // B
+	H, // D
+	D, // H
+	B, // V
+	N, // N
+}
+
 func (na NucleicAcid) ToString() string {
 	return nucleicAcidLookup[na]
 }
@@ -93,6 +115,13 @@ func (self NucleicAcid) IsAmbiguous() bool {
 	return self > T
 }
 
+// Look up the nucleic acid of an IUPAC code; the code is
+// case-insensitive.
+func LookupNucleicAcid(code rune) (NucleicAcid, bool) {
+	na, present := nucleicAcidLookupR[unicode.ToUpper(code)]
+	return na, present
+}
+
 func ReadString(nucleicAcidSequence string) []NucleicAcid {
 	nucleicAcidSequence = strings.ToUpper(
 		utils.StripWhiteSpace(nucleicAcidSequence))
@@ -115,6 +144,19 @@ func WriteString(nas []NucleicAcid) string {
 	return result
 }
 
+func (self NucleicAcid) Complement() NucleicAcid {
+	return complementNucleicAcids[self]
+}
+
+// Return the reverse complement of a sequence as a new slice
+func ReverseComplement(nas []NucleicAcid) []NucleicAcid {
+	result := make([]NucleicAcid, len(nas))
+	for idx, na := range nas {
+		result[len(nas)-1-idx] = na.Complement()
+	}
+	return result
+}
+
 func GetUnambiguousNucleicAcids(na NucleicAcid) []NucleicAcid {
 	return ambiguousNucleicAcids[na]
 }
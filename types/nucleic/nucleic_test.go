@@ -71,3 +71,32 @@ func TestGetUnambiguousNucleicAcids(t *testing.T) {
 		t.Errorf(MSG_NOT_EQUAL, expect, result)
 	}
 }
+
+func TestComplement(t *testing.T) {
+	for _, na := range NucleicAcids {
+		// the complement of an ambiguous code must represent the
+		// complements of its unambiguous nucleic acids
+		expect := make(map[NucleicAcid]bool)
+		for _, una := range GetUnambiguousNucleicAcids(na) {
+			expect[una.Complement()] = true
+		}
+		result := make(map[NucleicAcid]bool)
+		for _, una := range GetUnambiguousNucleicAcids(na.Complement()) {
+			result[una] = true
+		}
+		if !reflect.DeepEqual(expect, result) {
+			t.Errorf(MSG_NOT_EQUAL, expect, result)
+		}
+		if na.Complement().Complement() != na {
+			t.Errorf(MSG_NOT_EQUAL, na, na.Complement().Complement())
+		}
+	}
+}
+
+func TestReverseComplement(t *testing.T) {
+	result := WriteString(ReverseComplement(ReadString("AACGTRYKMBDHVNWS")))
+	expect := "SWNBDHVKMRYACGTT"
+	if result != expect {
+		t.Errorf(MSG_NOT_EQUAL, expect, result)
+	}
+}
@@ -0,0 +1,87 @@
+// This package transparently decompresses input streams and
+// compresses output files. Compressed input is detected by its magic
+// bytes, so it works on unnamed streams such as stdin; compressed
+// output is selected by the extension of the output file name.
+package compression
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	bzip2Magic = []byte("BZh")
+	zstdMagic  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// Wrap reader in a decompressor if it starts with the magic bytes of
+// gzip, bzip2 or zstd data; otherwise its content is returned as is.
+// Closing the returned reader doesn't close reader.
+func NewReader(reader io.Reader) (io.ReadCloser, error) {
+	buffered := bufio.NewReader(reader)
+	magic, err := buffered.Peek(len(zstdMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return gzip.NewReader(buffered)
+	case bytes.HasPrefix(magic, bzip2Magic):
+		return ioutil.NopCloser(bzip2.NewReader(buffered)), nil
+	case bytes.HasPrefix(magic, zstdMagic):
+		decoder, err := zstd.NewReader(buffered)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	default:
+		return ioutil.NopCloser(buffered), nil
+	}
+}
+
+// Wrap writer in a compressor chosen by the extension of fileName:
+// ".gz" for gzip and ".zst" for zstd. Other file names are written
+// uncompressed. The returned writer must be closed to flush the
+// compressed data; closing it doesn't close writer.
+func NewWriter(writer io.Writer, fileName string) (io.WriteCloser, error) {
+	if err := CheckOutputFileName(fileName); err != nil {
+		return nil, err
+	}
+	switch {
+	case strings.HasSuffix(fileName, ".gz"):
+		return gzip.NewWriter(writer), nil
+	case strings.HasSuffix(fileName, ".zst"):
+		return zstd.NewWriter(writer)
+	default:
+		return nopWriteCloser{writer}, nil
+	}
+}
+
+// Check that NewWriter supports the compression selected by the
+// extension of fileName, before the file is created. There's no
+// bzip2 compressor in the standard library, so bzip2 is only
+// supported for input.
+func CheckOutputFileName(fileName string) error {
+	if strings.HasSuffix(fileName, ".bz2") {
+		return fmt.Errorf(
+			"Cannot write %v: bzip2 compressed output is not supported (use .gz or .zst)",
+			fileName)
+	}
+	return nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,80 @@
+package compression
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+const (
+	MSG_NOT_EQUAL = "Expect %#v but received %#v"
+)
+
+const exampleFASTA = ">seq1\nACGTTGCA\n>seq2\nAAAA\n"
+
+func TestRoundTrip(t *testing.T) {
+	for _, fileName := range []string{"out.fas", "out.fas.gz", "out.fas.zst"} {
+		var compressed bytes.Buffer
+		writer, err := NewWriter(&compressed, fileName)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		writer.Write([]byte(exampleFASTA))
+		writer.Close()
+		if strings.HasSuffix(fileName, "z") && compressed.String() == exampleFASTA {
+			t.Errorf("Expected %v to be compressed", fileName)
+		}
+		reader, err := NewReader(&compressed)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		result, err := ioutil.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if string(result) != exampleFASTA {
+			t.Errorf(MSG_NOT_EQUAL, exampleFASTA, string(result))
+		}
+	}
+}
+
+func TestReadBzip2(t *testing.T) {
+	if _, err := exec.LookPath("bzip2"); err != nil {
+		t.Skip("bzip2 isn't installed")
+	}
+	cmd := exec.Command("bzip2", "-c")
+	cmd.Stdin = strings.NewReader(exampleFASTA)
+	compressed, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	reader, err := NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	result, _ := ioutil.ReadAll(reader)
+	if string(result) != exampleFASTA {
+		t.Errorf(MSG_NOT_EQUAL, exampleFASTA, string(result))
+	}
+}
+
+func TestReadShortInput(t *testing.T) {
+	for _, input := range []string{"", ">"} {
+		reader, err := NewReader(strings.NewReader(input))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		result, _ := ioutil.ReadAll(reader)
+		if string(result) != input {
+			t.Errorf(MSG_NOT_EQUAL, input, string(result))
+		}
+	}
+}
+
+func TestWriteBzip2(t *testing.T) {
+	if _, err := NewWriter(&bytes.Buffer{}, "out.fas.bz2"); err == nil {
+		t.Errorf("Expected an error for bzip2 output")
+	}
+}
@@ -0,0 +1,95 @@
+package fastareader
+
+import (
+	"fmt"
+	n "github.com/hivdb/nucamino/types/nucleic"
+	"unicode"
+)
+
+// A character of the input that isn't an IUPAC nucleotide code; it
+// was replaced by N at Position.
+type InvalidCharacter struct {
+	Position  int
+	Character string
+}
+
+// A range of positions, from First to Last
+type Range struct {
+	First int
+	Last  int
+}
+
+// What was changed while reading a sequence. Positions are 1-based
+// positions of the parsed sequence, i.e. after the gaps were
+// stripped.
+type Diagnostics struct {
+	// Characters which were replaced by N
+	InvalidCharacters []InvalidCharacter `json:",omitempty"`
+	// The number of gap characters ("-" and ".") which were removed
+	StrippedGaps int `json:",omitempty"`
+	// The ranges of lowercase (soft-masked) bases
+	SoftMaskedRanges []Range `json:",omitempty"`
+}
+
+// Whether nothing was changed while reading the sequence
+func (d *Diagnostics) IsEmpty() bool {
+	return len(d.InvalidCharacters) == 0 && d.StrippedGaps == 0 &&
+		len(d.SoftMaskedRanges) == 0
+}
+
+func (c InvalidCharacter) ToString() string {
+	return fmt.Sprintf("%s%d", c.Character, c.Position)
+}
+
+func (r Range) ToString() string {
+	return fmt.Sprintf("%d-%d", r.First, r.Last)
+}
+
+func isGap(char rune) bool {
+	return char == '-' || char == '.'
+}
+
+// Parse the bases of seqText, ignoring whitespace. qualText is either
+// empty, or holds the quality character of each base (gaps included)
+// as in FASTQ files; the qualities of stripped gaps are dropped.
+func parseSequence(seqText string, qualText string) ([]n.NucleicAcid, []int, Diagnostics) {
+	var (
+		nas     = make([]n.NucleicAcid, 0, len(seqText))
+		quality []int
+		diag    Diagnostics
+		idx     = 0
+	)
+	if qualText != "" {
+		quality = make([]int, 0, len(qualText))
+	}
+	for _, char := range seqText {
+		if unicode.IsSpace(char) {
+			continue
+		}
+		charIdx := idx
+		idx++
+		if isGap(char) {
+			diag.StrippedGaps++
+			continue
+		}
+		na, valid := n.LookupNucleicAcid(char)
+		nas = append(nas, na)
+		pos := len(nas)
+		if !valid {
+			nas[pos-1] = n.N
+			diag.InvalidCharacters = append(
+				diag.InvalidCharacters, InvalidCharacter{pos, string(char)})
+		} else if unicode.IsLower(char) {
+			last := len(diag.SoftMaskedRanges) - 1
+			if last >= 0 && diag.SoftMaskedRanges[last].Last == pos-1 {
+				diag.SoftMaskedRanges[last].Last = pos
+			} else {
+				diag.SoftMaskedRanges = append(diag.SoftMaskedRanges, Range{pos, pos})
+			}
+		}
+		if quality != nil {
+			quality = append(quality, int(qualText[charIdx])-phredOffset)
+		}
+	}
+	return nas, quality, diag
+}
@@ -9,29 +9,82 @@ import (
 	"strings"
 )
 
+// A sequence read from a FASTA or FASTQ file. Quality holds the
+// Phred quality score of each base; it's nil for FASTA input.
+// Diagnostics records what was changed while reading the sequence.
 type Sequence struct {
-	Name     string
-	Sequence []n.NucleicAcid
+	Name        string
+	Sequence    []n.NucleicAcid
+	Quality     []int
+	Diagnostics Diagnostics
 }
 
-func makeSequence(name string, seqText string) Sequence {
-	return Sequence{name, n.ReadString(seqText)}
+// Parse a sequence given as text. Whitespace and gaps ("-" and ".")
+// are removed, and characters other than IUPAC nucleotide codes are
+// replaced by N.
+func NewSequence(name string, seqText string) Sequence {
+	nas, _, diag := parseSequence(seqText, "")
+	return Sequence{name, nas, nil, diag}
 }
 
-func ReadSequences(reader io.Reader) []Sequence {
-	results := make([]Sequence, 0, 20)
+// Reads lines of any length, without their line terminators ("\n"
+// or "\r\n"). A byte order mark at the start of the input is
+// skipped.
+type lineReader struct {
+	reader  *bufio.Reader
+	err     error
+	isFirst bool
+}
+
+func newLineReader(reader io.Reader) *lineReader {
+	return &lineReader{reader: bufio.NewReader(reader), isFirst: true}
+}
+
+func (lr *lineReader) next() (string, bool) {
+	line, err := lr.reader.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		if err != io.EOF {
+			lr.err = err
+		}
+		return "", false
+	}
+	if lr.isFirst {
+		line = strings.TrimPrefix(line, "\ufeff")
+		lr.isFirst = false
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), true
+}
+
+// The input format is detected from the first line which isn't
+// blank: FASTQ files start with "@", anything else is read as FASTA.
+func readSequences(reader io.Reader, emit func(Sequence)) error {
+	lines := newLineReader(reader)
+	for line, ok := lines.next(); ok; line, ok = lines.next() {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "@") {
+			if err := readFASTQ(line, lines, emit); err != nil {
+				return err
+			}
+		} else {
+			readFASTA(line, lines, emit)
+		}
+		break
+	}
+	return lines.err
+}
+
+func readFASTA(firstLine string, lines *lineReader, emit func(Sequence)) {
 	name := ""
 	var seqBuffer bytes.Buffer
 	seqCount := 0
-	scanner := bufio.NewScanner(reader)
-	for scanner.Scan() {
-		line := scanner.Text()
+	for line, ok := firstLine, true; ok; line, ok = lines.next() {
 		if strings.HasPrefix(line, ";") || strings.HasPrefix(line, "#") {
 			continue
 		} else if strings.HasPrefix(line, ">") {
 			if name != "" {
-				results = append(
-					results, makeSequence(name, seqBuffer.String()))
+				emit(NewSequence(name, seqBuffer.String()))
 				seqBuffer.Reset()
 			}
 			seqCount++
@@ -43,11 +96,46 @@ func ReadSequences(reader io.Reader) []Sequence {
 			seqBuffer.WriteString(strings.TrimSpace(line))
 		}
 	}
+	if lines.err != nil {
+		// don't emit a sequence which may be incomplete
+		return
+	}
 	if name != "" || seqBuffer.Len() > 0 {
 		if name == "" {
 			name = "unnamed sequence"
 		}
-		results = append(results, makeSequence(name, seqBuffer.String()))
+		emit(NewSequence(name, seqBuffer.String()))
 	}
-	return results
+}
+
+// Read all sequences from reader into memory; reader may contain
+// either FASTA or FASTQ. Use StreamSequences for inputs which are too
+// large to be held in memory at once. An error is returned if reader
+// fails or the input is malformed; the sequences read before the
+// error are returned as well.
+func ReadSequences(reader io.Reader) ([]Sequence, error) {
+	results := make([]Sequence, 0, 20)
+	err := readSequences(reader, func(seq Sequence) {
+		results = append(results, seq)
+	})
+	return results, err
+}
+
+// Read sequences from reader in a separate goroutine. Each sequence
+// is sent to the returned channel as soon as its record is complete;
+// the channel is closed once the input is exhausted or an error
+// occurred. At most bufferSize parsed sequences are queued ahead of
+// the consumer. After the sequence channel was closed, the error
+// channel receives the error which stopped the reading, or nil.
+func StreamSequences(reader io.Reader, bufferSize int) (<-chan Sequence, <-chan error) {
+	c := make(chan Sequence, bufferSize)
+	errc := make(chan error, 1)
+	go func() {
+		err := readSequences(reader, func(seq Sequence) {
+			c <- seq
+		})
+		close(c)
+		errc <- err
+	}()
+	return c, errc
 }
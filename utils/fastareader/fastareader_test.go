@@ -1,8 +1,11 @@
 package fastareader
 
 import (
+	"errors"
 	"fmt"
 	n "github.com/hivdb/nucamino/types/nucleic"
+	"io"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -21,7 +24,7 @@ TG CA
 ;comment2
 WSMK.
   BDH-VN`)
-	seqs := ReadSequences(reader)
+	seqs, _ := ReadSequences(reader)
 	seq := seqs[0]
 	expectName := "TestSeq1"
 	if seq.Name != expectName {
@@ -37,9 +40,10 @@ WSMK.
 	if seq.Name != expectName {
 		t.Errorf(MSG_NOT_EQUAL, expectName, seq.Name)
 	}
+	// gaps are stripped
 	expectSeq = fmt.Sprintf(
 		"%#v", []n.NucleicAcid{
-			n.W, n.S, n.M, n.K, n.N /*.*/, n.B, n.D, n.H, n.N /*-*/, n.V, n.N})
+			n.W, n.S, n.M, n.K, n.B, n.D, n.H, n.V, n.N})
 	if fmt.Sprintf("%#v", seq.Sequence) != expectSeq {
 		t.Errorf(MSG_NOT_EQUAL, expectSeq, seq.Sequence)
 	}
@@ -52,7 +56,7 @@ ACGT
 TG CA
 >
 AAAA`)
-	seqs := ReadSequences(reader)
+	seqs, _ := ReadSequences(reader)
 	seq := seqs[0]
 	expectName := "unnamed sequence 1"
 	if seq.Name != expectName {
@@ -70,10 +74,146 @@ func TestReadSequencesBranch2(t *testing.T) {
 ACGT
 TG CA
 AAAA`)
-	seqs := ReadSequences(reader)
+	seqs, _ := ReadSequences(reader)
 	seq := seqs[0]
 	expectName := "unnamed sequence"
 	if seq.Name != expectName {
 		t.Errorf(MSG_NOT_EQUAL, expectName, seq.Name)
 	}
 }
+
+func TestStreamSequences(t *testing.T) {
+	reader := strings.NewReader(`
+>TestSeq1
+ACGT
+>TestSeq2
+TGCA
+>TestSeq3
+AAAA`)
+	expectNames := []string{"TestSeq1", "TestSeq2", "TestSeq3"}
+	idx := 0
+	seqs, errs := StreamSequences(reader, 1)
+	for seq := range seqs {
+		if seq.Name != expectNames[idx] {
+			t.Errorf(MSG_NOT_EQUAL, expectNames[idx], seq.Name)
+		}
+		idx++
+	}
+	if idx != len(expectNames) {
+		t.Errorf(MSG_NOT_EQUAL, len(expectNames), idx)
+	}
+	if err := <-errs; err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestReadFASTQ(t *testing.T) {
+	reader := strings.NewReader(`
+@TestSeq1 sample A
+ACGT
+TGCA
++
+IIII
+#+5@
+@
+AAA
++TestSeq2
+@@@
+@TestSeq3
+ACG
++
+II`)
+	seqs, err := ReadSequences(reader)
+	if err == nil {
+		t.Errorf("Expected an error for the truncated record")
+	}
+	if len(seqs) != 2 {
+		t.Fatalf(MSG_NOT_EQUAL, 2, len(seqs))
+	}
+	var cases = []struct {
+		name    string
+		seq     string
+		quality []int
+	}{
+		{"TestSeq1 sample A", "ACGTTGCA", []int{40, 40, 40, 40, 2, 10, 20, 31}},
+		{"unnamed sequence 2", "AAA", []int{31, 31, 31}},
+	}
+	for i, c := range cases {
+		seq := seqs[i]
+		if seq.Name != c.name {
+			t.Errorf(MSG_NOT_EQUAL, c.name, seq.Name)
+		}
+		if result := n.WriteString(seq.Sequence); result != c.seq {
+			t.Errorf(MSG_NOT_EQUAL, c.seq, result)
+		}
+		if fmt.Sprintf("%v", seq.Quality) != fmt.Sprintf("%v", c.quality) {
+			t.Errorf(MSG_NOT_EQUAL, c.quality, seq.Quality)
+		}
+	}
+}
+
+func TestReadFASTAHasNoQuality(t *testing.T) {
+	seqs, _ := ReadSequences(strings.NewReader(">TestSeq1\nACGT\n"))
+	if seqs[0].Quality != nil {
+		t.Errorf(MSG_NOT_EQUAL, nil, seqs[0].Quality)
+	}
+}
+
+func TestReadLongLines(t *testing.T) {
+	long := strings.Repeat("ACGT", 50000)
+	reader := strings.NewReader(">TestSeq1\n" + long + "\n>TestSeq2\nAAAA\n")
+	seqs, err := ReadSequences(reader)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(seqs) != 2 || len(seqs[0].Sequence) != len(long) {
+		t.Errorf("Expected two sequences of %d and 4 bases, got %d sequences", len(long), len(seqs))
+	}
+}
+
+func TestReadCRLFAndBOM(t *testing.T) {
+	reader := strings.NewReader("\ufeff>TestSeq1\r\nACGT\r\nTGCA\r\n")
+	seqs, _ := ReadSequences(reader)
+	if seqs[0].Name != "TestSeq1" {
+		t.Errorf(MSG_NOT_EQUAL, "TestSeq1", seqs[0].Name)
+	}
+	if result := n.WriteString(seqs[0].Sequence); result != "ACGTTGCA" {
+		t.Errorf(MSG_NOT_EQUAL, "ACGTTGCA", result)
+	}
+	if !seqs[0].Diagnostics.IsEmpty() {
+		t.Errorf(MSG_NOT_EQUAL, Diagnostics{}, seqs[0].Diagnostics)
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read(p []byte) (int, error) {
+	return 0, errors.New("read failed")
+}
+
+func TestReadError(t *testing.T) {
+	reader := io.MultiReader(strings.NewReader(">TestSeq1\nACGT\n>TestSeq2\nAC"), failingReader{})
+	seqs, err := ReadSequences(reader)
+	if err == nil || err.Error() != "read failed" {
+		t.Errorf(MSG_NOT_EQUAL, "read failed", err)
+	}
+	// the incomplete sequence isn't returned
+	if len(seqs) != 1 {
+		t.Errorf(MSG_NOT_EQUAL, 1, len(seqs))
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	seq := NewSequence("TestSeq1", "acgT--TGc a*CG.1tt")
+	if result := n.WriteString(seq.Sequence); result != "ACGTTGCANCGNTT" {
+		t.Errorf(MSG_NOT_EQUAL, "ACGTTGCANCGNTT", result)
+	}
+	expect := Diagnostics{
+		InvalidCharacters: []InvalidCharacter{{9, "*"}, {12, "1"}},
+		StrippedGaps:      3,
+		SoftMaskedRanges:  []Range{{1, 3}, {7, 8}, {13, 14}},
+	}
+	if !reflect.DeepEqual(seq.Diagnostics, expect) {
+		t.Errorf(MSG_NOT_EQUAL, expect, seq.Diagnostics)
+	}
+}
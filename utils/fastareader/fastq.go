@@ -0,0 +1,84 @@
+package fastareader
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// The offset of the quality characters of FASTQ files (Sanger /
+// Illumina 1.8+ encoding)
+const phredOffset = 33
+
+func makeFASTQSequence(name string, seqText string, qualText string) (Sequence, error) {
+	numBases := utf8.RuneCountInString(strings.Join(strings.Fields(seqText), ""))
+	if len(qualText) != numBases {
+		return Sequence{}, fmt.Errorf(
+			"FASTQ record %v has %d bases but %d quality scores",
+			name, numBases, len(qualText))
+	}
+	nas, quality, diag := parseSequence(seqText, qualText)
+	return Sequence{name, nas, quality, diag}, nil
+}
+
+// A FASTQ record consists of a header line starting with "@", the
+// sequence, a separator line starting with "+" and the qualities of
+// the bases. Both the sequence and the qualities may be wrapped over
+// several lines; since a quality line may also start with "@", the
+// end of a record is determined by the number of qualities read.
+func readFASTQ(firstLine string, lines *lineReader, emit func(Sequence)) error {
+	const (
+		inHeader = iota
+		inSequence
+		inQuality
+	)
+	var (
+		name                  string
+		seqBuffer, qualBuffer bytes.Buffer
+		seqCount              = 0
+		state                 = inHeader
+	)
+	for line, ok := firstLine, true; ok; line, ok = lines.next() {
+		line = strings.TrimSpace(line)
+		switch state {
+		case inHeader:
+			if line == "" {
+				continue
+			}
+			if !strings.HasPrefix(line, "@") {
+				return fmt.Errorf(
+					"Expected a FASTQ record starting with \"@\" after record %d, got %q",
+					seqCount, line)
+			}
+			seqCount++
+			name = strings.TrimSpace(strings.TrimPrefix(line, "@"))
+			if name == "" {
+				name = fmt.Sprintf("unnamed sequence %d", seqCount)
+			}
+			state = inSequence
+		case inSequence:
+			if strings.HasPrefix(line, "+") {
+				state = inQuality
+			} else {
+				seqBuffer.WriteString(line)
+			}
+		case inQuality:
+			qualBuffer.WriteString(line)
+		}
+		if state == inQuality && qualBuffer.Len() >= seqBuffer.Len() {
+			seq, err := makeFASTQSequence(name, seqBuffer.String(), qualBuffer.String())
+			if err != nil {
+				return err
+			}
+			emit(seq)
+			seqBuffer.Reset()
+			qualBuffer.Reset()
+			state = inHeader
+		}
+	}
+	if lines.err == nil && state != inHeader {
+		return fmt.Errorf("FASTQ record %v is truncated", name)
+	}
+	return nil
+}